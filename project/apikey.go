@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"csvapi-test/audit"
+	"csvapi-test/auth"
+	"csvapi-test/config"
+	"csvapi-test/model"
+	"csvapi-test/repository"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const apiKeyUsage = `Usage: apikey [-tenant name] <command>
+
+Keys are managed in the tenant, the default tenant if omitted.
+
+Commands:
+  create <name> <scope>...   Create a key with the scopes (data:read, data:write, admin), printed once
+  list                       List the keys and their last use
+  revoke <id>                Revoke the key with the id`
+
+// Run the apikey subcommand, used to create the first admin key, and return the process exit code
+func runAPIKey(dbConfig config.DatabaseConfig, args []string) int {
+	flags := flag.NewFlagSet("apikey", flag.ContinueOnError)
+	tenant := flags.String("tenant", "", "tenant of the keys")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	args = flags.Args()
+	if len(args) == 0 {
+		fmt.Println(apiKeyUsage)
+		return 2
+	}
+	if !auth.ValidTenant(*tenant) {
+		fmt.Printf("tenant must match %s\n", config.TenantPattern)
+		return 2
+	}
+
+	db, err := model.DbConfig(dbConfig)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	keys := repository.NewGormAPIKeyStore(db)
+	ctx := auth.WithTenant(audit.WithActor(context.Background(), "cli"), *tenant)
+
+	switch args[0] {
+	case "create":
+		if len(args) < 3 {
+			fmt.Println(apiKeyUsage)
+			return 2
+		}
+		for _, scope := range args[2:] {
+			if !auth.ValidScope(scope) {
+				fmt.Printf("unknown scope %q, expected one of %s\n", scope, strings.Join(auth.Scopes, ", "))
+				return 2
+			}
+		}
+		apiKey, key, err := keys.Create(ctx, args[1], args[2:])
+		if err != nil {
+			fmt.Println("Error creating api key:", err)
+			return 1
+		}
+		fmt.Printf("created api key %d %s, it will not be shown again:\n%s\n", apiKey.ID, apiKey.Name, key)
+	case "list":
+		apiKeys, err := keys.List(ctx)
+		if err != nil {
+			fmt.Println("Error listing api keys:", err)
+			return 1
+		}
+		for _, apiKey := range apiKeys {
+			fmt.Printf("%d\t%s\t%s...\t%s\tlast used %s\t%s\n", apiKey.ID, apiKey.Name, apiKey.Prefix,
+				strings.Join(apiKey.Scopes, ","), formatTime(apiKey.LastUsedAt, "never"), formatTime(apiKey.RevokedAt, "active"))
+		}
+	case "revoke":
+		if len(args) < 2 {
+			fmt.Println(apiKeyUsage)
+			return 2
+		}
+		id, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			fmt.Println("revoke expects a key id")
+			return 2
+		}
+		if _, err := keys.Revoke(ctx, id); err != nil {
+			fmt.Println("Error revoking api key:", err)
+			return 1
+		}
+		fmt.Printf("revoked api key %d\n", id)
+	default:
+		fmt.Println(apiKeyUsage)
+		return 2
+	}
+	return 0
+}
+
+func formatTime(value *time.Time, zero string) string {
+	if value == nil {
+		return zero
+	}
+	return value.Format(time.RFC3339)
+}
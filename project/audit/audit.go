@@ -0,0 +1,70 @@
+package audit
+
+import (
+	"context"
+	"csvapi-test/auth"
+	"csvapi-test/model"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Who made a request and its correlation id, recorded with every audit entry
+type Info struct {
+	Actor     string
+	RequestID string
+}
+
+type infoKey struct{}
+
+// Context carrying the audit info
+func WithInfo(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, infoKey{}, info)
+}
+
+// Context carrying the audit info of ctx with the actor replaced
+func WithActor(ctx context.Context, actor string) context.Context {
+	info := FromContext(ctx)
+	info.Actor = actor
+	return WithInfo(ctx, info)
+}
+
+// Audit info carried by ctx, empty if none
+func FromContext(ctx context.Context) Info {
+	if ctx == nil {
+		return Info{}
+	}
+	info, _ := ctx.Value(infoKey{}).(Info)
+	return info
+}
+
+// Append an audit entry within tx, with json summaries of the data before and after the mutation.
+// Nil summaries are left empty, the actor, tenant and request id are read from the tx context.
+func Record(tx *gorm.DB, action, target string, before, after interface{}) error {
+	info := FromContext(tx.Statement.Context)
+	entry := model.AuditEntry{
+		Actor:     info.Actor,
+		Tenant:    auth.Tenant(tx.Statement.Context),
+		Action:    action,
+		Target:    target,
+		RequestID: info.RequestID,
+		CreatedAt: time.Now().UTC(),
+	}
+	var err error
+	if entry.Before, err = summary(before); err != nil {
+		return err
+	}
+	if entry.After, err = summary(after); err != nil {
+		return err
+	}
+	return tx.Create(&entry).Error
+}
+
+func summary(value interface{}) (string, error) {
+	if value == nil {
+		return "", nil
+	}
+	content, err := json.Marshal(value)
+	return string(content), err
+}
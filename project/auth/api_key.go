@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// Prefix of every api key, tells them apart from other bearer tokens
+const APIKeyPrefix = "csv_"
+
+// Characters of a key kept in clear text, to recognise it in listings
+const apiKeyDisplayLength = len(APIKeyPrefix) + 8
+
+// New random api key, only its hash must be stored
+func GenerateAPIKey() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// Hash of the api key stored at rest. The key is random and long enough
+// for a fast hash to be safe, which keeps the per request lookup cheap.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Leading characters of the api key shown in listings
+func APIKeyDisplayPrefix(key string) string {
+	if len(key) < apiKeyDisplayLength {
+		return key
+	}
+	return key[:apiKeyDisplayLength]
+}
+
+// Check token has the api key format
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	// Returned when no key of the set has the key id of a token
+	ErrUnknownKey = errors.New("no key matches the token key id")
+	// Returned when the remote key set cannot be fetched
+	ErrKeySetUnavailable = errors.New("json web key set unavailable")
+)
+
+// Unknown key ids refetch a remote key set at most this often, so forged key ids cannot flood the issuer
+const minKeySetRefetch = time.Minute
+
+// Public keys tokens are verified with, loaded from a JSON web key set file or url
+type KeySet struct {
+	url        string
+	refresh    time.Duration
+	client     *http.Client
+	mutex      sync.Mutex
+	keys       map[string]interface{} // Rsa or ecdsa public keys by key id
+	fetchedAt  time.Time
+	fetchErr   error         // Error of the last fetch, nil once it succeeded
+	refreshing chan struct{} // Closed once the running fetch ends, nil when none is running
+}
+
+// Key set of a local JWKS file, loaded once
+func LoadKeySetFile(path string) (*KeySet, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading jwks file: %w", err)
+	}
+	keys, err := parseKeySet(content)
+	if err != nil {
+		return nil, fmt.Errorf("parsing jwks file %s: %w", path, err)
+	}
+	return &KeySet{keys: keys}, nil
+}
+
+// Key set fetched from url on first use, then every refresh interval or when a token has an unknown key id
+func NewRemoteKeySet(url string, refresh time.Duration) *KeySet {
+	return &KeySet{url: url, refresh: refresh, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Public key with the key id, an empty id matches the only key of a single key set.
+// The key set is fetched without holding the lock, requests arriving during a refresh use the previous keys,
+// and only wait for it when there are no keys yet. A waiting request that is cancelled stops waiting, the fetch goes on.
+func (keySet *KeySet) Key(ctx context.Context, kid string) (interface{}, error) {
+	keySet.mutex.Lock()
+	if keySet.url != "" && keySet.stale(kid) {
+		refreshing, started := keySet.refreshing, false
+		if refreshing == nil {
+			keySet.fetchedAt = time.Now()
+			refreshing, started = make(chan struct{}), true
+			keySet.refreshing = refreshing
+			go keySet.fetch(context.WithoutCancel(ctx))
+		}
+		// The request starting the fetch waits for it, the others only when there are no keys yet
+		if started || keySet.keys == nil {
+			keySet.mutex.Unlock()
+			select {
+			case <-refreshing:
+			case <-ctx.Done():
+				return nil, fmt.Errorf("%w: %v", ErrKeySetUnavailable, ctx.Err())
+			}
+			keySet.mutex.Lock()
+			if keySet.keys == nil {
+				err := keySet.fetchErr
+				keySet.mutex.Unlock()
+				return nil, err
+			}
+		}
+	}
+	defer keySet.mutex.Unlock()
+	return keySet.lookup(kid)
+}
+
+// Whether the keys must be fetched for the key id, the lock must be held
+func (keySet *KeySet) stale(kid string) bool {
+	if keySet.keys == nil || time.Since(keySet.fetchedAt) >= keySet.refresh {
+		return true
+	}
+	_, err := keySet.lookup(kid)
+	return err != nil && time.Since(keySet.fetchedAt) >= minKeySetRefetch
+}
+
+func (keySet *KeySet) lookup(kid string) (interface{}, error) {
+	if kid == "" && len(keySet.keys) == 1 {
+		for _, key := range keySet.keys {
+			return key, nil
+		}
+	}
+	key, found := keySet.keys[kid]
+	if !found {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// Fetch the key set started by Key and swap the keys in, a failed refresh keeps serving the previous keys.
+// The context is detached from the request that started the fetch, the waiters of every request share
+// the fetch and it is only bounded by the timeout of the http client.
+func (keySet *KeySet) fetch(ctx context.Context) {
+	keys, err := keySet.download(ctx)
+
+	keySet.mutex.Lock()
+	defer keySet.mutex.Unlock()
+	if err == nil {
+		keySet.keys = keys
+	}
+	keySet.fetchErr = err
+	close(keySet.refreshing)
+	keySet.refreshing = nil
+}
+
+func (keySet *KeySet) download(ctx context.Context) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, keySet.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeySetUnavailable, err)
+	}
+	res, err := keySet.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeySetUnavailable, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s returned %s", ErrKeySetUnavailable, keySet.url, res.Status)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeySetUnavailable, err)
+	}
+	keys, err := parseKeySet(content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeySetUnavailable, err)
+	}
+	return keys, nil
+}
+
+// Single key of a JSON web key set, only the public parameters are read
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Parse the signing keys of the set, symmetric and encryption keys are ignored
+func parseKeySet(content []byte) (map[string]interface{}, error) {
+	var keySet struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &keySet); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]interface{}{}
+	for i, jwk := range keySet.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		var (
+			key interface{}
+			err error
+		)
+		switch jwk.Kty {
+		case "RSA":
+			key, err = jwk.rsaPublicKey()
+		case "EC":
+			key, err = jwk.ecdsaPublicKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %d %q: %w", i, jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no RSA or EC signing key in the set")
+	}
+	return keys, nil
+}
+
+func (jwk jsonWebKey) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(jwk.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus: %w", err)
+	}
+	e, err := decodeBigInt(jwk.E)
+	if err != nil || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid exponent")
+	}
+	if n.BitLen() < 2048 {
+		return nil, fmt.Errorf("modulus of %d bits is too small", n.BitLen())
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (jwk jsonWebKey) ecdsaPublicKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch jwk.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+	}
+	x, err := decodeBigInt(jwk.X)
+	if err != nil {
+		return nil, fmt.Errorf("invalid x coordinate: %w", err)
+	}
+	y, err := decodeBigInt(jwk.Y)
+	if err != nil {
+		return nil, fmt.Errorf("invalid y coordinate: %w", err)
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("point is not on the curve")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	content, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(content), nil
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"csvapi-test/config"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Returned when a bearer token is malformed, expired, not issued for this app or badly signed
+var ErrInvalidToken = errors.New("invalid bearer token")
+
+// Asymmetric algorithms accepted, so a public key can never be used as an hmac secret
+var tokenMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// Verifies bearer tokens of the OIDC provider and maps their roles to scopes
+type TokenVerifier struct {
+	keys   *KeySet
+	config config.JWTConfig
+	parser *jwt.Parser
+}
+
+func NewTokenVerifier(jwtConfig config.JWTConfig) (*TokenVerifier, error) {
+	verifier := &TokenVerifier{config: jwtConfig}
+	if jwtConfig.JWKSFile != "" {
+		keys, err := LoadKeySetFile(jwtConfig.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		verifier.keys = keys
+	} else {
+		verifier.keys = NewRemoteKeySet(jwtConfig.JWKSURL, jwtConfig.RefreshInterval)
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(tokenMethods),
+		jwt.WithLeeway(jwtConfig.Leeway),
+		jwt.WithExpirationRequired(),
+	}
+	if jwtConfig.Issuer != "" {
+		options = append(options, jwt.WithIssuer(jwtConfig.Issuer))
+	}
+	if jwtConfig.Audience != "" {
+		options = append(options, jwt.WithAudience(jwtConfig.Audience))
+	}
+	verifier.parser = jwt.NewParser(options...)
+	return verifier, nil
+}
+
+// Principal of a valid token, ErrInvalidToken if the token is rejected
+// and ErrKeySetUnavailable if the keys to verify it cannot be fetched
+func (verifier *TokenVerifier) Verify(ctx context.Context, token string) (Principal, error) {
+	claims := jwt.MapClaims{}
+	_, err := verifier.parser.ParseWithClaims(token, claims, func(parsed *jwt.Token) (interface{}, error) {
+		kid, _ := parsed.Header["kid"].(string)
+		return verifier.keys.Key(ctx, kid)
+	})
+	if errors.Is(err, ErrKeySetUnavailable) {
+		return Principal{}, err
+	} else if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return Principal{}, fmt.Errorf("%w: sub claim is required", ErrInvalidToken)
+	}
+	principal := Principal{Subject: "jwt:" + subject, Scopes: verifier.scopes(claims)}
+
+	if verifier.config.TenantClaim != "" {
+		tenants := claimStrings(claims, verifier.config.TenantClaim)
+		if len(tenants) != 1 || tenants[0] == "" || !ValidTenant(tenants[0]) {
+			return Principal{}, fmt.Errorf("%w: %s claim must hold a single valid tenant", ErrInvalidToken, verifier.config.TenantClaim)
+		}
+		principal.Tenant = tenants[0]
+	}
+	return principal, nil
+}
+
+// Scopes granted by the roles of the claims, unknown roles grant nothing
+func (verifier *TokenVerifier) scopes(claims jwt.MapClaims) (scopes []string) {
+	granted := map[string]bool{}
+	for _, role := range claimStrings(claims, verifier.config.RolesClaim) {
+		for _, scope := range verifier.config.RoleScopes[role] {
+			if !granted[scope] {
+				granted[scope] = true
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes
+}
+
+// String or string list claim at the dotted path e.g realm_access.roles
+func claimStrings(claims map[string]interface{}, path string) []string {
+	var value interface{} = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+
+	switch value := value.(type) {
+	case string:
+		return strings.Fields(value) // space separated like the standard scope claim
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if item, ok := item.(string); ok {
+				values = append(values, item)
+			}
+		}
+		return values
+	}
+	return nil
+}
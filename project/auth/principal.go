@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"csvapi-test/config"
+)
+
+// Permissions granted to api keys
+const (
+	ScopeDataRead  = "data:read"  // Query candles
+	ScopeDataWrite = "data:write" // Upload, update and delete candles
+	ScopeAdmin     = "admin"      // Manage api keys, retention and read the audit log, grants every other scope
+)
+
+// Supported scopes, listed in config to validate the scopes granted to token roles
+var Scopes = config.Scopes
+
+// Check scope is supported
+func ValidScope(scope string) bool {
+	for _, supported := range Scopes {
+		if scope == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticated caller of a request
+type Principal struct {
+	Subject string // Audit actor e.g apikey:1:ci-uploader
+	Tenant  string // Tenant whose data the caller accesses, empty for the default tenant
+	Scopes  []string
+}
+
+// Check tenant is a valid tenant name, the default tenant is always valid
+func ValidTenant(tenant string) bool {
+	return tenant == "" || config.TenantPattern.MatchString(tenant)
+}
+
+// Check the principal was granted scope, directly or through the admin scope
+func (principal Principal) HasScope(scope string) bool {
+	for _, granted := range principal.Scopes {
+		if granted == scope || granted == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// Context carrying the principal
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// Principal carried by ctx, false if the request was not authenticated
+func FromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+type tenantKey struct{}
+
+// Context scoped to the tenant, every candle read and write with it is limited to the tenant
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// Tenant ctx is scoped to, the default tenant if none
+func Tenant(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// Page size of the server when the query has no limit
+const defaultLimit = 100
+
+// Candle of the api
+type Candle struct {
+	ID     uint64  `json:"id"`
+	UNIX   uint64  `json:"unix"` // Unix milliseconds
+	SYMBOL string  `json:"symbol"`
+	OPEN   float32 `json:"open"`
+	HIGH   float32 `json:"high"`
+	LOW    float32 `json:"low"`
+	CLOSE  float32 `json:"close"`
+}
+
+// Filters of the candle queries, zero fields are not sent
+type Query struct {
+	Search string
+	Filter string // Comparisons of the candle fields separated by ; e.g close>42000;symbol in (BTCUSDT,ETHUSDT)
+	From   uint64 // Start of the range in unix milliseconds, inclusive
+	To     uint64 // End of the range in unix milliseconds, exclusive
+	Limit  int    // Page size, 100 by default
+}
+
+func (query Query) values(page int) url.Values {
+	values := url.Values{}
+	if query.Search != "" {
+		values.Set("search", query.Search)
+	}
+	if query.Filter != "" {
+		values.Set("filter", query.Filter)
+	}
+	if query.From > 0 {
+		values.Set("from", strconv.FormatUint(query.From, 10))
+	}
+	if query.To > 0 {
+		values.Set("to", strconv.FormatUint(query.To, 10))
+	}
+	if query.Limit > 0 {
+		values.Set("limit", strconv.Itoa(query.Limit))
+	}
+	if page > 1 {
+		values.Set("page", strconv.Itoa(page))
+	}
+	return values
+}
+
+// Candles of the page of the query, pages start at 1
+func (client *Client) Candles(ctx context.Context, query Query, page int) ([]Candle, error) {
+	var candles []Candle
+	err := client.get(ctx, "/data", query.values(page), &candles)
+	return candles, err
+}
+
+// Iterator over every candle of the query, fetching the pages as they are reached
+func (client *Client) AllCandles(query Query) *CandleIterator {
+	return &CandleIterator{client: client, query: query}
+}
+
+// Candles of a query across its pages, e.g
+//
+//	candles := client.AllCandles(query)
+//	for candles.Next(ctx) {
+//		candle := candles.Candle()
+//	}
+//	err := candles.Err()
+type CandleIterator struct {
+	client  *Client
+	query   Query
+	page    int // Last fetched page
+	candles []Candle
+	index   int
+	last    bool // The last fetched page was short
+	err     error
+}
+
+// Move to the next candle, fetching the next page when needed. False once every candle was read or a page failed.
+func (iterator *CandleIterator) Next(ctx context.Context) bool {
+	if iterator.err != nil {
+		return false
+	}
+	if iterator.index+1 < len(iterator.candles) {
+		iterator.index++
+		return true
+	}
+	if iterator.last {
+		return false
+	}
+
+	// A page shorter than the limit is the last one. The pagination object is not requested,
+	// the server would count the whole query for every page.
+	candles, err := iterator.client.Candles(ctx, iterator.query, iterator.page+1)
+	if err != nil {
+		iterator.err = err
+		return false
+	}
+	limit := iterator.query.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	iterator.page++
+	iterator.candles = candles
+	iterator.index = 0
+	iterator.last = len(candles) < limit
+	return len(candles) > 0
+}
+
+// Current candle, valid after Next returned true
+func (iterator *CandleIterator) Candle() Candle {
+	return iterator.candles[iterator.index]
+}
+
+// Error of the failed page, nil once every candle was read
+func (iterator *CandleIterator) Err() error {
+	return iterator.err
+}
@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Api version the client calls
+const Version = "v1"
+
+// Headers read by the api, the same as the server middlewares
+const (
+	apiKeyHeader    = "X-API-Key"
+	requestIDHeader = "X-Request-ID"
+)
+
+// Settings of the client, zero fields take their default
+type Options struct {
+	APIKey       string        // Sent in the X-API-Key header
+	BearerToken  string        // Sent in the Authorization header, when there is no api key
+	HTTPClient   *http.Client  // http.DefaultClient by default
+	Retries      int           // Retries of the failures worth retrying, 3 by default, negative to never retry
+	RetryWait    time.Duration // Wait before the first retry without a Retry-After, doubled on every retry, 500ms by default
+	MaxRetryWait time.Duration // Longest wait between retries, Retry-After included, 30s by default
+	PollInterval time.Duration // Wait between the import status requests of WaitImport, 1s by default
+}
+
+// Client of the versioned api, safe for concurrent use
+type Client struct {
+	baseURL string // Url of the version prefix e.g http://localhost:8080/v1
+	options Options
+}
+
+// Client of the api served at baseURL e.g http://localhost:8080
+func New(baseURL string, options Options) (*Client, error) {
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("client: invalid base url: %w", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("client: base url %q must be http or https", baseURL)
+	}
+
+	if options.HTTPClient == nil {
+		options.HTTPClient = http.DefaultClient
+	}
+	if options.Retries == 0 {
+		options.Retries = 3
+	} else if options.Retries < 0 {
+		options.Retries = 0
+	}
+	if options.RetryWait <= 0 {
+		options.RetryWait = 500 * time.Millisecond
+	}
+	if options.MaxRetryWait <= 0 {
+		options.MaxRetryWait = 30 * time.Second
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = time.Second
+	}
+	return &Client{baseURL: base.String() + "/" + Version, options: options}, nil
+}
+
+// Request to the path of the api version, with the credentials of the client
+func (client *Client) newRequest(ctx context.Context, method, path string, query url.Values, body io.Reader) (*http.Request, error) {
+	target := client.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	request, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/json")
+	if client.options.APIKey != "" {
+		request.Header.Set(apiKeyHeader, client.options.APIKey)
+	} else if client.options.BearerToken != "" {
+		request.Header.Set("Authorization", "Bearer "+client.options.BearerToken)
+	}
+	return request, nil
+}
+
+// Send a GET request, retried on the failures worth retrying, and decode the data of the response into data
+func (client *Client) get(ctx context.Context, path string, query url.Values, data interface{}) error {
+	return client.retry(ctx, func() error {
+		request, err := client.newRequest(ctx, http.MethodGet, path, query, nil)
+		if err != nil {
+			return err
+		}
+		response, err := client.options.HTTPClient.Do(request)
+		if err != nil {
+			return err
+		}
+		return decode(response, data)
+	})
+}
+
+// Run the attempt until it succeeds, fails for good or the retries are exhausted, returns its last error
+func (client *Client) retry(ctx context.Context, attempt func() error) error {
+	for retry := 0; ; retry++ {
+		err := attempt()
+		wait, ok := client.retryWait(err, retry)
+		if !ok {
+			return err
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// Wait before retrying the failed attempt, false if it must not be retried
+func (client *Client) retryWait(err error, retry int) (time.Duration, bool) {
+	if err == nil || retry >= client.options.Retries {
+		return 0, false
+	}
+	wait := client.options.RetryWait << retry
+	var apiErr *Error
+	var urlErr *url.Error
+	if errors.As(err, &apiErr) {
+		if !apiErr.Temporary() {
+			return 0, false
+		}
+		if apiErr.RetryAfter > 0 {
+			wait = apiErr.RetryAfter
+		}
+	} else if !errors.As(err, &urlErr) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// Only connection failures are retried, not the cancellations or the decoding errors
+		return 0, false
+	}
+	return min(wait, client.options.MaxRetryWait), true
+}
+
+// Success response body
+type envelope struct {
+	Data interface{} `json:"data"`
+}
+
+// Decode the data of the success envelope into data, or the problem of the failed response
+func decode(response *http.Response, data interface{}) error {
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		return newError(response)
+	}
+	if err := json.NewDecoder(response.Body).Decode(&envelope{Data: data}); err != nil {
+		return fmt.Errorf("client: decoding the %s response: %w", response.Request.URL.Path, err)
+	}
+	return nil
+}
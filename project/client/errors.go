@@ -0,0 +1,78 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Problem response of a failed request, match on its Code
+type Error struct {
+	Problem
+	RetryAfter time.Duration // Retry-After of the response, 0 when not sent
+}
+
+// Error response body following RFC 7807 problem details, the same as the server problems
+type Problem struct {
+	Type      string       `json:"type"`   // Uri of the error code e.g urn:csvapi:error:CSV_INVALID_HEADER
+	Title     string       `json:"title"`  // Summary of the error code
+	Status    int          `json:"status"` // Http status code
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`   // Request path
+	Code      string       `json:"code"`                 // Stable error code e.g RATE_LIMITED
+	RequestID string       `json:"request_id,omitempty"` // For matching the response with the server logs
+	Errors    []FieldError `json:"errors,omitempty"`     // Invalid payload fields
+	Details   interface{}  `json:"details,omitempty"`    // Structured context of the error, e.g the invalid csv line
+}
+
+// Invalid field of a request payload
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"` // Failed validation rule e.g required
+	Message string `json:"message"`
+}
+
+func (err *Error) Error() string {
+	if err.Code == "" {
+		return fmt.Sprintf("client: %d %s", err.Status, err.Title)
+	}
+	if err.Detail == "" {
+		return fmt.Sprintf("client: %d %s: %s", err.Status, err.Code, err.Title)
+	}
+	return fmt.Sprintf("client: %d %s: %s", err.Status, err.Code, err.Detail)
+}
+
+// Problem codes of the failures that may succeed when retried
+var temporaryCodes = map[string]bool{
+	"RATE_LIMITED":       true,
+	"TOO_MANY_IMPORTS":   true,
+	"DB_CONFLICT":        true,
+	"SHUTTING_DOWN":      true,
+	"IMPORT_INTERRUPTED": true,
+	"AUTH_UNAVAILABLE":   true,
+}
+
+// Whether the request may succeed when retried, e.g rate limited or interrupted by a shutdown.
+// Responses that are not problems, e.g from a proxy, are temporary on 502, 503 and 504.
+func (err *Error) Temporary() bool {
+	if err.Code == "" {
+		return err.Status == http.StatusBadGateway || err.Status == http.StatusServiceUnavailable || err.Status == http.StatusGatewayTimeout
+	}
+	return temporaryCodes[err.Code]
+}
+
+// Error of the failed response, from its problem body when it has one
+func newError(response *http.Response) *Error {
+	err := &Error{}
+	if seconds, parseErr := strconv.Atoi(response.Header.Get("Retry-After")); parseErr == nil && seconds > 0 {
+		err.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if json.Unmarshal(body, &err.Problem) != nil || err.Code == "" {
+		err.Problem = Problem{Status: response.StatusCode, Title: http.StatusText(response.StatusCode)}
+	}
+	return err
+}
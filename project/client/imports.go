@@ -0,0 +1,185 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Status of an import
+const (
+	ImportRunning     = "running"
+	ImportCommitted   = "committed"
+	ImportInterrupted = "interrupted" // Rolled back by a shutdown, the upload can be retried
+)
+
+// Rows of a committed upload
+type ImportResult struct {
+	CSVLinesRead   string `json:"csvLinesRead"`
+	TotalSavedRows string `json:"totalSavedRows"`
+}
+
+// Status of the import of an upload, found by the request id of the upload
+type ImportStatus struct {
+	RequestID  string         `json:"request_id"`
+	Status     string         `json:"status"`
+	Summary    *ImportSummary `json:"summary,omitempty"`     // Set once the import finished
+	FinishedAt *time.Time     `json:"finished_at,omitempty"` // Set once the import finished
+}
+
+// Uploaded file and rows of a finished import
+type ImportSummary struct {
+	FileName string `json:"file_name"`
+	FileSize int64  `json:"file_size"`
+	Rows     int    `json:"rows"` // Saved rows, or read rows of an interrupted import
+}
+
+// Settings of an upload
+type ImportOptions struct {
+	// X-Request-ID of the upload, generated when empty. Its import status is found by it.
+	RequestID string
+	// Called as the file is sent with the bytes sent so far, starts over when the upload is retried
+	Progress func(sent, total int64)
+}
+
+// Upload the csv file at path, see Import
+func (client *Client) ImportFile(ctx context.Context, path string, options ImportOptions) (*ImportResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return client.Import(ctx, filepath.Base(path), file, options)
+}
+
+// Upload the csv content as the file name, the rows are saved in a single import.
+// The upload is retried when it is rate limited or interrupted by a shutdown. When the connection fails,
+// the import status of the upload is checked first so an import committed by the server is not sent twice.
+func (client *Client) Import(ctx context.Context, name string, content io.ReadSeeker, options ImportOptions) (*ImportResult, error) {
+	total, err := content.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if options.RequestID == "" {
+		options.RequestID = newRequestID()
+	}
+
+	var result ImportResult
+	err = client.retry(ctx, func() error {
+		err := client.upload(ctx, name, content, total, options, &result)
+		var urlErr *url.Error
+		if !errors.As(err, &urlErr) || ctx.Err() != nil {
+			return err
+		}
+		// The response was lost, the server may have received the whole upload
+		status, statusErr := client.WaitImport(ctx, options.RequestID)
+		if statusErr != nil || status.Status != ImportCommitted {
+			return err
+		}
+		rows := strconv.Itoa(status.Summary.Rows)
+		result = ImportResult{CSVLinesRead: rows, TotalSavedRows: rows}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Send the content in a multipart form, streamed from the start of the content
+func (client *Client) upload(ctx context.Context, name string, content io.ReadSeeker, total int64, options ImportOptions, result *ImportResult) error {
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	request, err := client.newRequest(ctx, http.MethodPost, "/data", nil, body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	request.Header.Set(requestIDHeader, options.RequestID)
+
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		part, err := form.CreateFormFile("csv_file", name)
+		if err == nil {
+			_, err = io.Copy(part, &progressReader{reader: content, total: total, progress: options.Progress})
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+	// The content is read again by the next attempt, stop the writing before returning
+	defer func() {
+		body.Close()
+		<-written
+	}()
+
+	response, err := client.options.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	return decode(response, result)
+}
+
+// Reader calling progress with the bytes read so far
+type progressReader struct {
+	reader   io.Reader
+	sent     int64
+	total    int64
+	progress func(sent, total int64)
+}
+
+func (reader *progressReader) Read(p []byte) (int, error) {
+	n, err := reader.reader.Read(p)
+	if n > 0 && reader.progress != nil {
+		reader.sent += int64(n)
+		reader.progress(reader.sent, reader.total)
+	}
+	return n, err
+}
+
+// Status of the import of the upload sent with the request id.
+// Fails with NOT_FOUND when the upload was rejected, never received or is running on another instance.
+func (client *Client) ImportStatus(ctx context.Context, requestID string) (*ImportStatus, error) {
+	var status ImportStatus
+	if err := client.get(ctx, "/imports/"+url.PathEscape(requestID), nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Poll the import status of the upload until it is no longer running, or ctx is done
+func (client *Client) WaitImport(ctx context.Context, requestID string) (*ImportStatus, error) {
+	for {
+		status, err := client.ImportStatus(ctx, requestID)
+		if err != nil || status.Status != ImportRunning {
+			return status, err
+		}
+		timer := time.NewTimer(client.options.PollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,699 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Application settings, loaded from defaults, then the config file, then environment, then flags
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Import    ImportConfig    `yaml:"import"`
+	CORS      CORSConfig      `yaml:"cors"`
+	Retention RetentionConfig `yaml:"retention"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Tenants   TenantsConfig   `yaml:"tenants"`
+	Limits    LimitsConfig    `yaml:"limits"`
+	Log       LogConfig       `yaml:"log"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	API       APIConfig       `yaml:"api"`
+}
+
+// Versioned routes, the unversioned paths are deprecated aliases of the v1 routes
+type APIConfig struct {
+	LegacyRoutes bool      `yaml:"legacy_routes"` // Serve the unversioned aliases, disable once every client calls /v1
+	DeprecatedAt time.Time `yaml:"deprecated_at"` // Date of the Deprecation header of the aliases
+	Sunset       time.Time `yaml:"sunset"`        // Date of the Sunset header, after which the aliases may be removed, zero omits it
+}
+
+// Structured logs written to stderr
+type LogConfig struct {
+	Level  string `yaml:"level"`  // debug, info, warn or error, debug includes every query
+	Format string `yaml:"format"` // json or text
+}
+
+// Http server settings
+type ServerConfig struct {
+	Port            string        `yaml:"port"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	RequestTimeout  time.Duration `yaml:"request_timeout"` // Timeout middleware limit per request
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	MaxHeaderBytes  int           `yaml:"max_header_bytes"`
+	MinSpoolFree    int64         `yaml:"min_spool_free"` // Free bytes of the upload spool directory (TMPDIR) required to be ready, 0 disables
+}
+
+// Database connection settings
+type DatabaseConfig struct {
+	Driver   string `yaml:"driver"` // postgres, mysql or sqlite
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+
+	// Sqlite database file, empty keeps the database in memory and loses it on restart
+	Path        string        `yaml:"path"`
+	BusyTimeout time.Duration `yaml:"busy_timeout"`  // Wait for a locked sqlite database before failing
+	CacheSizeKB int           `yaml:"cache_size_kb"` // Sqlite page cache size per connection
+
+	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold"` // Queries taking longer are logged as warnings, 0 disables
+}
+
+// Csv import pipeline settings
+type ImportConfig struct {
+	ChunkSize       int           `yaml:"chunk_size"`        // Size of slice of ohcl to save to the db as batches
+	WorkerFileSize  int64         `yaml:"worker_file_size"`  // File size factor for adding a worker to the pool
+	MaxExtraWorkers int           `yaml:"max_extra_workers"` // Max additional workers on top of the CPU count
+	Timeout         time.Duration `yaml:"timeout"`
+	ReadyDelay      time.Duration `yaml:"ready_delay"`   // Keep accepting uploads on shutdown after reporting not ready, until load balancers stop routing here
+	DrainTimeout    time.Duration `yaml:"drain_timeout"` // Wait for running imports on shutdown, then roll them back as interrupted
+}
+
+// Cross origin settings
+type CORSConfig struct {
+	AllowOrigins     []string      `yaml:"allow_origins"`
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+}
+
+// Request authentication settings
+type AuthConfig struct {
+	Enabled bool      `yaml:"enabled"` // Require an api key or token with the route scope, disable only behind a trusted proxy
+	JWT     JWTConfig `yaml:"jwt"`
+}
+
+// Bearer token settings for tokens issued by an OIDC provider, enabled when a JWKS source is set
+type JWTConfig struct {
+	JWKSFile        string              `yaml:"jwks_file"`        // Local JSON web key set
+	JWKSURL         string              `yaml:"jwks_url"`         // Remote JSON web key set e.g https://issuer/.well-known/jwks.json
+	RefreshInterval time.Duration       `yaml:"refresh_interval"` // Time between fetches of the remote key set
+	Issuer          string              `yaml:"issuer"`           // Expected iss claim, not checked if empty
+	Audience        string              `yaml:"audience"`         // Expected aud claim, not checked if empty
+	Leeway          time.Duration       `yaml:"leeway"`           // Allowed clock skew on exp and nbf
+	RolesClaim      string              `yaml:"roles_claim"`      // Claim holding the roles, dotted for nested claims e.g realm_access.roles
+	RoleScopes      map[string][]string `yaml:"role_scopes"`      // Scopes granted to each role
+	TenantClaim     string              `yaml:"tenant_claim"`     // Claim holding the tenant, every token is in the default tenant if empty
+}
+
+// Check a JWKS source is set
+func (jwtConfig JWTConfig) Enabled() bool {
+	return jwtConfig.JWKSFile != "" || jwtConfig.JWKSURL != ""
+}
+
+// Limits of a tenant, 0 is unlimited
+type TenantQuota struct {
+	MaxRows        int64 `yaml:"max_rows"`         // Candles stored by the tenant
+	MaxUploadBytes int64 `yaml:"max_upload_bytes"` // Size of a single csv upload
+}
+
+// Tenants sharing the deployment, each api key or token belongs to one tenant
+type TenantsConfig struct {
+	DefaultQuota TenantQuota            `yaml:"default_quota"` // Quota of the tenants without their own
+	Quotas       map[string]TenantQuota `yaml:"quotas"`        // Quotas by tenant name
+}
+
+// Quota of the tenant
+func (tenants TenantsConfig) Quota(tenant string) TenantQuota {
+	if quota, found := tenants.Quotas[tenant]; found {
+		return quota
+	}
+	return tenants.DefaultQuota
+}
+
+// OpenTelemetry traces exported over OTLP/HTTP, disabled without an endpoint
+type TracingConfig struct {
+	Endpoint    string  `yaml:"endpoint"`     // Collector host:port e.g localhost:4318
+	Insecure    bool    `yaml:"insecure"`     // Export over http instead of https
+	ServiceName string  `yaml:"service_name"` // service.name resource attribute
+	SampleRatio float64 `yaml:"sample_ratio"` // Share of the traces started by the api that are sampled, traces of callers keep their decision
+}
+
+// Request rate and import concurrency limits per client, a client is its api key, token subject or IP
+type LimitsConfig struct {
+	QueryRate        float64       `yaml:"query_rate"`         // GET /data requests per second per client, 0 disables the limit
+	QueryBurst       int           `yaml:"query_burst"`        // Requests a client can send at once before being limited to the rate
+	MaxImports       int           `yaml:"max_imports"`        // Concurrent csv imports of every client, 0 is unlimited
+	MaxClientImports int           `yaml:"max_client_imports"` // Concurrent csv imports of a single client, 0 is unlimited
+	ImportRetryAfter time.Duration `yaml:"import_retry_after"` // Retry-After of a rejected import
+}
+
+// Scheduled downsampling and archival of old candles
+type RetentionConfig struct {
+	Interval   time.Duration     `yaml:"interval"`    // Time between policy runs, 0 disables scheduled runs
+	ArchiveDir string            `yaml:"archive_dir"` // Directory of the compressed csv exports of archived candles
+	WindowRows int               `yaml:"window_rows"` // Candles rolled up and deleted per transaction
+	Policies   []RetentionPolicy `yaml:"policies"`
+}
+
+// Downsample candles older than MaxAge into Resolution buckets, then delete or archive them
+type RetentionPolicy struct {
+	Name       string        `yaml:"name"`
+	Symbol     string        `yaml:"symbol"` // Empty applies to every symbol without its own policy
+	MaxAge     time.Duration `yaml:"max_age"`
+	Resolution time.Duration `yaml:"resolution"`
+	Action     string        `yaml:"action"` // delete or archive
+}
+
+// Supported retention policy actions
+var RetentionActions = []string{"delete", "archive"}
+
+// Scopes that can be granted to roles, matching the auth package scopes
+var Scopes = []string{"data:read", "data:write", "admin"}
+
+// Tenant names, used in archive paths so limited to lower case letters, digits, - and _
+var TenantPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Supported log levels and formats
+var (
+	LogLevels  = []string{"debug", "info", "warn", "error"}
+	LogFormats = []string{"json", "text"}
+)
+
+// Supported database drivers
+var Drivers = []string{"postgres", "mysql", "sqlite"}
+
+// Postgres and mysql accept at most 65535 bind parameters per statement, 7 per ohcl row
+const maxChunkSize = 65535 / 7
+
+// Default settings matching the previous hard coded values
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:            "8080",
+			ReadTimeout:     1 * time.Minute, // Default is 10s, increaased incase of large files
+			WriteTimeout:    3 * time.Minute, // Default is 10s, increaased incase of longer operation
+			RequestTimeout:  3 * time.Minute,
+			ShutdownTimeout: 5 * time.Second,
+			MaxHeaderBytes:  2 << 20,   //2MB
+			MinSpoolFree:    256 << 20, // 256MB
+		},
+		Database: DatabaseConfig{
+			Driver:      "postgres",
+			BusyTimeout: 5 * time.Second,
+			CacheSizeKB: 64 << 10, // 64MB
+
+			SlowQueryThreshold: 500 * time.Millisecond,
+		},
+		Import: ImportConfig{
+			ChunkSize:       4000,
+			WorkerFileSize:  4 << 20, // 4MB
+			MaxExtraWorkers: 20,
+			Timeout:         3 * time.Minute,
+			ReadyDelay:      5 * time.Second,
+			DrainTimeout:    time.Minute,
+		},
+		CORS: CORSConfig{
+			AllowOrigins: []string{"*"},
+			MaxAge:       12 * time.Hour,
+		},
+		Auth: AuthConfig{
+			Enabled: true,
+			JWT: JWTConfig{
+				RefreshInterval: time.Hour,
+				Leeway:          30 * time.Second,
+				RolesClaim:      "roles",
+			},
+		},
+		Retention: RetentionConfig{
+			WindowRows: 10000,
+		},
+		Limits: LimitsConfig{
+			QueryRate:        10,
+			QueryBurst:       20,
+			MaxImports:       4,
+			MaxClientImports: 2,
+			ImportRetryAfter: 30 * time.Second,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: TracingConfig{
+			ServiceName: "csvapi",
+			SampleRatio: 1,
+		},
+		API: APIConfig{
+			LegacyRoutes: true,
+			DeprecatedAt: time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC), // Release of the /v1 routes
+		},
+	}
+}
+
+// Load settings for the command line args (without the program name).
+// Returns the args left after the flags e.g the migrate subcommand.
+func Load(args []string) (*Config, []string, error) {
+	// .env file is optional, the environment may be set by the container
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("loading .env: %w", err)
+	}
+
+	flags := flag.NewFlagSet("csvapi", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	port := flags.String("port", "", "http port to listen on")
+	driver := flags.String("db-driver", "", "database driver, one of "+strings.Join(Drivers, ", "))
+	dbPath := flags.String("db-path", "", "sqlite database file, in memory if empty")
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := Default()
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, nil, err
+	}
+
+	if *port != "" {
+		cfg.Server.Port = *port
+	}
+	if *driver != "" {
+		cfg.Database.Driver = *driver
+	}
+	if *dbPath != "" {
+		cfg.Database.Path = *dbPath
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, flags.Args(), nil
+}
+
+func (cfg *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (cfg *Config) loadEnv() error {
+	var errs []error
+	envString("PORT", &cfg.Server.Port)
+	errs = append(errs,
+		envDuration("SERVER_READ_TIMEOUT", &cfg.Server.ReadTimeout),
+		envDuration("SERVER_WRITE_TIMEOUT", &cfg.Server.WriteTimeout),
+		envDuration("SERVER_REQUEST_TIMEOUT", &cfg.Server.RequestTimeout),
+		envDuration("SERVER_SHUTDOWN_TIMEOUT", &cfg.Server.ShutdownTimeout),
+		envInt("SERVER_MAX_HEADER_BYTES", &cfg.Server.MaxHeaderBytes),
+		envInt64("SERVER_MIN_SPOOL_FREE", &cfg.Server.MinSpoolFree),
+	)
+
+	envString("DB_DRIVER", &cfg.Database.Driver)
+	envString("DB_HOST", &cfg.Database.Host)
+	envString("DB_PORT", &cfg.Database.Port)
+	envString("DB_USER", &cfg.Database.User)
+	envString("DB_PASSWORD", &cfg.Database.Password)
+	envString("DB_NAME", &cfg.Database.Name)
+	envString("DB_PATH", &cfg.Database.Path)
+	errs = append(errs,
+		envDuration("DB_BUSY_TIMEOUT", &cfg.Database.BusyTimeout),
+		envInt("DB_CACHE_SIZE_KB", &cfg.Database.CacheSizeKB),
+		envDuration("DB_SLOW_QUERY_THRESHOLD", &cfg.Database.SlowQueryThreshold),
+	)
+
+	errs = append(errs,
+		envInt("IMPORT_CHUNK_SIZE", &cfg.Import.ChunkSize),
+		envInt64("IMPORT_WORKER_FILE_SIZE", &cfg.Import.WorkerFileSize),
+		envInt("IMPORT_MAX_EXTRA_WORKERS", &cfg.Import.MaxExtraWorkers),
+		envDuration("IMPORT_TIMEOUT", &cfg.Import.Timeout),
+		envDuration("IMPORT_READY_DELAY", &cfg.Import.ReadyDelay),
+		envDuration("IMPORT_DRAIN_TIMEOUT", &cfg.Import.DrainTimeout),
+	)
+
+	if origins, found := os.LookupEnv("CORS_ALLOW_ORIGINS"); found {
+		cfg.CORS.AllowOrigins = strings.Split(origins, ",")
+	}
+	errs = append(errs,
+		envBool("CORS_ALLOW_CREDENTIALS", &cfg.CORS.AllowCredentials),
+		envDuration("CORS_MAX_AGE", &cfg.CORS.MaxAge),
+	)
+
+	errs = append(errs, envDuration("RETENTION_INTERVAL", &cfg.Retention.Interval))
+	envString("RETENTION_ARCHIVE_DIR", &cfg.Retention.ArchiveDir)
+	errs = append(errs, envInt("RETENTION_WINDOW_ROWS", &cfg.Retention.WindowRows))
+
+	errs = append(errs, envBool("AUTH_ENABLED", &cfg.Auth.Enabled))
+	envString("AUTH_JWT_JWKS_FILE", &cfg.Auth.JWT.JWKSFile)
+	envString("AUTH_JWT_JWKS_URL", &cfg.Auth.JWT.JWKSURL)
+	envString("AUTH_JWT_ISSUER", &cfg.Auth.JWT.Issuer)
+	envString("AUTH_JWT_AUDIENCE", &cfg.Auth.JWT.Audience)
+	envString("AUTH_JWT_ROLES_CLAIM", &cfg.Auth.JWT.RolesClaim)
+	envString("AUTH_JWT_TENANT_CLAIM", &cfg.Auth.JWT.TenantClaim)
+	errs = append(errs,
+		envDuration("AUTH_JWT_REFRESH_INTERVAL", &cfg.Auth.JWT.RefreshInterval),
+		envDuration("AUTH_JWT_LEEWAY", &cfg.Auth.JWT.Leeway),
+	)
+
+	errs = append(errs,
+		envInt64("TENANT_MAX_ROWS", &cfg.Tenants.DefaultQuota.MaxRows),
+		envInt64("TENANT_MAX_UPLOAD_BYTES", &cfg.Tenants.DefaultQuota.MaxUploadBytes),
+	)
+
+	errs = append(errs,
+		envFloat("LIMIT_QUERY_RATE", &cfg.Limits.QueryRate),
+		envInt("LIMIT_QUERY_BURST", &cfg.Limits.QueryBurst),
+		envInt("LIMIT_MAX_IMPORTS", &cfg.Limits.MaxImports),
+		envInt("LIMIT_MAX_CLIENT_IMPORTS", &cfg.Limits.MaxClientImports),
+		envDuration("LIMIT_IMPORT_RETRY_AFTER", &cfg.Limits.ImportRetryAfter),
+	)
+
+	envString("LOG_LEVEL", &cfg.Log.Level)
+	envString("LOG_FORMAT", &cfg.Log.Format)
+
+	envString("TRACING_ENDPOINT", &cfg.Tracing.Endpoint)
+	envString("TRACING_SERVICE_NAME", &cfg.Tracing.ServiceName)
+	errs = append(errs,
+		envBool("TRACING_INSECURE", &cfg.Tracing.Insecure),
+		envFloat("TRACING_SAMPLE_RATIO", &cfg.Tracing.SampleRatio),
+	)
+
+	errs = append(errs,
+		envBool("API_LEGACY_ROUTES", &cfg.API.LegacyRoutes),
+		envTime("API_DEPRECATED_AT", &cfg.API.DeprecatedAt),
+		envTime("API_SUNSET", &cfg.API.Sunset),
+	)
+
+	return errors.Join(errs...)
+}
+
+// Check every setting and report all invalid ones at once
+func (cfg *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if port, err := strconv.Atoi(cfg.Server.Port); err != nil || port < 1 || port > 65535 {
+		invalid("server.port must be a number between 1 and 65535, got %q", cfg.Server.Port)
+	}
+	for _, setting := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"server.read_timeout", cfg.Server.ReadTimeout},
+		{"server.write_timeout", cfg.Server.WriteTimeout},
+		{"server.request_timeout", cfg.Server.RequestTimeout},
+		{"server.shutdown_timeout", cfg.Server.ShutdownTimeout},
+		{"import.timeout", cfg.Import.Timeout},
+		{"import.drain_timeout", cfg.Import.DrainTimeout},
+	} {
+		if setting.value <= 0 {
+			invalid("%s must be a positive duration, got %s", setting.name, setting.value)
+		}
+	}
+	if cfg.Server.MaxHeaderBytes <= 0 {
+		invalid("server.max_header_bytes must be positive")
+	}
+	if cfg.Import.ReadyDelay < 0 {
+		invalid("import.ready_delay must not be negative, got %s", cfg.Import.ReadyDelay)
+	}
+	if cfg.Server.MinSpoolFree < 0 {
+		invalid("server.min_spool_free must not be negative")
+	}
+
+	switch cfg.Database.Driver {
+	case "postgres", "mysql":
+		for _, setting := range []struct{ name, value string }{
+			{"database.host", cfg.Database.Host},
+			{"database.port", cfg.Database.Port},
+			{"database.user", cfg.Database.User},
+			{"database.name", cfg.Database.Name},
+		} {
+			if setting.value == "" {
+				invalid("%s is required for the %s driver", setting.name, cfg.Database.Driver)
+			}
+		}
+	case "sqlite":
+		if cfg.Database.Path != "" {
+			if info, err := os.Stat(filepath.Dir(cfg.Database.Path)); err != nil || !info.IsDir() {
+				invalid("database.path directory %q does not exist", filepath.Dir(cfg.Database.Path))
+			}
+		}
+		if cfg.Database.BusyTimeout <= 0 {
+			invalid("database.busy_timeout must be a positive duration, got %s", cfg.Database.BusyTimeout)
+		}
+		if cfg.Database.CacheSizeKB < 0 {
+			invalid("database.cache_size_kb must not be negative")
+		}
+	default:
+		invalid("database.driver must be one of %s, got %q", strings.Join(Drivers, ", "), cfg.Database.Driver)
+	}
+
+	if cfg.Database.SlowQueryThreshold < 0 {
+		invalid("database.slow_query_threshold must not be negative")
+	}
+
+	if cfg.Import.ChunkSize < 1 || cfg.Import.ChunkSize > maxChunkSize {
+		invalid("import.chunk_size must be between 1 and %d, got %d", maxChunkSize, cfg.Import.ChunkSize)
+	}
+	if cfg.Import.WorkerFileSize < 1 {
+		invalid("import.worker_file_size must be positive")
+	}
+	if cfg.Import.MaxExtraWorkers < 0 {
+		invalid("import.max_extra_workers must not be negative")
+	}
+
+	if len(cfg.CORS.AllowOrigins) == 0 {
+		invalid("cors.allow_origins must not be empty")
+	}
+	for _, origin := range cfg.CORS.AllowOrigins {
+		if origin == "*" && cfg.CORS.AllowCredentials {
+			invalid("cors.allow_credentials requires explicit cors.allow_origins, browsers reject credentials with \"*\"")
+		}
+	}
+
+	if jwt := cfg.Auth.JWT; jwt.Enabled() {
+		if jwt.JWKSFile != "" && jwt.JWKSURL != "" {
+			invalid("auth.jwt.jwks_file and auth.jwt.jwks_url are exclusive")
+		}
+		if jwt.JWKSURL != "" && jwt.RefreshInterval < time.Minute {
+			invalid("auth.jwt.refresh_interval must be at least 1m, got %s", jwt.RefreshInterval)
+		}
+		if jwt.Leeway < 0 {
+			invalid("auth.jwt.leeway must not be negative")
+		}
+		if jwt.RolesClaim == "" {
+			invalid("auth.jwt.roles_claim is required")
+		}
+		if len(jwt.RoleScopes) == 0 {
+			invalid("auth.jwt.role_scopes must map at least one role to its scopes")
+		}
+		roles := make([]string, 0, len(jwt.RoleScopes))
+		for role := range jwt.RoleScopes {
+			roles = append(roles, role)
+		}
+		sort.Strings(roles) // report in a stable order
+		for _, role := range roles {
+			for _, scope := range jwt.RoleScopes[role] {
+				if !containsString(Scopes, scope) {
+					invalid("auth.jwt.role_scopes.%s scope must be one of %s, got %q", role, strings.Join(Scopes, ", "), scope)
+				}
+			}
+		}
+	}
+
+	if quota := cfg.Tenants.DefaultQuota; quota.MaxRows < 0 || quota.MaxUploadBytes < 0 {
+		invalid("tenants.default_quota limits must not be negative")
+	}
+	tenants := make([]string, 0, len(cfg.Tenants.Quotas))
+	for tenant := range cfg.Tenants.Quotas {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants) // report in a stable order
+	for _, tenant := range tenants {
+		if !TenantPattern.MatchString(tenant) {
+			invalid("tenants.quotas tenant %q must match %s", tenant, TenantPattern)
+		}
+		if quota := cfg.Tenants.Quotas[tenant]; quota.MaxRows < 0 || quota.MaxUploadBytes < 0 {
+			invalid("tenants.quotas.%s limits must not be negative", tenant)
+		}
+	}
+
+	if limits := cfg.Limits; limits.QueryRate < 0 {
+		invalid("limits.query_rate must not be negative")
+	} else if limits.QueryRate > 0 && limits.QueryBurst < 1 {
+		invalid("limits.query_burst must be positive with a query_rate")
+	}
+	if cfg.Limits.MaxImports < 0 || cfg.Limits.MaxClientImports < 0 {
+		invalid("limits.max_imports and limits.max_client_imports must not be negative")
+	}
+	if cfg.Limits.ImportRetryAfter < time.Second {
+		invalid("limits.import_retry_after must be at least 1s, got %s", cfg.Limits.ImportRetryAfter)
+	}
+
+	if !containsString(LogLevels, strings.ToLower(cfg.Log.Level)) {
+		invalid("log.level must be one of %s, got %q", strings.Join(LogLevels, ", "), cfg.Log.Level)
+	}
+	if !containsString(LogFormats, cfg.Log.Format) {
+		invalid("log.format must be one of %s, got %q", strings.Join(LogFormats, ", "), cfg.Log.Format)
+	}
+
+	if cfg.Tracing.Endpoint != "" && cfg.Tracing.ServiceName == "" {
+		invalid("tracing.service_name is required with a tracing.endpoint")
+	}
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		invalid("tracing.sample_ratio must be between 0 and 1, got %v", cfg.Tracing.SampleRatio)
+	}
+
+	if cfg.API.LegacyRoutes && cfg.API.DeprecatedAt.IsZero() {
+		invalid("api.deprecated_at is required with api.legacy_routes")
+	}
+	if !cfg.API.Sunset.IsZero() && !cfg.API.Sunset.After(cfg.API.DeprecatedAt) {
+		invalid("api.sunset must be after api.deprecated_at, got %s", cfg.API.Sunset.Format(time.RFC3339))
+	}
+
+	if cfg.Retention.Interval < 0 {
+		invalid("retention.interval must not be negative")
+	}
+	if cfg.Retention.WindowRows < 1 {
+		invalid("retention.window_rows must be positive")
+	}
+	names, symbols := map[string]bool{}, map[string]bool{}
+	for i, policy := range cfg.Retention.Policies {
+		if policy.Name == "" || names[policy.Name] {
+			invalid("retention.policies[%d].name must be set and unique, got %q", i, policy.Name)
+		}
+		names[policy.Name] = true
+		if symbols[policy.Symbol] {
+			invalid("retention.policies[%d].symbol %q already has a policy", i, policy.Symbol)
+		}
+		symbols[policy.Symbol] = true
+		if policy.MaxAge <= 0 {
+			invalid("retention.policies[%d].max_age must be a positive duration", i)
+		}
+		if policy.Resolution < time.Second || policy.Resolution > policy.MaxAge {
+			invalid("retention.policies[%d].resolution must be between 1s and max_age, got %s", i, policy.Resolution)
+		}
+		switch policy.Action {
+		case "delete":
+		case "archive":
+			if cfg.Retention.ArchiveDir == "" {
+				invalid("retention.archive_dir is required by the archive action of policy %q", policy.Name)
+			}
+		default:
+			invalid("retention.policies[%d].action must be one of %s, got %q", i, strings.Join(RetentionActions, ", "), policy.Action)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+func envString(key string, target *string) {
+	if value, found := os.LookupEnv(key); found && value != "" {
+		*target = value
+	}
+}
+
+func envInt(key string, target *int) error {
+	value, found := os.LookupEnv(key)
+	if !found || value == "" {
+		return nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%s must be an integer, got %q", key, value)
+	}
+	*target = parsed
+	return nil
+}
+
+func envInt64(key string, target *int64) error {
+	value, found := os.LookupEnv(key)
+	if !found || value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%s must be an integer, got %q", key, value)
+	}
+	*target = parsed
+	return nil
+}
+
+func envFloat(key string, target *float64) error {
+	value, found := os.LookupEnv(key)
+	if !found || value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("%s must be a number, got %q", key, value)
+	}
+	*target = parsed
+	return nil
+}
+
+func envBool(key string, target *bool) error {
+	value, found := os.LookupEnv(key)
+	if !found || value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("%s must be a boolean, got %q", key, value)
+	}
+	*target = parsed
+	return nil
+}
+
+func envDuration(key string, target *time.Duration) error {
+	value, found := os.LookupEnv(key)
+	if !found || value == "" {
+		return nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%s must be a duration e.g 3m, got %q", key, value)
+	}
+	*target = parsed
+	return nil
+}
+
+// Date e.g 2027-01-31, or RFC 3339 time
+func envTime(key string, target *time.Time) error {
+	value, found := os.LookupEnv(key)
+	if !found || value == "" {
+		return nil
+	}
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			*target = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("%s must be a date e.g 2027-01-31 or an RFC 3339 time, got %q", key, value)
+}
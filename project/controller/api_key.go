@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"csvapi-test/auth"
+	"csvapi-test/repository"
+	"csvapi-test/services"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Admin handlers for the api keys of the clients
+type APIKeyHandler struct {
+	keys repository.APIKeyStore
+}
+
+func NewAPIKeyHandler(keys repository.APIKeyStore) *APIKeyHandler {
+	return &APIKeyHandler{keys: keys}
+}
+
+// Payload of a new api key
+type createAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required" doc:"data:read, data:write or admin"`
+}
+
+// Create an api key, the key is only returned in this response
+func (handler *APIKeyHandler) Create(c *gin.Context) {
+	var payload createAPIKeyRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		services.AbortWithRequestError(c, &payload, err)
+		return
+	}
+	valid := len(payload.Scopes) > 0
+	for _, scope := range payload.Scopes {
+		valid = valid && auth.ValidScope(scope)
+	}
+	if !valid {
+		problem := services.NewProblem(c, services.CodeValidationFailed, "The request payload is invalid")
+		problem.Errors = []services.FieldError{{
+			Field:   "scopes",
+			Rule:    "oneof",
+			Message: "scopes must be a non empty list of " + strings.Join(auth.Scopes, ", "),
+		}}
+		problem.Abort(c)
+		return
+	}
+
+	apiKey, key, err := handler.keys.Create(c.Request.Context(), payload.Name, payload.Scopes)
+	if err != nil {
+		services.ServerErrror(c, services.CodeInternal, err)
+		return
+	}
+
+	response := gin.H{
+		"status":  "success",
+		"message": "Api key created, store the key now as it is not shown again",
+		"data":    apiKey,
+		"key":     key,
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// List every api key with its last use, keys themselves are never returned
+func (handler *APIKeyHandler) List(c *gin.Context) {
+	apiKeys, err := handler.keys.List(c.Request.Context())
+	if err != nil {
+		services.ServerErrror(c, services.CodeInternal, err)
+		return
+	}
+
+	response := gin.H{
+		"status":  "success",
+		"message": "Api keys successfully fetched",
+		"data":    apiKeys,
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Revoke an api key, requests with it are rejected from now on
+func (handler *APIKeyHandler) Revoke(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		services.ErrorResponse(c, services.CodeInvalidParameter, "id must be a positive integer")
+		return
+	}
+
+	apiKey, err := handler.keys.Revoke(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		services.ErrorResponse(c, services.CodeNotFound, err.Error())
+		return
+	} else if err != nil {
+		services.ServerErrror(c, services.CodeInternal, err)
+		return
+	}
+
+	response := gin.H{
+		"status":  "success",
+		"message": "Api key revoked",
+		"data":    apiKey,
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"csvapi-test/repository"
+	"csvapi-test/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Admin handlers for the audit log
+type AuditHandler struct {
+	store repository.AuditStore
+}
+
+func NewAuditHandler(store repository.AuditStore) *AuditHandler {
+	return &AuditHandler{store: store}
+}
+
+// Filters of the audit entries, the creation time range is in RFC 3339 e.g since=2023-04-01T00:00:00Z
+type auditQuery struct {
+	Action    string    `form:"action" doc:"e.g candle.update"`
+	Actor     string    `form:"actor"`
+	Target    string    `form:"target" doc:"Prefix of the mutated resource e.g ohcls/42"`
+	RequestID string    `form:"request_id"`
+	Since     time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00" doc:"RFC 3339 creation time, inclusive"`
+	Until     time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00" binding:"omitempty,gtfield=Since" doc:"RFC 3339 creation time, exclusive"`
+}
+
+// List audit entries latest first, filtered by action, actor, target prefix, request id and creation time
+func (handler *AuditHandler) List(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params auditQuery
+	if err := c.ShouldBindQuery(&params); err != nil {
+		services.AbortWithQueryError(c, &params, err)
+		return
+	}
+	query := repository.AuditQuery{
+		Action:    params.Action,
+		Actor:     params.Actor,
+		Target:    params.Target,
+		RequestID: params.RequestID,
+		Since:     params.Since,
+		Until:     params.Until,
+	}
+
+	paginationQueries := &services.PaginationParams{}
+	paginationQueries.ParseQuery(c)
+
+	total, err := handler.store.Count(ctx, query)
+	if err != nil {
+		services.ServerErrror(c, services.CodeInternal, err)
+		return
+	}
+	pagination, err := services.Paginate(c, *paginationQueries, int(total))
+	if err != nil {
+		services.ServerErrror(c, services.CodeInternal, err)
+		return
+	}
+
+	query.Limit = paginationQueries.Limit
+	query.Offset = paginationQueries.Offset
+	entries, err := handler.store.List(ctx, query)
+	if err != nil {
+		services.ServerErrror(c, services.CodeInternal, err)
+		return
+	}
+
+	response := gin.H{
+		"status":     "success",
+		"message":    "Audit entries successfully fetched",
+		"data":       entries,
+		"pagination": pagination,
+	}
+	c.JSON(http.StatusOK, response)
+}
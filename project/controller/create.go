@@ -3,11 +3,20 @@ package controller
 import (
 	"bufio"
 	"context"
+	"csvapi-test/audit"
+	"csvapi-test/auth"
+	"csvapi-test/drain"
+	"csvapi-test/metrics"
 	"csvapi-test/model"
+	"csvapi-test/repository"
 	"csvapi-test/services"
+	"csvapi-test/tracing"
+	"csvapi-test/validation"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"math"
 	"net/http"
 	"path/filepath"
@@ -18,30 +27,46 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
-)
-
-const (
-	chunkVolume = 4000    // Size of slice of ohcl to save to the db as batches
-	MB4         = 4 << 20 // File size factor for worker pool
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Object holding csv rows in chunks and channel flow for saving the chunks in batches
 type ProcessPool struct {
 	chunk           []model.Ohcl
+	chunkVolume     int // Size of slice of ohcl to save to the db as batches
 	dataChan        chan []model.Ohcl
+	failed          chan struct{} // Closed on the first failed batch insertion, stops the csv reading
+	failOnce        sync.Once
 	wg              *sync.WaitGroup
 	numWorkers      int //Number of worker to process db insertion from dbChannel
 	errorMessage    string
-	csvLinesRead    int // Total number of lines read
+	err             error // Error of the failed batch insertion, if any
+	csvLinesRead    int   // Total number of lines read
+	rowsRead        int   // Csv records parsed, including the ones not sent after a failure
+	chunksSent      int
+	sendWait        time.Duration // Time the reading was blocked on a full db channel, by busy workers
 	totalChunkSaved int
-	done            bool // Checks if every lines has been saved
+	done            bool   // Checks if every lines has been saved
+	locale          string // Language of the invalid row messages
 	mutex           sync.Mutex
 }
 
-// Read through the entire csv rows and append them in chunks into the db channel
-func (processPool *ProcessPool) generateCsvChunk(csvReader *csv.Reader) {
+// Read through the entire csv rows and append them in chunks into the db channel.
+// The db channel is closed once reading stops, so the workers finish the sent chunks and exit.
+func (processPool *ProcessPool) generateCsvChunk(ctx context.Context, csvReader *csv.Reader) {
+	defer close(processPool.dataChan) // close data channel
+
+	_, span := tracing.Start(ctx, "csv.read")
+	defer func() {
+		span.SetAttributes(
+			attribute.Int("csv.rows", processPool.rowsRead),
+			attribute.Int("csv.chunks", processPool.chunksSent),
+			attribute.Int64("csv.send_wait_ms", processPool.sendWait.Milliseconds()),
+		)
+		span.End()
+	}()
+
 	// Read csv so far there's no error saving or reading into the chunks
 	for {
 		// Read the line of csv reader
@@ -50,104 +75,192 @@ func (processPool *ProcessPool) generateCsvChunk(csvReader *csv.Reader) {
 			if err == io.EOF {
 				break
 			}
-			processPool.errorMessage = err.Error()
-			close(processPool.dataChan) // close data channel
-			break
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				err = &RowError{Line: parseErr.Line, Message: parseErr.Err.Error()}
+			}
+			processPool.setError(err, err.Error())
+			return
 		}
+		processPool.rowsRead++
+		line, _ := csvReader.FieldPos(0)
 		// Check and valid number of columns, strictly based on the expected data
-		if len(row) != 6 {
-			processPool.errorMessage = "Invalid row detected"
+		if len(row) != len(expectedHeader) {
+			message := validation.RowMessage(processPool.locale, "columns", "", strconv.Itoa(len(expectedHeader)))
+			processPool.setError(&RowError{Line: line, Message: message}, "Invalid row detected")
 			processPool.chunk = make([]model.Ohcl, 0) // empty the chunk
-			close(processPool.dataChan)               // close data channel
-			break
+			return
 		}
 
 		// Convert row to Ohcl
-		uix, err := strconv.ParseUint(row[0], 10, 64)
-		symbol := row[1]
-		open, err := strconv.ParseFloat(row[2], 32)
-		high, err := strconv.ParseFloat(row[3], 32)
-		low, err := strconv.ParseFloat(row[4], 32)
-		close, err := strconv.ParseFloat(row[5], 32)
-
-		ohlc := model.Ohcl{
-			UNIX:   uix,
-			SYMBOL: symbol,
-			OPEN:   float32(open),
-			HIGH:   float32(high),
-			LOW:    float32(low),
-			CLOSE:  float32(close),
+		ohlc, err := parseRow(row, processPool.locale)
+		if err != nil {
+			rowErr := &RowError{Line: line, Message: err.Error()}
+			processPool.setError(rowErr, rowErr.Error())
+			return
 		}
 		processPool.chunk = append(processPool.chunk, ohlc)
 		// check if the lenght of the rows equal to chunkVolume then send it to db channel
-		if len(processPool.chunk) == chunkVolume {
-			processPool.csvLinesRead += chunkVolume
-			processPool.dataChan <- processPool.chunk
-			// empty the chunk
-			processPool.chunk = make([]model.Ohcl, 0)
+		if len(processPool.chunk) == processPool.chunkVolume {
+			if !processPool.send(ctx) {
+				return
+			}
 		}
 	}
 	// check for remant of rows if not up to and checked by chunkVolume
 	if len(processPool.chunk) > 0 {
-		processPool.csvLinesRead += len(processPool.chunk)
-		if processPool.errorMessage == "" {
-			processPool.dataChan <- processPool.chunk
+		processPool.send(ctx)
+	}
+}
+
+// Invalid csv row, the import is rejected as a client error
+type RowError struct {
+	Line    int    `json:"line"` // Line of the row in the file, the header is line 1
+	Message string `json:"message"`
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// Convert the UNIX,SYMBOL,OPEN,HIGH,LOW,CLOSE columns of the row to Ohcl, with the errors in the locale
+func parseRow(row []string, locale string) (ohlc model.Ohcl, err error) {
+	ohlc.SYMBOL = row[1]
+	if ohlc.UNIX, err = strconv.ParseUint(row[0], 10, 64); err != nil {
+		return ohlc, errors.New(validation.RowMessage(locale, "unix", expectedHeader[0], strconv.Quote(row[0])))
+	}
+	for i, price := range []*float32{&ohlc.OPEN, &ohlc.HIGH, &ohlc.LOW, &ohlc.CLOSE} {
+		value, err := strconv.ParseFloat(row[i+2], 32)
+		if err != nil {
+			return ohlc, errors.New(validation.RowMessage(locale, "number", expectedHeader[i+2], strconv.Quote(row[i+2])))
 		}
+		*price = float32(value)
+	}
+	return ohlc, nil
+}
+
+// Send the chunk to the db channel, false if a worker failed or the import was cancelled and the reading must stop
+func (processPool *ProcessPool) send(ctx context.Context) bool {
+	metrics.ImportChannelDepth.Inc() // before the send, so the worker receiving it never takes the depth below 0
+	start := time.Now()
+	defer func() { processPool.sendWait += time.Since(start) }()
+	select {
+	case processPool.dataChan <- processPool.chunk:
+		processPool.chunksSent++
+		processPool.mutex.Lock()
+		processPool.csvLinesRead += len(processPool.chunk)
+		processPool.mutex.Unlock()
+		// empty the chunk
+		processPool.chunk = make([]model.Ohcl, 0, processPool.chunkVolume)
+		return true
+	case <-processPool.failed:
+		metrics.ImportChannelDepth.Dec()
+		return false
+	case <-ctx.Done():
+		metrics.ImportChannelDepth.Dec()
+		processPool.setError(context.Cause(ctx), context.Cause(ctx).Error())
+		return false
 	}
 }
 
-// Immplement worker pool to save csv chunks into the db
-func (processPool *ProcessPool) processCsvChunk(db *gorm.DB) {
+// Record the first error of the import and stop the csv reading
+func (processPool *ProcessPool) setError(err error, message string) {
+	processPool.failOnce.Do(func() {
+		processPool.err = err
+		processPool.errorMessage = message
+		close(processPool.failed)
+	})
+}
 
+// Immplement worker pool to save csv chunks into the db, each chunk insert is traced as a child span of ctx
+func (processPool *ProcessPool) processCsvChunk(ctx context.Context, candleImport repository.CandleImport) {
+	metrics.ImportWorkers.Add(float64(processPool.numWorkers))
 	for i := 0; i < processPool.numWorkers; i++ {
-		go func() {
+		go func(worker int) {
 			defer processPool.wg.Done()
+			defer metrics.ImportWorkers.Dec()
 			for rows := range processPool.dataChan {
-				processPool.mutex.Lock()
-				if err := db.Create(rows).Error; err != nil {
-					processPool.errorMessage = err.Error()
-					close(processPool.dataChan) // close data channel
-					processPool.mutex.Unlock()
-					return
+				metrics.ImportChannelDepth.Dec()
+				select {
+				case <-processPool.failed:
+					continue // drain the chunks sent before the failure, the import is rolled back
+				default:
 				}
-				processPool.mutex.Unlock()
-
-				// check if all the scv lines has been saved
+				_, span := tracing.Start(ctx, "csv.insert_chunk", attribute.Int("csv.rows", len(rows)), attribute.Int("csv.worker", worker))
 				processPool.mutex.Lock()
-				processPool.totalChunkSaved += len(rows)
-				if processPool.csvLinesRead == processPool.totalChunkSaved {
-					processPool.done = true
-					close(processPool.dataChan) //close data channel
+				span.AddEvent("batch lock acquired")
+				metrics.ImportWorkersBusy.Inc()
+				start := time.Now()
+				err := candleImport.InsertBatch(rows)
+				metrics.ImportWorkerBusySeconds.Add(time.Since(start).Seconds())
+				metrics.ImportWorkersBusy.Dec()
+				if err != nil {
+					processPool.setError(err, err.Error())
 					processPool.mutex.Unlock()
-					return // All csv lines are saved
+					tracing.Fail(span, err)
+					span.End()
+					continue
 				}
+				processPool.totalChunkSaved += len(rows)
 				processPool.mutex.Unlock()
-
+				span.End()
 			}
-		}()
+		}(i)
 	}
 }
 
-func Create(c *gin.Context) {
+// Csv upload handler, saves every row of the csv_file form file in a single import
+func (handler *CandleHandler) Create(c *gin.Context) {
+	importConfig := handler.importConfig
+
+	// Register the import so the shutdown waits for it, and interrupts it past the drain timeout
+	ctx, finished, err := handler.imports.Begin(c.Request.Context())
+	if errors.Is(err, drain.ErrShuttingDown) {
+		services.RetryableError(c, services.CodeShuttingDown, handler.retryAfter, "The server is shutting down, retry the upload")
+		return
+	}
+	defer finished()
+
+	// Reported running by the status route until the import is committed or rolled back
+	defer handler.running.add(auth.Tenant(ctx), audit.FromContext(ctx).RequestID)()
+
 	// Increase the context timeout incase of a very large csv file to.
-	_, cancel := context.WithTimeout(c.Request.Context(), 3*time.Minute)
+	ctx, cancel := context.WithTimeout(ctx, importConfig.Timeout)
 	defer cancel()
 
+	// Stop reading the body past the upload quota, the form is saved to memory and temp files before its size is known
+	maxSize := handler.tenants.Quota(auth.Tenant(ctx)).MaxUploadBytes
+	if maxSize > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+multipartOverhead)
+	}
+	tooLarge := func() {
+		services.ErrorResponse(c, services.CodeUploadTooLarge, fmt.Sprintf("Csv file exceeds the upload quota of %d bytes", maxSize))
+	}
+
 	file, err := c.FormFile("csv_file")
-	if err != nil {
-		services.BadRequestErrror(c, err, ": Form cannot be parsed")
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		tooLarge()
+		return
+	} else if err != nil {
+		services.ErrorResponse(c, services.CodeCsvInvalidForm, "Form cannot be parsed: "+err.Error())
 		return
 	}
 
 	if strings.ToLower(filepath.Ext(file.Filename)) != ".csv" {
-		services.BadRequestErrror(c, nil, "Expected a csv file")
+		services.ErrorResponse(c, services.CodeCsvInvalidFile, "Expected a csv file")
+		return
+	}
+
+	if maxSize > 0 && file.Size > maxSize {
+		tooLarge()
 		return
 	}
 
 	// Get an io.Reader for the file contents using file.Open()
 	fileContent, err := file.Open()
 	if err != nil {
-		services.ServerErrror(c, err, "")
+		services.ServerErrror(c, services.CodeInternal, err)
 		return
 	}
 	defer fileContent.Close()
@@ -158,64 +271,118 @@ func Create(c *gin.Context) {
 
 	// Read first row to remove the header
 	header, err := csvReader.Read()
-	if err != nil {
-		services.ServerErrror(c, err, ":occurs when reading the file header")
+	var parseErr *csv.ParseError
+	if err == io.EOF {
+		services.ErrorResponse(c, services.CodeCsvInvalidHeader, "Csv file is empty")
+		return
+	} else if errors.As(err, &parseErr) {
+		services.ErrorResponse(c, services.CodeCsvInvalidHeader, err.Error())
+		return
+	} else if err != nil {
+		services.ServerErrror(c, services.CodeInternal, err)
 		return
 	} else if !validateSCVHeader(header) {
-		services.BadRequestErrror(c, nil, "Csv file is not valid")
+		services.ErrorResponse(c, services.CodeCsvInvalidHeader, "Csv header must be "+strings.Join(expectedHeader, ","))
 		return
 	}
 
-	// Set max of 20 additional workers for big files
-	ff := file.Size / MB4
-	worKerFactor := math.Min(float64(ff), 20)
+	// Set max of MaxExtraWorkers additional workers for big files
+	ff := file.Size / importConfig.WorkerFileSize
+	worKerFactor := math.Min(float64(ff), float64(importConfig.MaxExtraWorkers))
 
 	// number of workers for worker pool from system CPU available
 	numWorkers := runtime.NumCPU() + int(worKerFactor)
 
-	var (
-		db = model.DB     // Database instance
-		wg sync.WaitGroup // wait group syncer for workpool
+	var wg sync.WaitGroup // wait group syncer for workpool
+
+	// Trace the import stages: the reading, each chunk insert and their queries
+	ctx, span := tracing.Start(ctx, "csv.import",
+		attribute.String("csv.file", file.Filename),
+		attribute.Int64("csv.bytes", file.Size),
+		attribute.Int("csv.workers", numWorkers),
+		attribute.Int("csv.chunk_size", importConfig.ChunkSize),
 	)
+	defer span.End()
 
-	// Disable logging and default transaction for the database session
-	db = db.Session(&gorm.Session{
-		Logger:                 logger.Default.LogMode(logger.Silent),
-		SkipDefaultTransaction: true, //disable default transaction to help speed
-	})
+	// Import stopped by the shutdown, whatever stage it failed in
+	interrupted := func() bool { return errors.Is(context.Cause(ctx), drain.ErrInterrupted) }
+	if interrupted() {
+		// Interrupted while the upload was received, nothing was written yet
+		tracing.Fail(span, drain.ErrInterrupted)
+		services.RetryableError(c, services.CodeImportInterrupted, handler.retryAfter, "The import was interrupted by a server shutdown, retry the upload")
+		return
+	}
 
-	tx := db.Begin() // Perform the saving with explicit transaction to enable rollback
+	// Perform the saving within a single import to enable rollback
+	candleImport, err := handler.store.BeginImport(ctx)
+	if err != nil {
+		tracing.Fail(span, err)
+		services.ServerErrror(c, services.CodeImportFailed, err)
+		return
+	}
+
+	start := time.Now()
+	metrics.ImportBytes.Add(float64(file.Size))
+	slog.InfoContext(ctx, "import started", slog.String("file", file.Filename), slog.Int64("bytes", file.Size),
+		slog.Int("workers", numWorkers), slog.String("tenant", auth.Tenant(ctx)))
 
 	wg.Add(numWorkers)
 	processPool := ProcessPool{
-		wg:         &wg,
-		chunk:      make([]model.Ohcl, 0, chunkVolume),
-		dataChan:   make(chan []model.Ohcl, numWorkers),
-		numWorkers: numWorkers,
+		wg:          &wg,
+		chunk:       make([]model.Ohcl, 0, importConfig.ChunkSize),
+		chunkVolume: importConfig.ChunkSize,
+		dataChan:    make(chan []model.Ohcl, numWorkers),
+		failed:      make(chan struct{}),
+		numWorkers:  numWorkers,
+		locale:      validation.Locale(c.GetHeader("Accept-Language")),
 	}
 
-	// go processPool.processCsvChunk(db) //Use worker pool to save csv in chunks
-	go processPool.processCsvChunk(tx)      //Use worker pool to save csv in chunks
-	processPool.generateCsvChunk(csvReader) // Read word scv rows into chunks
+	go processPool.processCsvChunk(ctx, candleImport) //Use worker pool to save csv in chunks
+	processPool.generateCsvChunk(ctx, csvReader)      // Read word scv rows into chunks
 
 	// lock flow until all workers are done
 	wg.Wait()
+	processPool.done = processPool.totalChunkSaved == processPool.csvLinesRead // check if all the scv lines has been saved
 
-	// Check if theres no error for worker pool and commit transaction
-	if processPool.errorMessage == "" && processPool.done {
-		if err := tx.Commit().Error; err != nil {
-			services.ServerErrror(c, err, "")
+	// Check if theres no error for worker pool and commit the import
+	if processPool.errorMessage == "" && processPool.done && !interrupted() {
+		summary := model.ImportSummary{
+			FileName: file.Filename,
+			FileSize: file.Size,
+			Rows:     processPool.totalChunkSaved,
+		}
+		if err := candleImport.Commit(summary); err != nil {
+			if interrupted() {
+				handler.interrupt(c, ctx, candleImport, file.Filename, file.Size, &processPool, start)
+				return
+			}
+			processPool.finish(ctx, start, "rolled_back", err)
+			services.ServerErrror(c, services.CodeImportFailed, err)
 			return
 		}
+		processPool.finish(ctx, start, "committed", nil)
+	} else if interrupted() {
+		handler.interrupt(c, ctx, candleImport, file.Filename, file.Size, &processPool, start)
+		return
 	} else {
-		tx.Rollback() // rollback the transaction
-		services.ServerErrror(c, nil, processPool.errorMessage)
+		candleImport.Rollback() // rollback the import
+		processPool.finish(ctx, start, "rolled_back", processPool.importError())
+		var rowErr *RowError
+		if errors.Is(processPool.err, repository.ErrQuotaExceeded) {
+			services.ErrorResponse(c, services.CodeQuotaExceeded, processPool.err.Error())
+		} else if errors.As(processPool.err, &rowErr) {
+			problem := services.NewProblem(c, services.CodeRowParseError, rowErr.Error())
+			problem.Details = rowErr
+			problem.Abort(c)
+		} else {
+			services.ServerErrror(c, services.CodeImportFailed, processPool.importError())
+		}
 		return
 	}
 
-	data := map[string]string{
-		"csvLinesRead":   fmt.Sprintf("%d", processPool.csvLinesRead),
-		"totalSavedRows": fmt.Sprintf("%d", processPool.totalChunkSaved),
+	data := model.ImportResult{
+		CSVLinesRead:   fmt.Sprintf("%d", processPool.csvLinesRead),
+		TotalSavedRows: fmt.Sprintf("%d", processPool.totalChunkSaved),
 	}
 	response := gin.H{
 		"status": "success",
@@ -224,14 +391,80 @@ func Create(c *gin.Context) {
 	c.JSON(http.StatusCreated, response)
 }
 
+// Roll back the import interrupted by the shutdown, audit it and ask the client to retry the upload
+func (handler *CandleHandler) interrupt(c *gin.Context, ctx context.Context, candleImport repository.CandleImport,
+	fileName string, fileSize int64, processPool *ProcessPool, start time.Time) {
+	summary := model.ImportSummary{FileName: fileName, FileSize: fileSize, Rows: processPool.rowsRead}
+	if err := candleImport.Interrupt(summary); err != nil {
+		slog.ErrorContext(ctx, "auditing the interrupted import failed", slog.String("error", err.Error()))
+	}
+	processPool.finish(ctx, start, "interrupted", drain.ErrInterrupted)
+	services.RetryableError(c, services.CodeImportInterrupted, handler.retryAfter, "The import was interrupted by a server shutdown and rolled back, retry the upload")
+}
+
+// Record the metrics, the import span attributes and the summary log of the finished import,
+// result is committed, rolled_back or interrupted
+func (processPool *ProcessPool) finish(ctx context.Context, start time.Time, result string, err error) {
+	duration := time.Since(start)
+	metrics.Imports.WithLabelValues(result).Inc()
+	metrics.ImportDuration.WithLabelValues(result).Observe(duration.Seconds())
+	metrics.ImportRowsRead.Add(float64(processPool.rowsRead))
+	saved := 0 // inserted rows of a rolled back import are not saved
+	if result == "committed" {
+		saved = processPool.totalChunkSaved
+		metrics.ImportRowsSaved.Add(float64(saved))
+	} else {
+		metrics.ImportRowsRejected.Add(float64(processPool.rowsRead))
+	}
+
+	attrs := []slog.Attr{
+		slog.String("result", result),
+		slog.Int("rows_read", processPool.rowsRead),
+		slog.Int("rows_saved", saved),
+		slog.Duration("duration", duration),
+	}
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(
+		attribute.String("csv.result", result),
+		attribute.Int("csv.rows_read", processPool.rowsRead),
+		attribute.Int("csv.rows_saved", saved),
+	)
+	tracing.Fail(span, err)
+
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+		slog.Default().LogAttrs(ctx, slog.LevelWarn, "import finished", attrs...)
+		return
+	}
+	slog.Default().LogAttrs(ctx, slog.LevelInfo, "import finished", attrs...)
+}
+
+// Error of the failed import, the batch insertion error or the csv reading error message
+func (processPool *ProcessPool) importError() error {
+	if processPool.err != nil {
+		return processPool.err
+	}
+	if processPool.errorMessage != "" {
+		return errors.New(processPool.errorMessage)
+	}
+	return errors.New("not every row was saved")
+}
+
+// Bytes of the multipart form around the csv file allowed on top of the upload quota, for its headers and boundaries
+const multipartOverhead = 64 << 10
+
+// Columns of the csv files, in order
+var expectedHeader = []string{"UNIX", "SYMBOL", "OPEN", "HIGH", "LOW", "CLOSE"}
+
 func validateSCVHeader(header []string) (valid bool) {
-	expectedHeader := []string{"UNIX", "SYMBOL", "OPEN", "HIGH", "LOW", "CLOSE"}
-	valid = true
+	if len(header) != len(expectedHeader) {
+		return false
+	}
 	for index, value := range expectedHeader {
 		if value != strings.ToUpper(header[index]) {
-			valid = false
-			break
+			return false
 		}
 	}
-	return valid && len(header) == len(expectedHeader)
+	return true
 }
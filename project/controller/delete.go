@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"csvapi-test/repository"
+	"csvapi-test/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Query of DELETE /data, every field is required so a whole symbol or table is never deleted by mistake.
+// From is a pointer so required only rejects a missing from, from=0 is a valid start.
+type deleteRangeQuery struct {
+	Symbol string  `form:"symbol" json:"symbol" binding:"required,symbol"`
+	From   *uint64 `form:"from" json:"from" binding:"required" doc:"Start of the range in unix milliseconds, inclusive"`
+	To     uint64  `form:"to" json:"to" binding:"required,gtfield=From" doc:"End of the range in unix milliseconds, exclusive"`
+}
+
+// Delete a single candle
+func (handler *CandleHandler) Delete(c *gin.Context) {
+	id, ok := candleID(c)
+	if !ok {
+		return
+	}
+
+	candle, err := handler.store.Delete(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrCandleNotFound) {
+		services.ErrorResponse(c, services.CodeNotFound, err.Error())
+		return
+	} else if err != nil {
+		services.ServerErrror(c, services.CodeInternal, err)
+		return
+	}
+
+	response := gin.H{
+		"status":  "success",
+		"message": "Data successfully deleted",
+		"data":    candle,
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Delete every candle of a symbol within the from (inclusive) and to (exclusive) unix milliseconds range
+func (handler *CandleHandler) DeleteRange(c *gin.Context) {
+	var query deleteRangeQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		services.AbortWithQueryError(c, &query, err)
+		return
+	}
+
+	deleted, err := handler.store.DeleteRange(c.Request.Context(), repository.CandleRange{
+		Symbol: query.Symbol,
+		From:   *query.From,
+		To:     query.To,
+	})
+	if err != nil {
+		services.ServerErrror(c, services.CodeInternal, err)
+		return
+	}
+
+	response := gin.H{
+		"status":  "success",
+		"message": "Data successfully deleted",
+		"data":    gin.H{"deletedRows": deleted},
+	}
+	c.JSON(http.StatusOK, response)
+}
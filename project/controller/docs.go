@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"csvapi-test/openapi"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Version of the swagger-ui-dist package the docs page loads from the CDN
+const swaggerUIVersion = "5.9.0"
+
+// Swagger UI page of the OpenAPI document served next to it
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>CSV API docs</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+// OpenAPI document of the routes and its interactive docs
+type DocsHandler struct {
+	doc *openapi.Document
+}
+
+func NewDocsHandler(doc *openapi.Document) *DocsHandler {
+	return &DocsHandler{doc: doc}
+}
+
+// OpenAPI 3 document, built once by the router
+func (handler *DocsHandler) Spec(c *gin.Context) {
+	c.JSON(http.StatusOK, handler.doc)
+}
+
+// Swagger UI of the OpenAPI document
+func (handler *DocsHandler) UI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+}
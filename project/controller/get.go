@@ -1,7 +1,7 @@
 package controller
 
 import (
-	"csvapi-test/model"
+	"csvapi-test/repository"
 	"csvapi-test/services"
 	"net/http"
 	"strings"
@@ -9,22 +9,37 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func Fetch(c *gin.Context) {
+// Search, filter and unix milliseconds range queries of the candles, pagination is parsed separately
+type fetchQuery struct {
+	Search string `form:"search" doc:"Full text search"`
+	Filter string `form:"filter" doc:"Comparisons of the candle fields separated by ;"`
+	From   uint64 `form:"from" doc:"Start of the range in unix milliseconds, inclusive"`
+	To     uint64 `form:"to" binding:"omitempty,gtfield=From" doc:"End of the range in unix milliseconds, exclusive"`
+}
+
+// Query saved candles with search, filter and pagination queries
+func (handler *CandleHandler) Fetch(c *gin.Context) {
 	var (
-		ohlcs            []model.Ohcl
-		db               = model.DB
-		search           = c.Query("search")
+		ctx              = c.Request.Context()
+		params           fetchQuery
 		pagination       services.Pagination
 		isFullPagination = strings.ToLower(c.Query("ptype")) == "full" // pagination type
 	)
+	if err := c.ShouldBindQuery(&params); err != nil {
+		services.AbortWithQueryError(c, &params, err)
+		return
+	}
+	// Unix milliseconds range, lets the database skip partitions outside of it
+	query := repository.CandleQuery{Search: params.Search, From: params.From, To: params.To}
 
-	// Compute full search on the request search query if set
-	if search != "" && !strings.HasPrefix(db.Dialector.Name(), "sqlite") {
-		db = db.Where(
-			`to_tsvector(
-					'english', unix || ' ' || symbol || ' ' || open || ' ' || high  || ' ' ||  low  || ' ' || close  
-					) @@ to_tsquery('english', ?)`,
-			search)
+	// Apply structured filter e.g close>42000;symbol in (BTCUSDT,ETHUSDT)
+	if params.Filter != "" {
+		expression, err := services.ParseFilter(params.Filter)
+		if err != nil {
+			services.FilterQueryError(c, err)
+			return
+		}
+		query.Filter = expression
 	}
 
 	paginationQueries := &services.PaginationParams{}
@@ -32,25 +47,25 @@ func Fetch(c *gin.Context) {
 
 	// Add full pagination object to the result
 	if isFullPagination {
-		var total int64
-
-		if err := db.Model(model.Ohcl{}).Count(&total).Error; err != nil {
-			services.ServerErrror(c, err, "")
+		total, err := handler.store.Count(ctx, query)
+		if err != nil {
+			services.ServerErrror(c, services.CodeInternal, err)
 			return
 		}
 
 		paginationP, err := services.Paginate(c, *paginationQueries, int(total))
 		if err != nil {
-			services.ServerErrror(c, err, "")
+			services.ServerErrror(c, services.CodeInternal, err)
 			return
 		}
 		pagination = *paginationP
 	}
 
-	if err := db.Limit(paginationQueries.Limit).
-		Offset(paginationQueries.Offset).
-		Find(&ohlcs).Error; err != nil {
-		services.ServerErrror(c, err, "")
+	query.Limit = paginationQueries.Limit
+	query.Offset = paginationQueries.Offset
+	ohlcs, err := handler.store.Query(ctx, query)
+	if err != nil {
+		services.ServerErrror(c, services.CodeInternal, err)
 		return
 	}
 
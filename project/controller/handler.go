@@ -0,0 +1,23 @@
+package controller
+
+import (
+	"csvapi-test/config"
+	"csvapi-test/drain"
+	"csvapi-test/repository"
+	"time"
+)
+
+// Handlers for the candle data routes and their dependencies
+type CandleHandler struct {
+	store        repository.CandleStore
+	audit        repository.AuditStore // Audit log of the finished imports
+	importConfig config.ImportConfig   // Chunk size, worker pool size and timeout of csv uploads
+	tenants      config.TenantsConfig  // Upload size quotas
+	imports      *drain.Drain          // Running imports, drained on shutdown
+	retryAfter   time.Duration         // Retry-After of uploads rejected or interrupted by a shutdown
+	running      *runningImports       // Imports running on this instance, for their status
+}
+
+func NewCandleHandler(store repository.CandleStore, audit repository.AuditStore, importConfig config.ImportConfig, tenants config.TenantsConfig, imports *drain.Drain, retryAfter time.Duration) *CandleHandler {
+	return &CandleHandler{store: store, audit: audit, importConfig: importConfig, tenants: tenants, imports: imports, retryAfter: retryAfter, running: newRunningImports()}
+}
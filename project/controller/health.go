@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"csvapi-test/health"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Liveness and readiness probes for container orchestrators
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{checker: checker}
+}
+
+// Process is alive and serving requests, no dependency is checked
+func (handler *HealthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Alive",
+	})
+}
+
+// Dependencies are ready for traffic, 503 with the failed checks if not
+func (handler *HealthHandler) Ready(c *gin.Context) {
+	ready, checks := handler.checker.Ready(c.Request.Context())
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  "failed",
+			"error":   true,
+			"message": "Not ready",
+			"data":    checks,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Ready",
+		"data":    checks,
+	})
+}
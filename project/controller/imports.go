@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"csvapi-test/auth"
+	"csvapi-test/model"
+	"csvapi-test/repository"
+	"csvapi-test/services"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Imports running on this instance, by tenant and request id of their upload
+type runningImports struct {
+	mutex   sync.Mutex
+	uploads map[string]int // Count of the running uploads sent with the same request id
+}
+
+func newRunningImports() *runningImports {
+	return &runningImports{uploads: map[string]int{}}
+}
+
+// Mark the import of the upload as running, call finished once it is committed or rolled back
+func (running *runningImports) add(tenant, requestID string) (finished func()) {
+	key := tenant + "/" + requestID
+	running.mutex.Lock()
+	running.uploads[key]++
+	running.mutex.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			running.mutex.Lock()
+			defer running.mutex.Unlock()
+			if running.uploads[key]--; running.uploads[key] == 0 {
+				delete(running.uploads, key)
+			}
+		})
+	}
+}
+
+func (running *runningImports) has(tenant, requestID string) bool {
+	running.mutex.Lock()
+	defer running.mutex.Unlock()
+	return running.uploads[tenant+"/"+requestID] > 0
+}
+
+// Status of the import of the upload sent with the request id, running on this instance or finished.
+// Finished imports are found in the audit log, so rejected uploads are not found once they ended.
+func (handler *CandleHandler) ImportStatus(c *gin.Context) {
+	ctx := c.Request.Context()
+	requestID := c.Param("request_id")
+
+	// Checked before the audit log, the import is audited before it stops running
+	if handler.running.has(auth.Tenant(ctx), requestID) {
+		response := gin.H{
+			"status":  "success",
+			"message": "Import is running",
+			"data":    model.ImportStatus{RequestID: requestID, Status: model.ImportRunning},
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	entries, err := handler.audit.List(ctx, repository.AuditQuery{RequestID: requestID, Limit: -1})
+	if err != nil {
+		services.ServerErrror(c, services.CodeInternal, err)
+		return
+	}
+	// Latest first, a retried upload reuses the request id of the interrupted one
+	for _, entry := range entries {
+		status := importStatuses[entry.Action]
+		if status == "" {
+			continue
+		}
+		var summary model.ImportSummary
+		if err := json.Unmarshal([]byte(entry.After), &summary); err != nil {
+			services.ServerErrror(c, services.CodeInternal, err)
+			return
+		}
+		finishedAt := entry.CreatedAt
+		response := gin.H{
+			"status":  "success",
+			"message": "Import is " + status,
+			"data":    model.ImportStatus{RequestID: requestID, Status: status, Summary: &summary, FinishedAt: &finishedAt},
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	services.ErrorResponse(c, services.CodeNotFound, "No running or finished import of the upload with request id "+requestID+", rejected uploads are not kept")
+}
+
+// Import status of the audited import actions
+var importStatuses = map[string]string{
+	model.AuditCandleImport:            model.ImportCommitted,
+	model.AuditCandleImportInterrupted: model.ImportInterrupted,
+}
@@ -0,0 +1,226 @@
+package controller
+
+import (
+	"csvapi-test/config"
+	"csvapi-test/health"
+	"csvapi-test/model"
+	"csvapi-test/openapi"
+	"csvapi-test/services"
+	"mime/multipart"
+	"net/http"
+)
+
+// OpenAPI descriptions of the handlers, the router adds them to the spec with their path and scope
+
+// Success envelope of the responses without data
+type message struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// Success envelope of the responses
+type envelope[T any] struct {
+	message
+	Data T `json:"data"`
+}
+
+// Success envelope of the listings, with the pagination object
+type pagedEnvelope[T any] struct {
+	envelope[T]
+	Pagination services.Pagination `json:"pagination"`
+}
+
+// Parsed by services.PaginationParams
+type paginationQuery struct {
+	Page  int `form:"page" doc:"Page number, from 1"`
+	Limit int `form:"limit" doc:"Page size, 100 by default"`
+}
+
+type idPath struct {
+	ID uint64 `uri:"id" doc:"Positive integer id"`
+}
+
+type importForm struct {
+	CSVFile multipart.FileHeader `form:"csv_file" binding:"required" doc:"Csv file with the UNIX,SYMBOL,OPEN,HIGH,LOW,CLOSE header, UNIX in milliseconds"`
+}
+
+type requestIDPath struct {
+	RequestID string `uri:"request_id" doc:"X-Request-ID sent with the upload"`
+}
+
+// Fields of the PATCH payload, omitted fields keep their saved value
+type candleUpdate struct {
+	UNIX   *uint64  `json:"unix" doc:"Open time in unix milliseconds"`
+	SYMBOL *string  `json:"symbol" binding:"symbol"`
+	OPEN   *float32 `json:"open"`
+	HIGH   *float32 `json:"high" doc:"Highest price of the candle"`
+	LOW    *float32 `json:"low" doc:"Lowest price of the candle"`
+	CLOSE  *float32 `json:"close"`
+}
+
+type deleteRangeSummary struct {
+	DeletedRows int64 `json:"deletedRows"`
+}
+
+type readiness struct {
+	envelope[map[string]health.Result]
+	Error bool `json:"error,omitempty" doc:"Set when not ready"`
+}
+
+type createdAPIKey struct {
+	envelope[model.APIKey]
+	Key string `json:"key" doc:"The api key, only returned once"`
+}
+
+type retentionRuns struct {
+	pagedEnvelope[[]model.RetentionRun]
+	Policies []config.RetentionPolicy `json:"policies"`
+}
+
+// Query of GET /data besides the fetchQuery
+type fetchPaginationQuery struct {
+	Ptype string `form:"ptype" binding:"oneof=full" doc:"full to add the pagination object, which counts the matching candles"`
+}
+
+var (
+	LiveDoc = openapi.Route{
+		ID:        "live",
+		Tag:       "health",
+		Summary:   "Liveness probe",
+		Responses: map[int]interface{}{http.StatusOK: message{}},
+	}
+	ReadyDoc = openapi.Route{
+		ID:          "ready",
+		Tag:         "health",
+		Summary:     "Readiness probe",
+		Description: "Checks the database, migrations, retention runner, spool disk space and running imports. Not ready once shutdown starts.",
+		Responses:   map[int]interface{}{http.StatusOK: readiness{}, http.StatusServiceUnavailable: readiness{}},
+	}
+	MetricsDoc = openapi.Route{
+		ID:        "metrics",
+		Tag:       "health",
+		Summary:   "Prometheus metrics",
+		Responses: map[int]interface{}{http.StatusOK: openapi.Raw{ContentType: "text/plain", Description: "Metrics in the Prometheus text exposition format"}},
+	}
+
+	CreateDoc = openapi.Route{
+		ID:          "importCandles",
+		Tag:         "candles",
+		Summary:     "Import candles from a csv file",
+		Description: "Rows are saved in one transaction, an invalid row rolls back the whole import.",
+		Form:        importForm{},
+		Responses:   map[int]interface{}{http.StatusCreated: envelope[model.ImportResult]{}},
+		Errors: []services.ErrorCode{
+			services.CodeCsvInvalidForm, services.CodeCsvInvalidFile, services.CodeCsvInvalidHeader, services.CodeRowParseError,
+			services.CodeUploadTooLarge, services.CodeQuotaExceeded, services.CodeTooManyImports, services.CodeDBConflict,
+			services.CodeImportFailed, services.CodeImportInterrupted, services.CodeShuttingDown,
+		},
+	}
+	ImportStatusDoc = openapi.Route{
+		ID:          "getImportStatus",
+		Tag:         "candles",
+		Summary:     "Status of the import of an upload",
+		Description: "Send an `X-Request-ID` with the upload to poll its import, e.g after the connection dropped. Running imports are only known to the instance running them, rejected uploads are not found once they ended.",
+		Path:        requestIDPath{},
+		Responses:   map[int]interface{}{http.StatusOK: envelope[model.ImportStatus]{}},
+		Errors:      []services.ErrorCode{services.CodeNotFound, services.CodeRateLimited},
+	}
+	FetchDoc = openapi.Route{
+		ID:          "listCandles",
+		Tag:         "candles",
+		Summary:     "Search and filter candles",
+		Description: "Filters combine comparisons of the candle fields with `;`, e.g `close>42000;symbol in (BTCUSDT,ETHUSDT)`. The pagination object is only sent with `ptype=full`.",
+		Query:       []interface{}{fetchQuery{}, paginationQuery{}, fetchPaginationQuery{}},
+		Responses:   map[int]interface{}{http.StatusOK: pagedEnvelope[[]model.Ohcl]{}},
+		Errors: []services.ErrorCode{
+			services.CodeInvalidParameter, services.CodeValidationFailed, services.CodeInvalidFilter, services.CodeRateLimited,
+		},
+	}
+	UpdateDoc = openapi.Route{
+		ID:          "updateCandle",
+		Tag:         "candles",
+		Summary:     "Correct fields of a candle",
+		Description: "The merged candle is validated, high must stay the highest price and low the lowest.",
+		Path:        idPath{},
+		Body:        candleUpdate{},
+		Responses:   map[int]interface{}{http.StatusOK: envelope[model.Ohcl]{}},
+		Errors: []services.ErrorCode{
+			services.CodeInvalidParameter, services.CodeInvalidBody, services.CodeValidationFailed, services.CodeNotFound,
+			services.CodeDBConflict,
+		},
+	}
+	DeleteDoc = openapi.Route{
+		ID:        "deleteCandle",
+		Tag:       "candles",
+		Summary:   "Delete a candle",
+		Path:      idPath{},
+		Responses: map[int]interface{}{http.StatusOK: envelope[model.Ohcl]{}},
+		Errors:    []services.ErrorCode{services.CodeInvalidParameter, services.CodeNotFound},
+	}
+	DeleteRangeDoc = openapi.Route{
+		ID:        "deleteCandleRange",
+		Tag:       "candles",
+		Summary:   "Delete the candles of a symbol within a time range",
+		Query:     []interface{}{deleteRangeQuery{}},
+		Responses: map[int]interface{}{http.StatusOK: envelope[deleteRangeSummary]{}},
+		Errors:    []services.ErrorCode{services.CodeInvalidParameter, services.CodeValidationFailed},
+	}
+
+	AuditDoc = openapi.Route{
+		ID:        "listAuditEntries",
+		Tag:       "admin",
+		Summary:   "List the audit log, latest first",
+		Query:     []interface{}{auditQuery{}, paginationQuery{}},
+		Responses: map[int]interface{}{http.StatusOK: pagedEnvelope[[]model.AuditEntry]{}},
+		Errors:    []services.ErrorCode{services.CodeInvalidParameter, services.CodeValidationFailed},
+	}
+	ListRunsDoc = openapi.Route{
+		ID:        "listRetentionRuns",
+		Tag:       "admin",
+		Summary:   "List the retention policies and runs, latest first",
+		Query:     []interface{}{paginationQuery{}},
+		Responses: map[int]interface{}{http.StatusOK: retentionRuns{}},
+	}
+	RunRetentionDoc = openapi.Route{
+		ID:        "runRetention",
+		Tag:       "admin",
+		Summary:   "Apply the retention policies now",
+		Responses: map[int]interface{}{http.StatusOK: envelope[[]model.RetentionRun]{}},
+		Errors:    []services.ErrorCode{services.CodeRetentionRunning},
+	}
+	ListAPIKeysDoc = openapi.Route{
+		ID:        "listAPIKeys",
+		Tag:       "admin",
+		Summary:   "List the api keys",
+		Responses: map[int]interface{}{http.StatusOK: envelope[[]model.APIKey]{}},
+	}
+	CreateAPIKeyDoc = openapi.Route{
+		ID:        "createAPIKey",
+		Tag:       "admin",
+		Summary:   "Create an api key",
+		Body:      createAPIKeyRequest{},
+		Responses: map[int]interface{}{http.StatusCreated: createdAPIKey{}},
+		Errors:    []services.ErrorCode{services.CodeInvalidBody, services.CodeValidationFailed},
+	}
+	RevokeAPIKeyDoc = openapi.Route{
+		ID:        "revokeAPIKey",
+		Tag:       "admin",
+		Summary:   "Revoke an api key",
+		Path:      idPath{},
+		Responses: map[int]interface{}{http.StatusOK: envelope[model.APIKey]{}},
+		Errors:    []services.ErrorCode{services.CodeInvalidParameter, services.CodeNotFound},
+	}
+
+	SpecDoc = openapi.Route{
+		ID:        "openapi",
+		Tag:       "docs",
+		Summary:   "This OpenAPI document",
+		Responses: map[int]interface{}{http.StatusOK: openapi.Raw{ContentType: "application/json", Description: "OpenAPI 3 document"}},
+	}
+	DocsUIDoc = openapi.Route{
+		ID:        "docs",
+		Tag:       "docs",
+		Summary:   "Swagger UI of the OpenAPI document",
+		Responses: map[int]interface{}{http.StatusOK: openapi.Raw{ContentType: "text/html", Description: "Swagger UI page"}},
+	}
+)
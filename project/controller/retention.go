@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"csvapi-test/retention"
+	"csvapi-test/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Admin handlers for the retention policies and their runs
+type RetentionHandler struct {
+	runner *retention.Runner
+}
+
+func NewRetentionHandler(runner *retention.Runner) *RetentionHandler {
+	return &RetentionHandler{runner: runner}
+}
+
+// List the configured policies and the logged runs, latest first
+func (handler *RetentionHandler) ListRuns(c *gin.Context) {
+	paginationQueries := &services.PaginationParams{}
+	paginationQueries.ParseQuery(c)
+
+	runs, total, err := handler.runner.ListRuns(c.Request.Context(), paginationQueries.Limit, paginationQueries.Offset)
+	if err != nil {
+		services.ServerErrror(c, services.CodeInternal, err)
+		return
+	}
+
+	pagination, err := services.Paginate(c, *paginationQueries, int(total))
+	if err != nil {
+		services.ServerErrror(c, services.CodeInternal, err)
+		return
+	}
+
+	response := gin.H{
+		"status":     "success",
+		"message":    "Retention runs successfully fetched",
+		"policies":   handler.runner.Policies(),
+		"data":       runs,
+		"pagination": pagination,
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Apply every policy now instead of waiting for the schedule
+func (handler *RetentionHandler) Run(c *gin.Context) {
+	runs, err := handler.runner.RunOnce(c.Request.Context())
+	if errors.Is(err, retention.ErrRunInProgress) {
+		services.ErrorResponse(c, services.CodeRetentionRunning, err.Error())
+		return
+	}
+	if err != nil {
+		services.ServerErrror(c, services.CodeInternal, err)
+		return
+	}
+
+	response := gin.H{
+		"status":  "success",
+		"message": "Retention policies applied",
+		"data":    runs,
+	}
+	c.JSON(http.StatusOK, response)
+}
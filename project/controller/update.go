@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"csvapi-test/repository"
+	"csvapi-test/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Parse the :id route param, sends a bad request response if invalid
+func candleID(c *gin.Context) (id uint64, ok bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		services.ErrorResponse(c, services.CodeInvalidParameter, "id must be a positive integer")
+		return 0, false
+	}
+	return id, true
+}
+
+// Correct fields of a single candle, omitted fields keep their saved value
+func (handler *CandleHandler) Update(c *gin.Context) {
+	id, ok := candleID(c)
+	if !ok {
+		return
+	}
+
+	candle, err := handler.store.Get(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrCandleNotFound) {
+		services.ErrorResponse(c, services.CodeNotFound, err.Error())
+		return
+	} else if err != nil {
+		services.ServerErrror(c, services.CodeInternal, err)
+		return
+	}
+
+	// Bind the payload over the saved candle, so the merged candle is validated with the model binding tags
+	if err := c.ShouldBindJSON(&candle); err != nil {
+		services.AbortWithRequestError(c, &candle, err)
+		return
+	}
+	candle.ID = id
+
+	if _, err := handler.store.Update(c.Request.Context(), candle); errors.Is(err, repository.ErrCandleNotFound) {
+		services.ErrorResponse(c, services.CodeNotFound, err.Error())
+		return
+	} else if err != nil {
+		services.ServerErrror(c, services.CodeInternal, err)
+		return
+	}
+
+	response := gin.H{
+		"status":  "success",
+		"message": "Data successfully updated",
+		"data":    candle,
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,105 @@
+package drain
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// Returned when a job is started after the shutdown began
+	ErrShuttingDown = errors.New("the server is shutting down")
+	// Cancellation cause of the jobs still running at the drain deadline
+	ErrInterrupted = errors.New("interrupted by the server shutdown")
+)
+
+// Time the interrupted jobs get to roll back after their cancellation
+const interruptGrace = 10 * time.Second
+
+// Long running jobs, e.g csv imports, that the shutdown waits for before stopping the server
+type Drain struct {
+	mutex    sync.Mutex
+	draining bool
+	jobs     map[int]context.CancelCauseFunc
+	next     int
+	running  sync.WaitGroup
+}
+
+func New() *Drain {
+	return &Drain{jobs: map[int]context.CancelCauseFunc{}}
+}
+
+// Start a job, its context is cancelled with ErrInterrupted if it is still running at the drain deadline.
+// Call finished once the job ended. Fails with ErrShuttingDown once the shutdown began.
+func (drain *Drain) Begin(ctx context.Context) (jobCtx context.Context, finished func(), err error) {
+	drain.mutex.Lock()
+	defer drain.mutex.Unlock()
+	if drain.draining {
+		return nil, nil, ErrShuttingDown
+	}
+
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	id := drain.next
+	drain.next++
+	drain.jobs[id] = cancel
+	drain.running.Add(1)
+
+	var once sync.Once
+	return jobCtx, func() {
+		once.Do(func() {
+			drain.mutex.Lock()
+			delete(drain.jobs, id)
+			drain.mutex.Unlock()
+			cancel(nil)
+			drain.running.Done()
+		})
+	}, nil
+}
+
+// Jobs currently running
+func (drain *Drain) InFlight() int {
+	drain.mutex.Lock()
+	defer drain.mutex.Unlock()
+	return len(drain.jobs)
+}
+
+// Whether the shutdown began, new jobs are rejected
+func (drain *Drain) Draining() bool {
+	drain.mutex.Lock()
+	defer drain.mutex.Unlock()
+	return drain.draining
+}
+
+// Reject new jobs and wait for the running ones until ctx is done, then cancel the remaining jobs
+// with ErrInterrupted and wait for them to roll back. Returns the number of interrupted jobs.
+func (drain *Drain) Shutdown(ctx context.Context) (interrupted int) {
+	drain.mutex.Lock()
+	drain.draining = true
+	drain.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		drain.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return 0
+	case <-ctx.Done():
+	}
+
+	drain.mutex.Lock()
+	for _, cancel := range drain.jobs {
+		cancel(ErrInterrupted)
+		interrupted++
+	}
+	drain.mutex.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(interruptGrace):
+	}
+	return interrupted
+}
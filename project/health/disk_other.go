@@ -0,0 +1,7 @@
+//go:build !(linux || darwin || freebsd)
+
+package health
+
+func freeBytes(dir string) (int64, error) {
+	return 0, errUnsupported
+}
@@ -0,0 +1,132 @@
+package health
+
+import (
+	"context"
+	"csvapi-test/model"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Check statuses, a disabled check does not apply to the deployment and does not fail readiness
+const (
+	StatusOK       = "ok"
+	StatusFailed   = "failed"
+	StatusDisabled = "disabled"
+)
+
+// Time limit of each readiness check
+const checkTimeout = 2 * time.Second
+
+var errUnsupported = errors.New("free disk space is not supported on this platform")
+
+// Outcome of a readiness check
+type Result struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Background job runner checked for liveness, e.g the retention runner
+type JobRunner interface {
+	Scheduled() bool
+	Alive() bool
+}
+
+// Running imports, drained on shutdown
+type ImportTracker interface {
+	InFlight() int
+	Draining() bool
+}
+
+// Readiness checks of the api dependencies, readiness is false once shutdown starts
+type Checker struct {
+	db           *gorm.DB
+	jobs         JobRunner
+	imports      ImportTracker
+	spoolDir     string
+	minSpoolFree int64
+	shuttingDown atomic.Bool
+}
+
+// Checker of db, jobs and imports, uploads are spooled to the temporary directory with minSpoolFree bytes required free
+func NewChecker(db *gorm.DB, jobs JobRunner, imports ImportTracker, minSpoolFree int64) *Checker {
+	return &Checker{db: db, jobs: jobs, imports: imports, spoolDir: os.TempDir(), minSpoolFree: minSpoolFree}
+}
+
+// Report not ready from now on, so orchestrators stop routing requests before the server stops
+func (checker *Checker) Shutdown() {
+	checker.shuttingDown.Store(true)
+}
+
+// Run every readiness check, ready if none failed and shutdown has not started
+func (checker *Checker) Ready(ctx context.Context) (ready bool, results map[string]Result) {
+	results = map[string]Result{
+		"database":   checker.check(ctx, checker.pingDatabase),
+		"migrations": checker.check(ctx, checker.migrations),
+		"jobs":       checker.jobRunner(),
+		"spool":      checker.spool(),
+		"imports":    {Status: StatusOK, Detail: fmt.Sprintf("%d running", checker.imports.InFlight())},
+	}
+	ready = !checker.shuttingDown.Load() && !checker.imports.Draining()
+	if !ready {
+		results["shutdown"] = Result{Status: StatusFailed, Detail: fmt.Sprintf("shutting down, draining %d imports", checker.imports.InFlight())}
+	}
+	for _, result := range results {
+		if result.Status == StatusFailed {
+			ready = false
+		}
+	}
+	return ready, results
+}
+
+func (checker *Checker) check(ctx context.Context, run func(ctx context.Context) error) Result {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	if err := run(ctx); err != nil {
+		return Result{Status: StatusFailed, Detail: err.Error()}
+	}
+	return Result{Status: StatusOK}
+}
+
+func (checker *Checker) pingDatabase(ctx context.Context) error {
+	sqlDB, err := checker.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func (checker *Checker) migrations(ctx context.Context) error {
+	return model.RequireMigrated(checker.db.WithContext(ctx))
+}
+
+func (checker *Checker) jobRunner() Result {
+	if !checker.jobs.Scheduled() {
+		return Result{Status: StatusDisabled, Detail: "no scheduled retention runs"}
+	}
+	if !checker.jobs.Alive() {
+		return Result{Status: StatusFailed, Detail: "retention runner stopped"}
+	}
+	return Result{Status: StatusOK}
+}
+
+func (checker *Checker) spool() Result {
+	if checker.minSpoolFree <= 0 {
+		return Result{Status: StatusDisabled}
+	}
+	free, err := freeBytes(checker.spoolDir)
+	if errors.Is(err, errUnsupported) {
+		return Result{Status: StatusDisabled, Detail: err.Error()}
+	} else if err != nil {
+		return Result{Status: StatusFailed, Detail: err.Error()}
+	}
+	detail := fmt.Sprintf("%d bytes free in %s", free, checker.spoolDir)
+	if free < checker.minSpoolFree {
+		return Result{Status: StatusFailed, Detail: fmt.Sprintf("%s, %d required", detail, checker.minSpoolFree)}
+	}
+	return Result{Status: StatusOK, Detail: detail}
+}
@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Gorm logger writing to the default slog logger, so queries are logged with the request id of their context.
+// Queries are logged at debug level, slow queries at warn and failed ones at error, not found records are not failures.
+type GormLogger struct {
+	SlowThreshold time.Duration // 0 disables slow query logs
+	level         logger.LogLevel
+}
+
+func NewGormLogger(slowThreshold time.Duration) *GormLogger {
+	return &GormLogger{SlowThreshold: slowThreshold, level: logger.Info}
+}
+
+// Copy logging from level, e.g logger.Warn skips the debug query logs
+func (gormLogger *GormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	copied := *gormLogger
+	copied.level = level
+	return &copied
+}
+
+func (gormLogger *GormLogger) Info(ctx context.Context, message string, args ...interface{}) {
+	if gormLogger.level >= logger.Info {
+		slog.InfoContext(ctx, message, slog.Any("args", args))
+	}
+}
+
+func (gormLogger *GormLogger) Warn(ctx context.Context, message string, args ...interface{}) {
+	if gormLogger.level >= logger.Warn {
+		slog.WarnContext(ctx, message, slog.Any("args", args))
+	}
+}
+
+func (gormLogger *GormLogger) Error(ctx context.Context, message string, args ...interface{}) {
+	if gormLogger.level >= logger.Error {
+		slog.ErrorContext(ctx, message, slog.Any("args", args))
+	}
+}
+
+func (gormLogger *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if gormLogger.level <= logger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	query := func(level slog.Level, message string, attrs ...slog.Attr) {
+		sql, rows := fc()
+		attrs = append(attrs, slog.String("sql", sql), slog.Int64("rows", rows), slog.Duration("elapsed", elapsed))
+		slog.Default().LogAttrs(ctx, level, message, attrs...)
+	}
+
+	switch {
+	case err != nil && gormLogger.level >= logger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		query(slog.LevelError, "query failed", slog.String("error", err.Error()))
+	case gormLogger.SlowThreshold > 0 && elapsed > gormLogger.SlowThreshold && gormLogger.level >= logger.Warn:
+		query(slog.LevelWarn, "slow query", slog.Duration("threshold", gormLogger.SlowThreshold))
+	case gormLogger.level >= logger.Info && slog.Default().Enabled(ctx, slog.LevelDebug):
+		query(slog.LevelDebug, "query")
+	}
+}
@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"context"
+	"csvapi-test/config"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+type attrsKey struct{}
+
+// Attach attributes to ctx, added to every record logged with the context e.g the request id
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(existing)+len(attrs))
+	merged = append(append(merged, existing...), attrs...)
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+// Logger writing to w in the configured format, from the configured level
+func New(logConfig config.LogConfig, w io.Writer) (*slog.Logger, error) {
+	level, err := ParseLevel(logConfig.Level)
+	if err != nil {
+		return nil, err
+	}
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch logConfig.Format {
+	case "json":
+		handler = slog.NewJSONHandler(w, options)
+	case "text":
+		handler = slog.NewTextHandler(w, options)
+	default:
+		return nil, fmt.Errorf("log format must be one of %s, got %q", strings.Join(config.LogFormats, ", "), logConfig.Format)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+// Slog level of the level name
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("log level must be one of %s, got %q", strings.Join(config.LogLevels, ", "), name)
+	}
+	return level, nil
+}
+
+// Adds the context attributes to the records
+type contextHandler struct {
+	slog.Handler
+}
+
+func (handler contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		record.AddAttrs(attrs...)
+	}
+	return handler.Handler.Handle(ctx, record)
+}
+
+func (handler contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{handler.Handler.WithAttrs(attrs)}
+}
+
+func (handler contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{handler.Handler.WithGroup(name)}
+}
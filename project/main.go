@@ -2,76 +2,164 @@ package main
 
 import (
 	"context"
+	"csvapi-test/auth"
+	"csvapi-test/config"
+	"csvapi-test/drain"
+	"csvapi-test/health"
+	"csvapi-test/logging"
+	"csvapi-test/metrics"
 	"csvapi-test/model"
+	"csvapi-test/repository"
+	"csvapi-test/retention"
 	"csvapi-test/router"
+	"csvapi-test/tracing"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 )
 
 func main() {
-	err := godotenv.Load()
+	// Load settings from config file, environment and flags
+	cfg, args, err := config.Load(os.Args[1:])
 	if err != nil {
-		panic("ENV not loaded")
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(2)
 	}
 
-	// Establish database connection,
-	model.DbConfig("LIVE_CONNECTION")
+	// Structured logs on stderr, gin prints its route table in debug mode only
+	logger, err := logging.New(cfg.Log, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logger)
+	if !strings.EqualFold(cfg.Log.Level, "debug") {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	// Run schema migrations e.g `./main migrate up`, partition or api key maintenance instead of serving
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			os.Exit(runMigrate(cfg.Database, args[1:]))
+		case "partition":
+			os.Exit(runPartition(cfg.Database, args[1:]))
+		case "apikey":
+			os.Exit(runAPIKey(cfg.Database, args[1:]))
+		}
+	}
+
+	// Export traces to the OTLP collector when an endpoint is configured
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("tracing setup failed", err)
+	}
+
+	// Establish database connection, fails if the schema is not migrated
+	db, err := model.DbConfig(cfg.Database)
+	if err != nil {
+		fatal("database connection failed", err)
+	}
+
+	// Publish the connection pool stats on /metrics
+	sqlDB, err := db.DB()
+	if err != nil {
+		fatal("database connection failed", err)
+	}
+	metrics.RegisterDB(sqlDB, cfg.Database.Name)
+
+	// Apply retention policies in the background until shutdown
+	retentionRunner := retention.NewRunner(db, cfg.Retention)
+	retentionCtx, stopRetention := context.WithCancel(context.Background())
+	defer stopRetention()
+	go retentionRunner.Start(retentionCtx)
+
+	// Verify bearer tokens of the OIDC provider when a JWKS is configured
+	var tokens *auth.TokenVerifier
+	if cfg.Auth.JWT.Enabled() {
+		if tokens, err = auth.NewTokenVerifier(cfg.Auth.JWT); err != nil {
+			fatal("loading the JWKS failed", err)
+		}
+	}
+
+	// Running imports, drained on shutdown
+	imports := drain.New()
+
+	// Readiness checks of the database, the retention runner and the upload spool
+	checker := health.NewChecker(db, retentionRunner, imports, cfg.Server.MinSpoolFree)
 
 	//Initialize *gin.Engine and app routes
-	app := router.AppInstance()
+	app := router.AppInstance(cfg, router.Dependencies{
+		Store:     repository.NewGormCandleStore(db).WithQuotas(cfg.Tenants),
+		Audit:     repository.NewGormAuditStore(db),
+		APIKeys:   repository.NewGormAPIKeyStore(db),
+		Tokens:    tokens,
+		Retention: retentionRunner,
+		Health:    checker,
+		Imports:   imports,
+	})
 
 	app.GET("", func(ctx *gin.Context) {
 		ctx.JSON(http.StatusOK, "Up and running")
 	})
 
-	app.NoRoute(func(ctx *gin.Context) {
-		notFoundResponse := gin.H{
-			"error":   true,
-			"status":  "failed",
-			"message": "Page not found",
-		}
-		ctx.JSON(http.StatusNotFound, notFoundResponse)
-	})
-
 	server := &http.Server{
-		Addr:           fmt.Sprintf(":%s", os.Getenv("PORT")),
+		Addr:           fmt.Sprintf(":%s", cfg.Server.Port),
 		Handler:        app,
-		ReadTimeout:    1 * time.Minute, // Default is 10s, increaased incase of large files
-		WriteTimeout:   3 * time.Minute, // Default is 10s, increaased incase of longer operation
-		MaxHeaderBytes: 2 << 20,         //2MB
+		ReadTimeout:    cfg.Server.ReadTimeout,
+		WriteTimeout:   cfg.Server.WriteTimeout,
+		MaxHeaderBytes: cfg.Server.MaxHeaderBytes,
 	}
 
 	go func() {
+		slog.Info("listening", slog.String("addr", server.Addr))
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal("listen:", err)
+			fatal("listen failed", err)
 		}
 	}()
 
 	// Wait for interrupt signal to gracefully shutdown the server with a timeout context.
 	// Visit https://gin-gonic.com/docs/examples/graceful-restart-or-stop/
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	// kill (no param) default send syscanll.SIGTERM
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// Report not ready so no new requests are routed here while the server stops
+	checker.Shutdown()
+	slog.Info("shutting down")
+
+	// Give the load balancers time to see the failing readiness probe before uploads are rejected
+	time.Sleep(cfg.Import.ReadyDelay)
+
+	// Reject new uploads and let running imports finish, interrupting them past the drain timeout
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.Import.DrainTimeout)
+	defer cancelDrain()
+	if interrupted := imports.Shutdown(drainCtx); interrupted > 0 {
+		slog.Warn("imports interrupted", slog.Int("count", interrupted))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatal("Server shutdown", err)
+		fatal("server shutdown failed", err)
 	}
-
-	// Catching ctx.Done().
-	select {
-	case <-ctx.Done():
-		log.Println("Graceful shutdown completed")
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Warn("flushing traces failed", slog.String("error", err.Error()))
 	}
+	slog.Info("graceful shutdown completed")
+}
+
+// Log the error and exit
+func fatal(message string, err error) {
+	slog.Error(message, slog.String("error", err.Error()))
+	os.Exit(1)
 }
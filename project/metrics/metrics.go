@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"csvapi-test/config"
+	"database/sql"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry of the application metrics served on /metrics
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+var (
+	// Served requests by route template, unmatched routes are labelled "unmatched"
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "csvapi_http_requests_total",
+		Help: "Http requests served, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "csvapi_http_request_duration_seconds",
+		Help:    "Http request latency, by method and route.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 180},
+	}, []string{"method", "route"})
+
+	HTTPRequestsInFlight = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "csvapi_http_requests_in_flight",
+		Help: "Http requests currently being served.",
+	})
+
+	// Finished csv imports by result: committed or rolled_back
+	Imports = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "csvapi_imports_total",
+		Help: "Csv imports finished, by result.",
+	}, []string{"result"})
+
+	ImportDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "csvapi_import_duration_seconds",
+		Help:    "Csv import duration from the first row read to the commit or rollback, by result.",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 13), // 50ms to 204s
+	}, []string{"result"})
+
+	ImportBytes = factory.NewCounter(prometheus.CounterOpts{
+		Name: "csvapi_import_bytes_total",
+		Help: "Size of the uploaded csv files.",
+	})
+
+	ImportRowsRead = factory.NewCounter(prometheus.CounterOpts{
+		Name: "csvapi_import_rows_read_total",
+		Help: "Csv rows read by finished imports, the saved and rejected rows.",
+	})
+
+	ImportRowsSaved = factory.NewCounter(prometheus.CounterOpts{
+		Name: "csvapi_import_rows_saved_total",
+		Help: "Csv rows of committed imports.",
+	})
+
+	// Rows read by imports that were rolled back, e.g after an invalid row or a failed batch
+	ImportRowsRejected = factory.NewCounter(prometheus.CounterOpts{
+		Name: "csvapi_import_rows_rejected_total",
+		Help: "Csv rows read by rolled back imports.",
+	})
+
+	ImportWorkers = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "csvapi_import_workers",
+		Help: "Import workers currently started.",
+	})
+
+	ImportWorkersBusy = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "csvapi_import_workers_busy",
+		Help: "Import workers currently inserting a batch.",
+	})
+
+	// Utilization of the workers is rate(csvapi_import_worker_busy_seconds_total) / csvapi_import_workers
+	ImportWorkerBusySeconds = factory.NewCounter(prometheus.CounterOpts{
+		Name: "csvapi_import_worker_busy_seconds_total",
+		Help: "Time spent by the import workers inserting batches.",
+	})
+
+	ImportChannelDepth = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "csvapi_import_channel_depth",
+		Help: "Batches read and waiting for an import worker.",
+	})
+
+	// Requests rejected by the per client rate limit, by route
+	RateLimitedRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "csvapi_rate_limited_requests_total",
+		Help: "Requests rejected with 429 by the per client rate limit.",
+	}, []string{"route"})
+
+	// Clients with a token bucket, forgotten once idle
+	RateLimitClients = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "csvapi_rate_limit_clients",
+		Help: "Clients currently tracked by the rate limiter.",
+	})
+
+	// Csv imports holding an import slot
+	ImportsInFlight = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "csvapi_imports_in_flight",
+		Help: "Csv imports currently running.",
+	})
+
+	// Imports rejected by the concurrency limits, by the limit reached: global or client
+	ImportsRejected = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "csvapi_imports_rejected_total",
+		Help: "Csv imports rejected with 429 by the concurrency limits.",
+	}, []string{"limit"})
+
+	// Configured limits, 0 is unlimited
+	Limits = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "csvapi_limit",
+		Help: "Configured request rate and import concurrency limits, 0 is unlimited.",
+	}, []string{"limit"})
+)
+
+var (
+	dbMutex     sync.Mutex
+	dbCollector prometheus.Collector
+)
+
+// Publish the connection pool stats of db as go_sql_* metrics, replacing the previously registered database
+func RegisterDB(db *sql.DB, name string) {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+
+	if dbCollector != nil {
+		Registry.Unregister(dbCollector)
+	}
+	dbCollector = collectors.NewDBStatsCollector(db, name)
+	Registry.MustRegister(dbCollector)
+}
+
+// Publish the configured limits
+func SetLimits(limits config.LimitsConfig) {
+	Limits.WithLabelValues("query_rate").Set(limits.QueryRate)
+	Limits.WithLabelValues("query_burst").Set(float64(limits.QueryBurst))
+	Limits.WithLabelValues("max_imports").Set(float64(limits.MaxImports))
+	Limits.WithLabelValues("max_client_imports").Set(float64(limits.MaxClientImports))
+}
+
+// Prometheus text exposition of the registry
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
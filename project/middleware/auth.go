@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"csvapi-test/audit"
+	"csvapi-test/auth"
+	"csvapi-test/config"
+	"csvapi-test/repository"
+	"csvapi-test/services"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header carrying an api key, alternatively sent as a bearer token
+const APIKeyHeader = "X-API-Key"
+
+// Retry-After of a bearer token that cannot be verified because the JWKS cannot be fetched
+const keySetRetryAfter = 5 * time.Second
+
+// Authenticate the api key or bearer token of the request and attach its principal to the request context.
+// Tokens are only accepted with a verifier, nil when no JWKS is configured.
+// With authentication disabled every request is an anonymous principal with every scope.
+func AuthMiddleware(keys repository.APIKeyStore, tokens *auth.TokenVerifier, authConfig config.AuthConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authConfig.Enabled {
+			setPrincipal(c, auth.Principal{Scopes: auth.Scopes})
+			c.Next()
+			return
+		}
+
+		key, token := requestCredentials(c)
+		if token != "" && tokens != nil {
+			authenticateToken(c, tokens, token)
+			return
+		}
+		if key == "" {
+			c.Header("WWW-Authenticate", "Bearer")
+			services.ErrorResponse(c, services.CodeUnauthorized, "An api key or bearer token is required, send the key in the "+APIKeyHeader+" header or as a bearer token")
+			c.Abort()
+			return
+		}
+
+		apiKey, err := keys.Authenticate(c.Request.Context(), key)
+		if errors.Is(err, repository.ErrInvalidAPIKey) {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			services.ErrorResponse(c, services.CodeInvalidCredentials, err.Error())
+			c.Abort()
+			return
+		} else if err != nil {
+			services.ServerErrror(c, services.CodeInternal, err)
+			c.Abort()
+			return
+		}
+
+		setPrincipal(c, auth.Principal{
+			Subject: fmt.Sprintf("apikey:%d:%s", apiKey.ID, apiKey.Name),
+			Tenant:  apiKey.Tenant,
+			Scopes:  apiKey.Scopes,
+		})
+		c.Next()
+	}
+}
+
+// Reject requests whose principal was not granted scope
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.FromContext(c.Request.Context())
+		if !ok {
+			c.Header("WWW-Authenticate", "Bearer")
+			services.ErrorResponse(c, services.CodeUnauthorized, "Authentication is required")
+			c.Abort()
+			return
+		}
+		if !principal.HasScope(scope) {
+			services.ErrorResponse(c, services.CodeForbidden, "The "+scope+" scope is required")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func authenticateToken(c *gin.Context, tokens *auth.TokenVerifier, token string) {
+	principal, err := tokens.Verify(c.Request.Context(), token)
+	if errors.Is(err, auth.ErrInvalidToken) {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		services.ErrorResponse(c, services.CodeInvalidCredentials, err.Error())
+		c.Abort()
+		return
+	} else if errors.Is(err, auth.ErrKeySetUnavailable) {
+		slog.WarnContext(c.Request.Context(), "bearer token not verified", slog.String("error", err.Error()))
+		services.RetryableError(c, services.CodeAuthUnavailable, keySetRetryAfter, "The keys of the token issuer cannot be fetched, retry later")
+		c.Abort()
+		return
+	} else if err != nil {
+		services.ServerErrror(c, services.CodeInternal, err)
+		c.Abort()
+		return
+	}
+	setPrincipal(c, principal)
+	c.Next()
+}
+
+// Reject principals outside the default tenant, for deployment wide operations
+func RequireDefaultTenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if auth.Tenant(c.Request.Context()) != "" {
+			services.ErrorResponse(c, services.CodeForbidden, "Only the default tenant can access this route")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Api key of the X-API-Key header or of the bearer authorization header, or any other bearer token
+func requestCredentials(c *gin.Context) (key, token string) {
+	if key := c.GetHeader(APIKeyHeader); key != "" {
+		return key, ""
+	}
+	scheme, bearer, found := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", ""
+	}
+	bearer = strings.TrimSpace(bearer)
+	if auth.IsAPIKey(bearer) {
+		return bearer, ""
+	}
+	return "", bearer
+}
+
+// Attach the principal and its tenant to the request context, it is the audit actor when authenticated
+func setPrincipal(c *gin.Context, principal auth.Principal) {
+	ctx := auth.WithPrincipal(c.Request.Context(), principal)
+	ctx = auth.WithTenant(ctx, principal.Tenant)
+	if principal.Subject != "" {
+		ctx = audit.WithActor(ctx, principal.Subject)
+	}
+	c.Request = c.Request.WithContext(ctx)
+}
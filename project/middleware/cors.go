@@ -1,27 +1,24 @@
 package middleware
 
 import (
-	"time"
+	"csvapi-test/config"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-func CORSMiddleware() gin.HandlerFunc {
-	AllowedOrigins := []string{
-		"*",
-	}
+func CORSMiddleware(corsConfig config.CORSConfig) gin.HandlerFunc {
 	return cors.New(
 		cors.Config{
-			AllowOrigins: AllowedOrigins,
+			AllowOrigins: corsConfig.AllowOrigins,
 			AllowMethods: []string{"PUT", "GET", "POST", "DELETE", "PATCH"},
 			AllowHeaders: []string{"Origin", "Content-Length",
 				"Accept-Encoding", "X-CSRF-Token", "Authorization", "Accept", "Cache-Control",
-				"X-Requested-With",
+				"X-Requested-With", RequestIDHeader, APIKeyHeader,
 			},
-			ExposeHeaders:    []string{"Content-Length"},
-			AllowCredentials: true,
-			MaxAge:           12 * time.Hour,
+			ExposeHeaders:    []string{"Content-Length", RequestIDHeader, "Deprecation", "Sunset", "Link"},
+			AllowCredentials: corsConfig.AllowCredentials,
+			MaxAge:           corsConfig.MaxAge,
 		},
 	)
 }
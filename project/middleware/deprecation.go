@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"csvapi-test/config"
+	"csvapi-test/logging"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Mark the responses of deprecated routes with the Deprecation (RFC 9745) and Sunset (RFC 8594) headers,
+// and a Link to the same path under the successor prefix e.g /v1.
+// Request logs of deprecated routes carry deprecated=true to find the clients still calling them.
+func DeprecationMiddleware(api config.APIConfig, successor string) gin.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(api.DeprecatedAt.Unix(), 10)
+	sunset := ""
+	if !api.Sunset.IsZero() {
+		sunset = api.Sunset.UTC().Format(http.TimeFormat)
+	}
+	return func(c *gin.Context) {
+		c.Header("Deprecation", deprecation)
+		if sunset != "" {
+			c.Header("Sunset", sunset)
+		}
+		c.Header("Link", "<"+successor+c.Request.URL.Path+`>; rel="successor-version"`)
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), slog.Bool("deprecated", true)))
+		c.Next()
+	}
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"csvapi-test/auth"
+	"csvapi-test/config"
+	"csvapi-test/metrics"
+	"csvapi-test/services"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// Clients without requests for this long are forgotten, unless refilling their bucket takes longer
+const clientIdleTimeout = 10 * time.Minute
+
+// Token bucket of a client
+type clientBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Token buckets by client, idle clients are swept on the following requests
+type rateLimiter struct {
+	limit     rate.Limit
+	burst     int
+	idle      time.Duration
+	mutex     sync.Mutex
+	clients   map[string]*clientBucket
+	lastSweep time.Time
+}
+
+// Limit each client to limits.QueryRate requests per second with bursts of limits.QueryBurst,
+// rejected requests get 429 with the time until a token is available in Retry-After.
+// Must run after the auth middleware to key the buckets by api key or token subject.
+func RateLimitMiddleware(limits config.LimitsConfig) gin.HandlerFunc {
+	if limits.QueryRate <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	idle := clientIdleTimeout
+	if refill := time.Duration(float64(limits.QueryBurst) / limits.QueryRate * float64(time.Second)); refill > idle {
+		idle = refill
+	}
+	limiter := &rateLimiter{
+		limit:   rate.Limit(limits.QueryRate),
+		burst:   limits.QueryBurst,
+		idle:    idle,
+		clients: map[string]*clientBucket{},
+	}
+
+	return func(c *gin.Context) {
+		if delay := limiter.reserve(clientKey(c), time.Now()); delay > 0 {
+			metrics.RateLimitedRequests.WithLabelValues(c.FullPath()).Inc()
+			services.RetryableError(c, services.CodeRateLimited, delay, "Rate limit exceeded, retry later")
+			return
+		}
+		c.Next()
+	}
+}
+
+// Take a token of the client bucket, returns the wait until a token is available if there is none
+func (limiter *rateLimiter) reserve(client string, now time.Time) time.Duration {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if now.Sub(limiter.lastSweep) > limiter.idle {
+		for key, bucket := range limiter.clients {
+			if now.Sub(bucket.lastSeen) > limiter.idle {
+				delete(limiter.clients, key)
+			}
+		}
+		limiter.lastSweep = now
+	}
+
+	bucket, found := limiter.clients[client]
+	if !found {
+		bucket = &clientBucket{limiter: rate.NewLimiter(limiter.limit, limiter.burst)}
+		limiter.clients[client] = bucket
+	}
+	bucket.lastSeen = now
+	metrics.RateLimitClients.Set(float64(len(limiter.clients)))
+
+	reservation := bucket.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now) // the request is rejected, give the token back
+		return delay
+	}
+	return 0
+}
+
+// Running imports, in total and by client
+type importSlots struct {
+	max       int
+	maxClient int
+	mutex     sync.Mutex
+	running   int
+	clients   map[string]int
+}
+
+// Limit the concurrent imports to limits.MaxImports in total and limits.MaxClientImports per client,
+// an import over either limit is rejected with 429 before its file is read.
+// Must run after the auth middleware to count the imports by api key or token subject.
+func ImportLimitMiddleware(limits config.LimitsConfig) gin.HandlerFunc {
+	slots := &importSlots{max: limits.MaxImports, maxClient: limits.MaxClientImports, clients: map[string]int{}}
+
+	return func(c *gin.Context) {
+		client := clientKey(c)
+		if limit := slots.acquire(client); limit != "" {
+			metrics.ImportsRejected.WithLabelValues(limit).Inc()
+			services.RetryableError(c, services.CodeTooManyImports, limits.ImportRetryAfter, "Too many concurrent imports, retry later")
+			return
+		}
+		defer slots.release(client)
+
+		c.Next()
+	}
+}
+
+// Take an import slot of the client, returns the limit reached if there is none
+func (slots *importSlots) acquire(client string) (limit string) {
+	slots.mutex.Lock()
+	defer slots.mutex.Unlock()
+
+	if slots.max > 0 && slots.running >= slots.max {
+		return "global"
+	}
+	if slots.maxClient > 0 && slots.clients[client] >= slots.maxClient {
+		return "client"
+	}
+	slots.running++
+	slots.clients[client]++
+	metrics.ImportsInFlight.Inc()
+	return ""
+}
+
+func (slots *importSlots) release(client string) {
+	slots.mutex.Lock()
+	defer slots.mutex.Unlock()
+
+	slots.running--
+	if slots.clients[client]--; slots.clients[client] <= 0 {
+		delete(slots.clients, client)
+	}
+	metrics.ImportsInFlight.Dec()
+}
+
+// Client of the request for the limits, the authenticated subject or else the client IP
+func clientKey(c *gin.Context) string {
+	if principal, ok := auth.FromContext(c.Request.Context()); ok && principal.Subject != "" {
+		return principal.Subject
+	}
+	return "ip:" + c.ClientIP()
+}
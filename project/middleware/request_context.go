@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"csvapi-test/audit"
+	"csvapi-test/logging"
+	"encoding/hex"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header carrying the correlation id of a request, generated when the client does not send one
+const RequestIDHeader = "X-Request-ID"
+
+// Attach the request id and the client as actor to the request context, for auditing and logging
+func RequestContextMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > 64 {
+			requestID = newRequestID()
+		}
+		c.Header(RequestIDHeader, requestID)
+
+		ctx := audit.WithInfo(c.Request.Context(), audit.Info{Actor: c.ClientIP(), RequestID: requestID})
+		ctx = logging.With(ctx, slog.String("request_id", requestID))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
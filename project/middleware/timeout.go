@@ -1,23 +1,21 @@
 package middleware
 
 import (
-	"net/http"
+	"csvapi-test/services"
 	"time"
 
 	"github.com/gin-contrib/timeout"
 	"github.com/gin-gonic/gin"
 )
 
-func testResponse(c *gin.Context) {
-	c.String(http.StatusRequestTimeout, "timeout")
-}
-
-func TimeoutMiddleware() gin.HandlerFunc {
+func TimeoutMiddleware(requestTimeout time.Duration) gin.HandlerFunc {
 	return timeout.New(
-		timeout.WithTimeout(3*time.Minute),
+		timeout.WithTimeout(requestTimeout),
 		timeout.WithHandler(func(c *gin.Context) {
 			c.Next()
 		}),
-		timeout.WithResponse(testResponse),
+		timeout.WithResponse(func(c *gin.Context) {
+			services.ErrorResponse(c, services.CodeRequestTimeout, "The request did not complete within "+requestTimeout.String())
+		}),
 	)
 }
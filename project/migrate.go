@@ -0,0 +1,78 @@
+package main
+
+import (
+	"csvapi-test/config"
+	"csvapi-test/model"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const migrateUsage = `Usage: migrate <command>
+
+Commands:
+  up          Apply all pending migrations
+  down [n]    Revert the last n applied migrations (default 1)
+  status      List migrations and when they were applied`
+
+// Run the migrate subcommand against the live database and return the process exit code
+func runMigrate(dbConfig config.DatabaseConfig, args []string) int {
+	if len(args) == 0 {
+		fmt.Println(migrateUsage)
+		return 2
+	}
+
+	db, err := model.Connect(dbConfig)
+	if err != nil {
+		fmt.Println("Database connection error:", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := model.MigrateUp(db)
+		for _, migration := range applied {
+			fmt.Printf("applied %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Println("Error from the migration:", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				fmt.Println("down expects a positive number of steps")
+				return 2
+			}
+		}
+		reverted, err := model.MigrateDown(db, steps)
+		for _, migration := range reverted {
+			fmt.Printf("reverted %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Println("Error from the migration:", err)
+			return 1
+		}
+	case "status":
+		states, err := model.MigrationStatus(db)
+		if err != nil {
+			fmt.Println("Error from the migration:", err)
+			return 1
+		}
+		for _, state := range states {
+			appliedAt := "pending"
+			if state.AppliedAt != nil {
+				appliedAt = state.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%d_%s\t%s\n", state.Version, state.Name, appliedAt)
+		}
+	default:
+		fmt.Println(migrateUsage)
+		return 2
+	}
+	return 0
+}
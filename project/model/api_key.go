@@ -0,0 +1,48 @@
+package model
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Audit actions of api key management
+const (
+	AuditAPIKeyCreate = "api_key.create"
+	AuditAPIKeyRevoke = "api_key.revoke"
+)
+
+// Scopes granted to an api key, stored space separated
+type ScopeList []string
+
+func (scopes ScopeList) Value() (driver.Value, error) {
+	return strings.Join(scopes, " "), nil
+}
+
+func (scopes *ScopeList) Scan(value interface{}) error {
+	switch value := value.(type) {
+	case string:
+		*scopes = strings.Fields(value)
+	case []byte:
+		*scopes = strings.Fields(string(value))
+	case nil:
+		*scopes = nil
+	default:
+		return fmt.Errorf("unsupported scopes type %T", value)
+	}
+	return nil
+}
+
+// Api key of a client, the key itself is only known to the client
+type APIKey struct {
+	ID         uint64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name       string     `json:"name" gorm:"not null"`
+	Tenant     string     `json:"tenant" gorm:"not null"` // Tenant whose data the key accesses, empty for the default tenant
+	Prefix     string     `json:"prefix" gorm:"not null"` // Leading characters of the key, to recognise it
+	KeyHash    string     `json:"-" gorm:"not null;uniqueIndex"`
+	Scopes     ScopeList  `json:"scopes" gorm:"type:text;not null"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"not null"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
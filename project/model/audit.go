@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+// Audited mutation actions
+const (
+	AuditCandleUpdate      = "candle.update"
+	AuditCandleDelete      = "candle.delete"
+	AuditCandleDeleteRange = "candle.delete_range"
+	AuditCandleImport      = "candle.import"
+	AuditCandleRetention   = "candle.retention"
+
+	AuditCandleImportInterrupted = "candle.import_interrupted" // Rolled back by a shutdown, the upload can be retried
+)
+
+// Summary of an imported csv file recorded with its audit entry
+type ImportSummary struct {
+	FileName string `json:"file_name"`
+	FileSize int64  `json:"file_size"`
+	Rows     int    `json:"rows"` // Saved rows, or read rows of an interrupted import
+}
+
+// Counts of a committed csv upload, sent in the upload response
+type ImportResult struct {
+	CSVLinesRead   string `json:"csvLinesRead"`
+	TotalSavedRows string `json:"totalSavedRows"`
+}
+
+// Status of an import
+const (
+	ImportRunning     = "running"
+	ImportCommitted   = "committed"
+	ImportInterrupted = "interrupted" // Rolled back by a shutdown, the upload can be retried
+)
+
+// Status of the import of an upload, found by the request id of the upload
+type ImportStatus struct {
+	RequestID  string         `json:"request_id"`
+	Status     string         `json:"status"`
+	Summary    *ImportSummary `json:"summary,omitempty"`     // Set once the import finished
+	FinishedAt *time.Time     `json:"finished_at,omitempty"` // Set once the import finished
+}
+
+// Append-only record of a data mutation
+type AuditEntry struct {
+	ID        uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
+	Actor     string    `json:"actor" gorm:"not null"` // Client or process that made the mutation
+	Tenant    string    `json:"tenant" gorm:"not null"`
+	Action    string    `json:"action" gorm:"not null"`
+	Target    string    `json:"target" gorm:"not null"` // Mutated resource e.g ohcls/42 or ohcls?symbol=BTCUSDT&from=1&to=2
+	Before    string    `json:"before"`                 // Json summary of the data before the mutation
+	After     string    `json:"after"`                  // Json summary of the data after the mutation
+	RequestID string    `json:"request_id"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null"`
+}
@@ -1,65 +1,126 @@
 package model
 
 import (
+	"csvapi-test/config"
+	"csvapi-test/logging"
+	"csvapi-test/tracing"
 	"fmt"
-	"os"
+	"net"
+	"net/url"
+	"strconv"
 
+	mysqlconfig "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 
 	"gorm.io/gorm"
 )
 
-// Current database instance
-var DB *gorm.DB
-
-// Establishes Database connection and migrations
-// @instanceType  indicates the type of DB to use
-func DbConfig(instanceType string) {
-	var (
-		db  *gorm.DB
-		err error
-	)
-	if instanceType == "LIVE_CONNECTION" {
-		db, err = PostgressInstance()
-	} else {
-		db, err = SQLiteInstance()
+// Establishes Database connection and checks the schema is migrated
+// @dbConfig  indicates the type of DB to use and its connection settings
+func DbConfig(dbConfig config.DatabaseConfig) (*gorm.DB, error) {
+	db, err := Connect(dbConfig)
+	if err != nil {
+		return nil, fmt.Errorf("database connection error: %w", err)
 	}
 
-	if err != nil {
-		fmt.Println(err)
-		panic("Database connection error: " + err.Error())
+	// The in memory database starts empty on every run, so it is migrated on connection
+	if dbConfig.Driver == "sqlite" && dbConfig.Path == "" {
+		if _, err = MigrateUp(db); err != nil {
+			return nil, fmt.Errorf("error from the migration: %w", err)
+		}
 	}
 
-	err = db.AutoMigrate(
-		&Ohcl{},
-	)
-	if err != nil {
-		fmt.Println("Error from the migration", err.Error())
-		panic("Error from the migration")
+	// Refuse to run against a schema behind the known migrations, run `migrate up` first
+	if err = RequireMigrated(db); err != nil {
+		return nil, err
 	}
 
-	DB = db
+	return db, nil
 }
 
-func PostgressInstance() (*gorm.DB, error) {
+// Open a database connection without checking migrations
+// @dbConfig  indicates the type of DB to use and its connection settings
+func Connect(dbConfig config.DatabaseConfig) (db *gorm.DB, err error) {
+	switch dbConfig.Driver {
+	case "postgres":
+		db, err = PostgressInstance(dbConfig)
+	case "mysql":
+		db, err = MySQLInstance(dbConfig)
+	default:
+		db, err = SQLiteInstance(dbConfig)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	host := os.Getenv("DB_HOST")
-	user := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASSWORD")
-	dbname := os.Getenv("DB_NAME")
-	port := os.Getenv("DB_PORT")
+	// Trace every statement as a child span of the request or import
+	if err = db.Use(tracing.GormPlugin{}); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
 
+func PostgressInstance(dbConfig config.DatabaseConfig) (*gorm.DB, error) {
 	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s",
-		host, user, password, dbname, port)
+		dbConfig.Host, dbConfig.User, dbConfig.Password, dbConfig.Name, dbConfig.Port)
 
 	return gorm.Open(postgres.Open(dsn), &gorm.Config{
 		PrepareStmt: true,
+		Logger:      logging.NewGormLogger(dbConfig.SlowQueryThreshold),
 	})
 }
 
-func SQLiteInstance() (*gorm.DB, error) {
-	return gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{
+func MySQLInstance(dbConfig config.DatabaseConfig) (*gorm.DB, error) {
+	mysqlConfig := mysqlconfig.NewConfig()
+	mysqlConfig.Net = "tcp"
+	mysqlConfig.Addr = net.JoinHostPort(dbConfig.Host, dbConfig.Port)
+	mysqlConfig.User = dbConfig.User
+	mysqlConfig.Passwd = dbConfig.Password
+	mysqlConfig.DBName = dbConfig.Name
+	mysqlConfig.ParseTime = true
+	// Report the matched rows instead of the changed ones, gorm Save inserts the row again when an update affects none
+	mysqlConfig.ClientFoundRows = true
+	mysqlConfig.Params = map[string]string{"charset": "utf8mb4"}
+
+	return gorm.Open(mysql.Open(mysqlConfig.FormatDSN()), &gorm.Config{
+		PrepareStmt: true,
+		Logger:      logging.NewGormLogger(dbConfig.SlowQueryThreshold),
+	})
+}
+
+// Sqlite database stored in dbConfig.Path, or in memory if the path is empty.
+// In memory databases with different names are isolated from each other.
+func SQLiteInstance(dbConfig config.DatabaseConfig) (*gorm.DB, error) {
+	if dbConfig.Path == "" {
+		dsn := "file::memory:?cache=shared"
+		if dbConfig.Name != "" {
+			dsn = fmt.Sprintf("file:%s?mode=memory&cache=shared", dbConfig.Name)
+		}
+		return gorm.Open(sqlite.Open(dsn), &gorm.Config{
+			PrepareStmt: true,
+			Logger:      logging.NewGormLogger(dbConfig.SlowQueryThreshold),
+		})
+	}
+
+	// Pragmas applied on every connection: write ahead log lets reads run alongside an import,
+	// NORMAL sync is durable with WAL and avoids a fsync per transaction, immediate transactions
+	// take the write lock upfront instead of failing with SQLITE_BUSY when upgrading
+	params := url.Values{}
+	params.Set("_journal_mode", "WAL")
+	params.Set("_synchronous", "NORMAL")
+	params.Set("_busy_timeout", strconv.FormatInt(dbConfig.BusyTimeout.Milliseconds(), 10))
+	params.Set("_foreign_keys", "1")
+	params.Set("_txlock", "immediate")
+	if dbConfig.CacheSizeKB > 0 {
+		params.Set("_cache_size", strconv.Itoa(-dbConfig.CacheSizeKB)) // negative size is in KiB
+	}
+	// The path is escaped, so a ? or # in it is not read as the start of the pragmas
+	dsn := (&url.URL{Scheme: "file", Opaque: (&url.URL{Path: dbConfig.Path}).EscapedPath(), RawQuery: params.Encode()}).String()
+
+	return gorm.Open(sqlite.Open(dsn), &gorm.Config{
 		PrepareStmt: true,
+		Logger:      logging.NewGormLogger(dbConfig.SlowQueryThreshold),
 	})
 }
@@ -0,0 +1,214 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Advisory lock held while migrating, so concurrent replicas run migrations one at a time
+const (
+	migrationLockKey  = 7263541209          // Postgres advisory lock key
+	migrationLockName = "csvapi_migrations" // Mysql named lock
+)
+
+// Versioned schema change, Up and Down run inside a transaction
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// Row of the schema_migrations table recording an applied migration
+type SchemaMigration struct {
+	Version   int       `json:"version" gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `json:"name" gorm:"not null"`
+	AppliedAt time.Time `json:"applied_at" gorm:"not null"`
+}
+
+// Applied state of a known migration
+type MigrationState struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// Returned when the database schema is behind the known migrations
+var ErrSchemaNotMigrated = errors.New("database schema is not migrated")
+
+// Run the statements registered for the current database dialect, dialects without statements are skipped
+func execForDialect(statements map[string][]string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		for _, statement := range statements[tx.Dialector.Name()] {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func sortedMigrations() []Migration {
+	migrations := make([]Migration, len(Migrations))
+	copy(migrations, Migrations)
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations
+}
+
+func createMigrationTable(db *gorm.DB) error {
+	return db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name varchar(255) NOT NULL,
+		applied_at timestamp NOT NULL
+	)`).Error
+}
+
+func appliedMigrations(db *gorm.DB) (map[int]SchemaMigration, error) {
+	var rows []SchemaMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// Run fc on a single connection holding the migration lock
+func withMigrationLock(db *gorm.DB, fc func(conn *gorm.DB) error) error {
+	return db.Connection(func(conn *gorm.DB) (err error) {
+		var lockSQL, unlockSQL string
+		var lockKey interface{}
+		switch conn.Dialector.Name() {
+		case "postgres":
+			lockSQL, unlockSQL, lockKey = "SELECT pg_advisory_lock(?)", "SELECT pg_advisory_unlock(?)", migrationLockKey
+		case "mysql":
+			lockSQL, unlockSQL, lockKey = "SELECT GET_LOCK(?, -1)", "SELECT RELEASE_LOCK(?)", migrationLockName
+		default:
+			return fc(conn)
+		}
+		if err = conn.Exec(lockSQL, lockKey).Error; err != nil {
+			return err
+		}
+		defer func() {
+			if unlockErr := conn.Exec(unlockSQL, lockKey).Error; err == nil {
+				err = unlockErr
+			}
+		}()
+		return fc(conn)
+	})
+}
+
+// Apply every pending migration in version order, returns the applied migrations
+func MigrateUp(db *gorm.DB) (applied []Migration, err error) {
+	err = withMigrationLock(db, func(conn *gorm.DB) error {
+		if err := createMigrationTable(conn); err != nil {
+			return err
+		}
+		done, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range sortedMigrations() {
+			if _, found := done[migration.Version]; found {
+				continue
+			}
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := migration.Up(tx); err != nil {
+					return err
+				}
+				return tx.Create(&SchemaMigration{
+					Version:   migration.Version,
+					Name:      migration.Name,
+					AppliedAt: time.Now().UTC(),
+				}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Revert the last steps applied migrations in reverse version order, returns the reverted migrations
+func MigrateDown(db *gorm.DB, steps int) (reverted []Migration, err error) {
+	err = withMigrationLock(db, func(conn *gorm.DB) error {
+		if err := createMigrationTable(conn); err != nil {
+			return err
+		}
+		done, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		migrations := sortedMigrations()
+		for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := migrations[i]
+			if _, found := done[migration.Version]; !found {
+				continue
+			}
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := migration.Down(tx); err != nil {
+					return err
+				}
+				return tx.Delete(&SchemaMigration{}, migration.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// List every known migration with the time it was applied, if any
+func MigrationStatus(db *gorm.DB) (states []MigrationState, err error) {
+	done := map[int]SchemaMigration{}
+	if db.Migrator().HasTable(&SchemaMigration{}) {
+		if done, err = appliedMigrations(db); err != nil {
+			return nil, err
+		}
+	}
+	for _, migration := range sortedMigrations() {
+		state := MigrationState{Version: migration.Version, Name: migration.Name}
+		if row, found := done[migration.Version]; found {
+			appliedAt := row.AppliedAt
+			state.AppliedAt = &appliedAt
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// Return ErrSchemaNotMigrated listing the pending migrations if the schema is not current
+func RequireMigrated(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return fmt.Errorf("%w: schema_migrations table is missing", ErrSchemaNotMigrated)
+	}
+	done, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+	var pending []string
+	for _, migration := range sortedMigrations() {
+		if _, found := done[migration.Version]; !found {
+			pending = append(pending, fmt.Sprintf("%d_%s", migration.Version, migration.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: pending %s", ErrSchemaNotMigrated, strings.Join(pending, ", "))
+	}
+	return nil
+}
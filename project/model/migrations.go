@@ -0,0 +1,569 @@
+package model
+
+// Ordered schema migrations, append new migrations with the next version.
+// Applied migrations must never be edited, add a new one instead.
+// Mysql commits DDL statements implicitly, so a failed mysql migration is not rolled back.
+var Migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_ohcls",
+		Up: execForDialect(map[string][]string{
+			"postgres": {
+				`CREATE TABLE IF NOT EXISTS ohcls (
+					id bigserial PRIMARY KEY,
+					unix bigint NOT NULL,
+					symbol text NOT NULL,
+					open real NOT NULL,
+					high real NOT NULL,
+					low real NOT NULL,
+					close real NOT NULL
+				)`,
+			},
+			"mysql": {
+				`CREATE TABLE IF NOT EXISTS ohcls (
+					id bigint unsigned AUTO_INCREMENT PRIMARY KEY,
+					unix bigint unsigned NOT NULL,
+					symbol varchar(64) NOT NULL,
+					open float NOT NULL,
+					high float NOT NULL,
+					low float NOT NULL,
+					close float NOT NULL
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			},
+			"sqlite": {
+				`CREATE TABLE IF NOT EXISTS ohcls (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					unix integer NOT NULL,
+					symbol text NOT NULL,
+					open real NOT NULL,
+					high real NOT NULL,
+					low real NOT NULL,
+					close real NOT NULL
+				)`,
+			},
+		}),
+		Down: execForDialect(map[string][]string{
+			"postgres": {`DROP TABLE IF EXISTS ohcls`},
+			"mysql":    {`DROP TABLE IF EXISTS ohcls`},
+			"sqlite":   {`DROP TABLE IF EXISTS ohcls`},
+		}),
+	},
+	{
+		// Adding a stored generated column rewrites the table, which backfills
+		// the search vector for every existing row in the same statement.
+		// Mysql has no tsvector, a stored text column with a FULLTEXT index is used instead.
+		Version: 2,
+		Name:    "ohcls_search_vector",
+		Up: execForDialect(map[string][]string{
+			"postgres": {
+				`ALTER TABLE ohcls ADD COLUMN IF NOT EXISTS search_vector tsvector
+					GENERATED ALWAYS AS (
+						to_tsvector('english'::regconfig,
+							unix::text || ' ' || symbol || ' ' || open::text || ' ' ||
+							high::text || ' ' || low::text || ' ' || close::text)
+					) STORED`,
+				`CREATE INDEX IF NOT EXISTS idx_ohcls_search_vector ON ohcls USING GIN (search_vector)`,
+			},
+			"mysql": {
+				`ALTER TABLE ohcls ADD COLUMN search_text varchar(255)
+					GENERATED ALWAYS AS (CONCAT_WS(' ', unix, symbol, open, high, low, close)) STORED`,
+				`CREATE FULLTEXT INDEX idx_ohcls_search_text ON ohcls (search_text)`,
+			},
+		}),
+		Down: execForDialect(map[string][]string{
+			"postgres": {
+				`DROP INDEX IF EXISTS idx_ohcls_search_vector`,
+				`ALTER TABLE ohcls DROP COLUMN IF EXISTS search_vector`,
+			},
+			"mysql": {
+				`DROP INDEX idx_ohcls_search_text ON ohcls`,
+				`ALTER TABLE ohcls DROP COLUMN search_text`,
+			},
+		}),
+	},
+	{
+		// Range partitions of one month on the unix milliseconds column, existing rows
+		// are copied into partitions created for every month they cover.
+		// There is no default partition, the importer creates partitions ahead of its inserts.
+		// The copy runs in the migration transaction and blocks ohcls until it commits, see the readme migration notes.
+		Version: 3,
+		Name:    "partition_ohcls_by_month",
+		Up: execForDialect(map[string][]string{
+			"postgres": {
+				`ALTER TABLE ohcls RENAME TO ohcls_unpartitioned`,
+				`ALTER TABLE ohcls_unpartitioned RENAME CONSTRAINT ohcls_pkey TO ohcls_unpartitioned_pkey`,
+				`ALTER INDEX IF EXISTS idx_ohcls_search_vector RENAME TO idx_ohcls_unpartitioned_search_vector`,
+				`CREATE TABLE ohcls (
+					id bigint NOT NULL DEFAULT nextval('ohcls_id_seq'),
+					unix bigint NOT NULL,
+					symbol text NOT NULL,
+					open real NOT NULL,
+					high real NOT NULL,
+					low real NOT NULL,
+					close real NOT NULL,
+					search_vector tsvector GENERATED ALWAYS AS (
+						to_tsvector('english'::regconfig,
+							unix::text || ' ' || symbol || ' ' || open::text || ' ' ||
+							high::text || ' ' || low::text || ' ' || close::text)
+					) STORED,
+					PRIMARY KEY (id, unix)
+				) PARTITION BY RANGE (unix)`,
+				`CREATE INDEX idx_ohcls_search_vector ON ohcls USING GIN (search_vector)`,
+				`CREATE INDEX idx_ohcls_symbol_unix ON ohcls (symbol, unix)`,
+				postgresEnsurePartitionFunction,
+				`SELECT ohcls_ensure_partition(month_unix) FROM (
+					SELECT DISTINCT (extract(epoch FROM date_trunc('month', to_timestamp(unix / 1000.0) AT TIME ZONE 'UTC')) * 1000)::bigint AS month_unix
+					FROM ohcls_unpartitioned
+				) months`,
+				`INSERT INTO ohcls (id, unix, symbol, open, high, low, close)
+					SELECT id, unix, symbol, open, high, low, close FROM ohcls_unpartitioned`,
+				`ALTER SEQUENCE ohcls_id_seq OWNED BY ohcls.id`,
+				`DROP TABLE ohcls_unpartitioned`,
+			},
+		}),
+		Down: execForDialect(map[string][]string{
+			"postgres": {
+				`ALTER TABLE ohcls RENAME TO ohcls_partitioned`,
+				`ALTER TABLE ohcls_partitioned RENAME CONSTRAINT ohcls_pkey TO ohcls_partitioned_pkey`,
+				`ALTER INDEX idx_ohcls_search_vector RENAME TO idx_ohcls_partitioned_search_vector`,
+				`CREATE TABLE ohcls (
+					id bigint PRIMARY KEY DEFAULT nextval('ohcls_id_seq'),
+					unix bigint NOT NULL,
+					symbol text NOT NULL,
+					open real NOT NULL,
+					high real NOT NULL,
+					low real NOT NULL,
+					close real NOT NULL,
+					search_vector tsvector GENERATED ALWAYS AS (
+						to_tsvector('english'::regconfig,
+							unix::text || ' ' || symbol || ' ' || open::text || ' ' ||
+							high::text || ' ' || low::text || ' ' || close::text)
+					) STORED
+				)`,
+				`CREATE INDEX idx_ohcls_search_vector ON ohcls USING GIN (search_vector)`,
+				`INSERT INTO ohcls (id, unix, symbol, open, high, low, close)
+					SELECT id, unix, symbol, open, high, low, close FROM ohcls_partitioned`,
+				`ALTER SEQUENCE ohcls_id_seq OWNED BY ohcls.id`,
+				`DROP TABLE ohcls_partitioned`,
+				`DROP FUNCTION IF EXISTS ohcls_ensure_partition(bigint)`,
+			},
+		}),
+	},
+	{
+		Version: 4,
+		Name:    "create_retention_tables",
+		Up: execForDialect(map[string][]string{
+			"postgres": {
+				`CREATE TABLE ohcl_rollups (
+					id bigserial PRIMARY KEY,
+					symbol text NOT NULL,
+					resolution bigint NOT NULL,
+					unix bigint NOT NULL,
+					open real NOT NULL,
+					high real NOT NULL,
+					low real NOT NULL,
+					close real NOT NULL,
+					source_rows bigint NOT NULL,
+					UNIQUE (symbol, resolution, unix)
+				)`,
+				`CREATE TABLE retention_runs (
+					id bigserial PRIMARY KEY,
+					policy text NOT NULL,
+					symbol text NOT NULL,
+					cutoff bigint NOT NULL,
+					status text NOT NULL,
+					rows_processed bigint NOT NULL,
+					rollups_written bigint NOT NULL,
+					rows_deleted bigint NOT NULL,
+					archive_file text NOT NULL DEFAULT '',
+					error text NOT NULL DEFAULT '',
+					started_at timestamptz NOT NULL,
+					finished_at timestamptz
+				)`,
+			},
+			"mysql": {
+				`CREATE TABLE ohcl_rollups (
+					id bigint unsigned AUTO_INCREMENT PRIMARY KEY,
+					symbol varchar(64) NOT NULL,
+					resolution bigint NOT NULL,
+					unix bigint unsigned NOT NULL,
+					open float NOT NULL,
+					high float NOT NULL,
+					low float NOT NULL,
+					close float NOT NULL,
+					source_rows bigint NOT NULL,
+					UNIQUE KEY idx_ohcl_rollups_bucket (symbol, resolution, unix)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+				`CREATE TABLE retention_runs (
+					id bigint unsigned AUTO_INCREMENT PRIMARY KEY,
+					policy varchar(255) NOT NULL,
+					symbol varchar(64) NOT NULL,
+					cutoff bigint unsigned NOT NULL,
+					status varchar(16) NOT NULL,
+					rows_processed bigint NOT NULL,
+					rollups_written bigint NOT NULL,
+					rows_deleted bigint NOT NULL,
+					archive_file text NOT NULL,
+					error text NOT NULL,
+					started_at datetime(3) NOT NULL,
+					finished_at datetime(3) NULL
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			},
+			"sqlite": {
+				`CREATE TABLE ohcl_rollups (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					symbol text NOT NULL,
+					resolution integer NOT NULL,
+					unix integer NOT NULL,
+					open real NOT NULL,
+					high real NOT NULL,
+					low real NOT NULL,
+					close real NOT NULL,
+					source_rows integer NOT NULL,
+					UNIQUE (symbol, resolution, unix)
+				)`,
+				`CREATE TABLE retention_runs (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					policy text NOT NULL,
+					symbol text NOT NULL,
+					cutoff integer NOT NULL,
+					status text NOT NULL,
+					rows_processed integer NOT NULL,
+					rollups_written integer NOT NULL,
+					rows_deleted integer NOT NULL,
+					archive_file text NOT NULL DEFAULT '',
+					error text NOT NULL DEFAULT '',
+					started_at datetime NOT NULL,
+					finished_at datetime
+				)`,
+			},
+		}),
+		Down: execForDialect(map[string][]string{
+			"postgres": {`DROP TABLE IF EXISTS retention_runs`, `DROP TABLE IF EXISTS ohcl_rollups`},
+			"mysql":    {`DROP TABLE IF EXISTS retention_runs`, `DROP TABLE IF EXISTS ohcl_rollups`},
+			"sqlite":   {`DROP TABLE IF EXISTS retention_runs`, `DROP TABLE IF EXISTS ohcl_rollups`},
+		}),
+	},
+	{
+		Version: 5,
+		Name:    "create_audit_entries",
+		Up: execForDialect(map[string][]string{
+			"postgres": {
+				`CREATE TABLE audit_entries (
+					id bigserial PRIMARY KEY,
+					action text NOT NULL,
+					target text NOT NULL,
+					before text NOT NULL DEFAULT '',
+					after text NOT NULL DEFAULT '',
+					created_at timestamptz NOT NULL
+				)`,
+			},
+			"mysql": {
+				`CREATE TABLE audit_entries (
+					id bigint unsigned AUTO_INCREMENT PRIMARY KEY,
+					action varchar(64) NOT NULL,
+					target varchar(255) NOT NULL,
+					` + "`before`" + ` text NOT NULL,
+					` + "`after`" + ` text NOT NULL,
+					created_at datetime(3) NOT NULL
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			},
+			"sqlite": {
+				`CREATE TABLE audit_entries (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					action text NOT NULL,
+					target text NOT NULL,
+					before text NOT NULL DEFAULT '',
+					after text NOT NULL DEFAULT '',
+					created_at datetime NOT NULL
+				)`,
+			},
+		}),
+		Down: execForDialect(map[string][]string{
+			"postgres": {`DROP TABLE IF EXISTS audit_entries`},
+			"mysql":    {`DROP TABLE IF EXISTS audit_entries`},
+			"sqlite":   {`DROP TABLE IF EXISTS audit_entries`},
+		}),
+	},
+	{
+		Version: 6,
+		Name:    "audit_entries_actor_and_append_only",
+		Up: execForDialect(map[string][]string{
+			"postgres": {
+				`ALTER TABLE audit_entries
+					ADD COLUMN actor text NOT NULL DEFAULT '',
+					ADD COLUMN request_id text NOT NULL DEFAULT ''`,
+				`CREATE INDEX idx_audit_entries_created_at ON audit_entries (created_at)`,
+				`CREATE INDEX idx_audit_entries_action_created_at ON audit_entries (action, created_at)`,
+				`CREATE FUNCTION audit_entries_append_only() RETURNS trigger AS $$
+				BEGIN
+					RAISE EXCEPTION 'audit entries are append-only';
+				END;
+				$$ LANGUAGE plpgsql`,
+				`CREATE TRIGGER audit_entries_append_only BEFORE UPDATE OR DELETE ON audit_entries
+					FOR EACH ROW EXECUTE FUNCTION audit_entries_append_only()`,
+			},
+			"mysql": {
+				`ALTER TABLE audit_entries
+					ADD COLUMN actor varchar(255) NOT NULL DEFAULT '',
+					ADD COLUMN request_id varchar(64) NOT NULL DEFAULT '',
+					ADD INDEX idx_audit_entries_created_at (created_at),
+					ADD INDEX idx_audit_entries_action_created_at (action, created_at)`,
+				`CREATE TRIGGER audit_entries_no_update BEFORE UPDATE ON audit_entries
+					FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit entries are append-only'`,
+				`CREATE TRIGGER audit_entries_no_delete BEFORE DELETE ON audit_entries
+					FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit entries are append-only'`,
+			},
+			"sqlite": {
+				`ALTER TABLE audit_entries ADD COLUMN actor text NOT NULL DEFAULT ''`,
+				`ALTER TABLE audit_entries ADD COLUMN request_id text NOT NULL DEFAULT ''`,
+				`CREATE INDEX idx_audit_entries_created_at ON audit_entries (created_at)`,
+				`CREATE INDEX idx_audit_entries_action_created_at ON audit_entries (action, created_at)`,
+				`CREATE TRIGGER audit_entries_no_update BEFORE UPDATE ON audit_entries
+				BEGIN
+					SELECT RAISE(ABORT, 'audit entries are append-only');
+				END`,
+				`CREATE TRIGGER audit_entries_no_delete BEFORE DELETE ON audit_entries
+				BEGIN
+					SELECT RAISE(ABORT, 'audit entries are append-only');
+				END`,
+			},
+		}),
+		Down: execForDialect(map[string][]string{
+			"postgres": {
+				`DROP TRIGGER IF EXISTS audit_entries_append_only ON audit_entries`,
+				`DROP FUNCTION IF EXISTS audit_entries_append_only()`,
+				`DROP INDEX IF EXISTS idx_audit_entries_action_created_at`,
+				`DROP INDEX IF EXISTS idx_audit_entries_created_at`,
+				`ALTER TABLE audit_entries DROP COLUMN request_id, DROP COLUMN actor`,
+			},
+			"mysql": {
+				`DROP TRIGGER IF EXISTS audit_entries_no_delete`,
+				`DROP TRIGGER IF EXISTS audit_entries_no_update`,
+				`ALTER TABLE audit_entries
+					DROP INDEX idx_audit_entries_action_created_at,
+					DROP INDEX idx_audit_entries_created_at,
+					DROP COLUMN request_id,
+					DROP COLUMN actor`,
+			},
+			"sqlite": {
+				`DROP TRIGGER IF EXISTS audit_entries_no_delete`,
+				`DROP TRIGGER IF EXISTS audit_entries_no_update`,
+				`DROP INDEX IF EXISTS idx_audit_entries_action_created_at`,
+				`DROP INDEX IF EXISTS idx_audit_entries_created_at`,
+				`ALTER TABLE audit_entries DROP COLUMN request_id`,
+				`ALTER TABLE audit_entries DROP COLUMN actor`,
+			},
+		}),
+	},
+	{
+		Version: 7,
+		Name:    "create_api_keys",
+		Up: execForDialect(map[string][]string{
+			"postgres": {
+				`CREATE TABLE api_keys (
+					id bigserial PRIMARY KEY,
+					name text NOT NULL,
+					prefix text NOT NULL,
+					key_hash char(64) NOT NULL UNIQUE,
+					scopes text NOT NULL,
+					created_at timestamptz NOT NULL,
+					last_used_at timestamptz,
+					revoked_at timestamptz
+				)`,
+			},
+			"mysql": {
+				`CREATE TABLE api_keys (
+					id bigint unsigned AUTO_INCREMENT PRIMARY KEY,
+					name varchar(255) NOT NULL,
+					prefix varchar(16) NOT NULL,
+					key_hash char(64) NOT NULL UNIQUE,
+					scopes varchar(255) NOT NULL,
+					created_at datetime(3) NOT NULL,
+					last_used_at datetime(3) NULL,
+					revoked_at datetime(3) NULL
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			},
+			"sqlite": {
+				`CREATE TABLE api_keys (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					name text NOT NULL,
+					prefix text NOT NULL,
+					key_hash text NOT NULL UNIQUE,
+					scopes text NOT NULL,
+					created_at datetime NOT NULL,
+					last_used_at datetime,
+					revoked_at datetime
+				)`,
+			},
+		}),
+		Down: execForDialect(map[string][]string{
+			"postgres": {`DROP TABLE IF EXISTS api_keys`},
+			"mysql":    {`DROP TABLE IF EXISTS api_keys`},
+			"sqlite":   {`DROP TABLE IF EXISTS api_keys`},
+		}),
+	},
+	{
+		// The default tenant is the empty string, existing rows and keys belong to it.
+		// Reverting keeps the candles of every tenant and only the rollups of the default tenant.
+		Version: 8,
+		Name:    "add_tenants",
+		Up: execForDialect(map[string][]string{
+			"postgres": {
+				`ALTER TABLE ohcls ADD COLUMN tenant text NOT NULL DEFAULT ''`,
+				`CREATE INDEX idx_ohcls_tenant_symbol_unix ON ohcls (tenant, symbol, unix)`,
+				`DROP INDEX IF EXISTS idx_ohcls_symbol_unix`,
+				`ALTER TABLE ohcl_rollups ADD COLUMN tenant text NOT NULL DEFAULT ''`,
+				`ALTER TABLE ohcl_rollups DROP CONSTRAINT ohcl_rollups_symbol_resolution_unix_key`,
+				`ALTER TABLE ohcl_rollups ADD CONSTRAINT ohcl_rollups_bucket UNIQUE (tenant, symbol, resolution, unix)`,
+				`ALTER TABLE retention_runs ADD COLUMN tenant text NOT NULL DEFAULT ''`,
+				`ALTER TABLE api_keys ADD COLUMN tenant text NOT NULL DEFAULT ''`,
+				`ALTER TABLE audit_entries ADD COLUMN tenant text NOT NULL DEFAULT ''`,
+				`CREATE INDEX idx_audit_entries_tenant_created_at ON audit_entries (tenant, created_at)`,
+			},
+			"mysql": {
+				`ALTER TABLE ohcls
+					ADD COLUMN tenant varchar(64) NOT NULL DEFAULT '',
+					ADD INDEX idx_ohcls_tenant_symbol_unix (tenant, symbol, unix)`,
+				`ALTER TABLE ohcl_rollups
+					ADD COLUMN tenant varchar(64) NOT NULL DEFAULT '',
+					DROP INDEX idx_ohcl_rollups_bucket,
+					ADD UNIQUE KEY idx_ohcl_rollups_bucket (tenant, symbol, resolution, unix)`,
+				`ALTER TABLE retention_runs ADD COLUMN tenant varchar(64) NOT NULL DEFAULT ''`,
+				`ALTER TABLE api_keys ADD COLUMN tenant varchar(64) NOT NULL DEFAULT ''`,
+				`ALTER TABLE audit_entries
+					ADD COLUMN tenant varchar(64) NOT NULL DEFAULT '',
+					ADD INDEX idx_audit_entries_tenant_created_at (tenant, created_at)`,
+			},
+			"sqlite": {
+				`ALTER TABLE ohcls ADD COLUMN tenant text NOT NULL DEFAULT ''`,
+				`CREATE INDEX idx_ohcls_tenant_symbol_unix ON ohcls (tenant, symbol, unix)`,
+				// Sqlite cannot drop a table constraint, the rollups table is rebuilt with the new unique key
+				`CREATE TABLE ohcl_rollups_tenant (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					tenant text NOT NULL DEFAULT '',
+					symbol text NOT NULL,
+					resolution integer NOT NULL,
+					unix integer NOT NULL,
+					open real NOT NULL,
+					high real NOT NULL,
+					low real NOT NULL,
+					close real NOT NULL,
+					source_rows integer NOT NULL,
+					UNIQUE (tenant, symbol, resolution, unix)
+				)`,
+				`INSERT INTO ohcl_rollups_tenant (id, symbol, resolution, unix, open, high, low, close, source_rows)
+					SELECT id, symbol, resolution, unix, open, high, low, close, source_rows FROM ohcl_rollups`,
+				`DROP TABLE ohcl_rollups`,
+				`ALTER TABLE ohcl_rollups_tenant RENAME TO ohcl_rollups`,
+				`ALTER TABLE retention_runs ADD COLUMN tenant text NOT NULL DEFAULT ''`,
+				`ALTER TABLE api_keys ADD COLUMN tenant text NOT NULL DEFAULT ''`,
+				`ALTER TABLE audit_entries ADD COLUMN tenant text NOT NULL DEFAULT ''`,
+				`CREATE INDEX idx_audit_entries_tenant_created_at ON audit_entries (tenant, created_at)`,
+			},
+		}),
+		Down: execForDialect(map[string][]string{
+			"postgres": {
+				`DROP INDEX IF EXISTS idx_audit_entries_tenant_created_at`,
+				`ALTER TABLE audit_entries DROP COLUMN tenant`,
+				`ALTER TABLE api_keys DROP COLUMN tenant`,
+				`ALTER TABLE retention_runs DROP COLUMN tenant`,
+				`DELETE FROM ohcl_rollups WHERE tenant <> ''`,
+				`ALTER TABLE ohcl_rollups DROP CONSTRAINT ohcl_rollups_bucket`,
+				`ALTER TABLE ohcl_rollups DROP COLUMN tenant`,
+				`ALTER TABLE ohcl_rollups ADD CONSTRAINT ohcl_rollups_symbol_resolution_unix_key UNIQUE (symbol, resolution, unix)`,
+				`CREATE INDEX idx_ohcls_symbol_unix ON ohcls (symbol, unix)`,
+				`DROP INDEX IF EXISTS idx_ohcls_tenant_symbol_unix`,
+				`ALTER TABLE ohcls DROP COLUMN tenant`,
+			},
+			"mysql": {
+				`ALTER TABLE audit_entries DROP INDEX idx_audit_entries_tenant_created_at, DROP COLUMN tenant`,
+				`ALTER TABLE api_keys DROP COLUMN tenant`,
+				`ALTER TABLE retention_runs DROP COLUMN tenant`,
+				`DELETE FROM ohcl_rollups WHERE tenant <> ''`,
+				`ALTER TABLE ohcl_rollups
+					DROP INDEX idx_ohcl_rollups_bucket,
+					DROP COLUMN tenant,
+					ADD UNIQUE KEY idx_ohcl_rollups_bucket (symbol, resolution, unix)`,
+				`ALTER TABLE ohcls DROP INDEX idx_ohcls_tenant_symbol_unix, DROP COLUMN tenant`,
+			},
+			"sqlite": {
+				`DROP INDEX IF EXISTS idx_audit_entries_tenant_created_at`,
+				`ALTER TABLE audit_entries DROP COLUMN tenant`,
+				`ALTER TABLE api_keys DROP COLUMN tenant`,
+				`ALTER TABLE retention_runs DROP COLUMN tenant`,
+				`CREATE TABLE ohcl_rollups_default (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					symbol text NOT NULL,
+					resolution integer NOT NULL,
+					unix integer NOT NULL,
+					open real NOT NULL,
+					high real NOT NULL,
+					low real NOT NULL,
+					close real NOT NULL,
+					source_rows integer NOT NULL,
+					UNIQUE (symbol, resolution, unix)
+				)`,
+				`INSERT INTO ohcl_rollups_default (id, symbol, resolution, unix, open, high, low, close, source_rows)
+					SELECT id, symbol, resolution, unix, open, high, low, close, source_rows FROM ohcl_rollups WHERE tenant = ''`,
+				`DROP TABLE ohcl_rollups`,
+				`ALTER TABLE ohcl_rollups_default RENAME TO ohcl_rollups`,
+				`DROP INDEX IF EXISTS idx_ohcls_tenant_symbol_unix`,
+				`ALTER TABLE ohcls DROP COLUMN tenant`,
+			},
+		}),
+	},
+	{
+		// Source range of the rows rolled up into a bucket, so late rows can move its open and close.
+		// Existing rollups span their whole bucket, their open and close are kept.
+		Version: 9,
+		Name:    "ohcl_rollups_source_range",
+		Up: execForDialect(map[string][]string{
+			"postgres": {
+				`ALTER TABLE ohcl_rollups
+					ADD COLUMN first_unix bigint NOT NULL DEFAULT 0,
+					ADD COLUMN last_unix bigint NOT NULL DEFAULT 0`,
+				`UPDATE ohcl_rollups SET first_unix = unix, last_unix = unix + resolution - 1`,
+			},
+			"mysql": {
+				`ALTER TABLE ohcl_rollups
+					ADD COLUMN first_unix bigint unsigned NOT NULL DEFAULT 0,
+					ADD COLUMN last_unix bigint unsigned NOT NULL DEFAULT 0`,
+				`UPDATE ohcl_rollups SET first_unix = unix, last_unix = unix + resolution - 1`,
+			},
+			"sqlite": {
+				`ALTER TABLE ohcl_rollups ADD COLUMN first_unix integer NOT NULL DEFAULT 0`,
+				`ALTER TABLE ohcl_rollups ADD COLUMN last_unix integer NOT NULL DEFAULT 0`,
+				`UPDATE ohcl_rollups SET first_unix = unix, last_unix = unix + resolution - 1`,
+			},
+		}),
+		Down: execForDialect(map[string][]string{
+			"postgres": {`ALTER TABLE ohcl_rollups DROP COLUMN last_unix, DROP COLUMN first_unix`},
+			"mysql":    {`ALTER TABLE ohcl_rollups DROP COLUMN last_unix, DROP COLUMN first_unix`},
+			"sqlite": {
+				`ALTER TABLE ohcl_rollups DROP COLUMN last_unix`,
+				`ALTER TABLE ohcl_rollups DROP COLUMN first_unix`,
+			},
+		}),
+	},
+}
+
+// Create the monthly partition holding unix_ms if missing and return its name.
+// The partition is created standalone then attached, attaching only takes a share update
+// exclusive lock on ohcls, so running imports and queries are not blocked.
+const postgresEnsurePartitionFunction = `CREATE OR REPLACE FUNCTION ohcls_ensure_partition(unix_ms bigint) RETURNS text AS $$
+DECLARE
+	month_start timestamp := date_trunc('month', to_timestamp(unix_ms / 1000.0) AT TIME ZONE 'UTC');
+	partition_name text := 'ohcls_' || to_char(month_start, 'YYYY_MM');
+	lower_bound bigint := (extract(epoch FROM month_start) * 1000)::bigint;
+	upper_bound bigint := (extract(epoch FROM month_start + interval '1 month') * 1000)::bigint;
+BEGIN
+	PERFORM pg_advisory_xact_lock(hashtext(partition_name));
+	IF to_regclass(partition_name) IS NULL THEN
+		EXECUTE format('CREATE TABLE %I (LIKE ohcls INCLUDING DEFAULTS INCLUDING GENERATED)', partition_name);
+		EXECUTE format('ALTER TABLE ohcls ATTACH PARTITION %I FOR VALUES FROM (%s) TO (%s)',
+			partition_name, lower_bound, upper_bound);
+	END IF;
+	RETURN partition_name;
+END;
+$$ LANGUAGE plpgsql`
@@ -3,13 +3,14 @@ package model
 import "mime/multipart"
 
 type Ohcl struct {
-	ID     uint64  `json:"-" gorm:"primaryKey;autoIncrement"`
-	UNIX   uint64  `json:"unix" binding:"required" gorm:"not null"`
-	SYMBOL string  `json:"symbol" binding:"required" gorm:"not null"`
-	OPEN   float32 `json:"open" binding:"required" gorm:"not null"`
-	HIGH   float32 `json:"high" binding:"required" gorm:"not null"`
-	LOW    float32 `json:"low" binding:"required" gorm:"not null"`
-	CLOSE  float32 `json:"close" binding:"required" gorm:"not null"`
+	ID     uint64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Tenant string  `json:"-" gorm:"not null"` // Set by the store from the request tenant, never from the payload
+	UNIX   uint64  `json:"unix" gorm:"not null"`
+	SYMBOL string  `json:"symbol" binding:"required,symbol" gorm:"not null"`
+	OPEN   float32 `json:"open" binding:"min=0" gorm:"not null"` // Zero is a valid price, required would reject it
+	HIGH   float32 `json:"high" binding:"min=0,ohlc_consistent" gorm:"not null"`
+	LOW    float32 `json:"low" binding:"min=0,ohlc_consistent" gorm:"not null"`
+	CLOSE  float32 `json:"close" binding:"min=0" gorm:"not null"`
 }
 
 type CreatePayload struct {
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Monthly partition of the ohcls table on postgres
+type Partition struct {
+	Name  string `json:"name"`
+	Bound string `json:"bound"` // Partition bound e.g FOR VALUES FROM (1643673600000) TO (1646092800000)
+}
+
+// Start of the UTC month holding the unix milliseconds timestamp, in unix milliseconds
+func PartitionMonth(unixMilli uint64) int64 {
+	t := time.UnixMilli(int64(unixMilli)).UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+}
+
+// Name of the partition holding the month e.g ohcls_2022_02
+func PartitionName(month time.Time) string {
+	return fmt.Sprintf("ohcls_%04d_%02d", month.Year(), month.Month())
+}
+
+// Create the partition holding the month start in unix milliseconds if missing, returns its name.
+// Must not run inside a transaction that inserted into ohcls, so it never waits on itself.
+func EnsurePartition(db *gorm.DB, monthUnixMilli int64) (name string, err error) {
+	err = db.Raw("SELECT ohcls_ensure_partition(?)", monthUnixMilli).Scan(&name).Error
+	return name, err
+}
+
+// List the partitions currently attached to ohcls, oldest first
+func ListPartitions(db *gorm.DB) (partitions []Partition, err error) {
+	err = db.Raw(`SELECT child.relname AS name, pg_get_expr(child.relpartbound, child.oid) AS bound
+		FROM pg_inherits
+		JOIN pg_class parent ON parent.oid = pg_inherits.inhparent
+		JOIN pg_class child ON child.oid = pg_inherits.inhrelid
+		WHERE parent.relname = 'ohcls'
+		ORDER BY child.relname`).Scan(&partitions).Error
+	return partitions, err
+}
+
+// Detach the partition of the month from ohcls for archival and return its new name.
+// The detached table is renamed to archived_<name>_<detach time> and keeps its rows, so a later
+// import for the same month gets a fresh partition which can be archived again.
+func DetachPartition(db *gorm.DB, month time.Time) (archivedName string, err error) {
+	name := PartitionName(month)
+	now := time.Now().UTC()
+	archivedName = fmt.Sprintf("archived_%s_%s%03d", name, now.Format("20060102_150405"), now.Nanosecond()/int(time.Millisecond))
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var exists bool
+		if err := tx.Raw("SELECT to_regclass(?) IS NOT NULL", archivedName).Scan(&exists).Error; err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("archive table %s already exists", archivedName)
+		}
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE ohcls DETACH PARTITION %s", name)).Error; err != nil {
+			return err
+		}
+		return tx.Exec(fmt.Sprintf("ALTER TABLE %s RENAME TO %s", name, archivedName)).Error
+	})
+	return archivedName, err
+}
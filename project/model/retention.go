@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// Coarser candle aggregated from ohcl rows by a retention policy, kept forever
+type OhclRollup struct {
+	ID         uint64  `json:"-" gorm:"primaryKey;autoIncrement"`
+	Tenant     string  `json:"tenant" gorm:"not null"`
+	SYMBOL     string  `json:"symbol" gorm:"not null"`
+	Resolution int64   `json:"resolution" gorm:"not null"` // Bucket size in milliseconds
+	UNIX       uint64  `json:"unix" gorm:"not null"`       // Bucket start in unix milliseconds
+	OPEN       float32 `json:"open" gorm:"not null"`
+	HIGH       float32 `json:"high" gorm:"not null"`
+	LOW        float32 `json:"low" gorm:"not null"`
+	CLOSE      float32 `json:"close" gorm:"not null"`
+	SourceRows int64   `json:"source_rows" gorm:"not null"` // Number of ohcl rows aggregated into the bucket
+	FirstUNIX  uint64  `json:"first_unix" gorm:"not null"`  // Unix of the row the open was taken from
+	LastUNIX   uint64  `json:"last_unix" gorm:"not null"`   // Unix of the row the close was taken from
+}
+
+// Status of a retention run
+const (
+	RetentionRunning   = "running"
+	RetentionSucceeded = "succeeded"
+	RetentionFailed    = "failed"
+)
+
+// Log of a retention policy applied to a symbol
+type RetentionRun struct {
+	ID             uint64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	Policy         string     `json:"policy" gorm:"not null"`
+	Tenant         string     `json:"tenant" gorm:"not null"`
+	SYMBOL         string     `json:"symbol" gorm:"not null"`
+	Cutoff         uint64     `json:"cutoff" gorm:"not null"` // Rows before this unix milliseconds were processed
+	Status         string     `json:"status" gorm:"not null"`
+	RowsProcessed  int64      `json:"rows_processed" gorm:"not null"`
+	RollupsWritten int64      `json:"rollups_written" gorm:"not null"`
+	RowsDeleted    int64      `json:"rows_deleted" gorm:"not null"`
+	ArchiveFile    string     `json:"archive_file"`
+	Error          string     `json:"error"`
+	StartedAt      time.Time  `json:"started_at" gorm:"not null"`
+	FinishedAt     *time.Time `json:"finished_at"`
+}
@@ -0,0 +1,290 @@
+package openapi
+
+import (
+	"csvapi-test/services"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version of the OpenAPI specification the documents follow
+const Version = "3.0.3"
+
+// Security schemes of the authenticated routes
+const (
+	APIKeyScheme = "apiKey"
+	BearerScheme = "bearer"
+)
+
+// OpenAPI 3 document of the api, built with Add from the route descriptions
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Tags       []Tag                `json:"tags,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+
+	components map[reflect.Type]string // Component names of the structs
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// Operations of a path by lowercase http method
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security"` // Empty for the routes without authentication
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path, query or header
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Description of a route, its Go values are reflected into the schemas of the document.
+// Fields are named after their json, form or uri tag, described by their doc tag and required by their binding tag.
+type Route struct {
+	ID          string // operationId
+	Summary     string
+	Description string
+	Tag         string
+	Scope       string        // Scope the api key or bearer token must be granted, empty for routes without authentication
+	Path        interface{}   // Struct of the path parameters, uri tags
+	Query       []interface{} // Structs of the query parameters, form tags
+	Body        interface{}   // Json request body
+	Form        interface{}   // Multipart request body, form tags
+	Responses   map[int]interface{}
+	Errors      []services.ErrorCode // Codes of the problem responses, grouped by status
+	Deprecated  bool
+}
+
+// Response body that is not a json value of a Go type, e.g the Prometheus exposition format
+type Raw struct {
+	ContentType string
+	Description string
+}
+
+// Document with the error problem schema and the api key and bearer token security schemes
+func New(info Info, apiKeyHeader string) *Document {
+	doc := &Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Paths:      map[string]*PathItem{},
+		components: map[reflect.Type]string{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]SecurityScheme{
+				APIKeyScheme: {Type: "apiKey", In: "header", Name: apiKeyHeader, Description: "Api key created by an admin"},
+				BearerScheme: {Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "Token of the configured issuer, scopes are granted by its roles"},
+			},
+		},
+	}
+	doc.Schema(services.Problem{})
+	return doc
+}
+
+// Add the tag shown as a section of the operations
+func (doc *Document) AddTag(name, description string) {
+	doc.Tags = append(doc.Tags, Tag{Name: name, Description: description})
+}
+
+// Add the operation of the gin route path e.g /data/:id
+func (doc *Document) Add(method, path string, route Route) {
+	operation := &Operation{
+		OperationID: route.ID,
+		Summary:     route.Summary,
+		Description: route.Description,
+		Deprecated:  route.Deprecated,
+		Responses:   map[string]*Response{},
+		Security:    []map[string][]string{},
+	}
+	if route.Tag != "" {
+		operation.Tags = []string{route.Tag}
+	}
+	if route.Scope != "" {
+		operation.Security = []map[string][]string{{APIKeyScheme: {}}, {BearerScheme: {}}}
+		operation.Description = strings.TrimSpace(operation.Description + "\n\nRequires the `" + route.Scope + "` scope.")
+	}
+
+	path, names := openAPIPath(path)
+	for _, name := range names {
+		operation.Parameters = append(operation.Parameters, doc.pathParameter(route.Path, name))
+	}
+	for _, query := range route.Query {
+		operation.Parameters = append(operation.Parameters, doc.parameters(query, "query", "form")...)
+	}
+
+	if route.Body != nil {
+		operation.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+			"application/json": {Schema: doc.Schema(route.Body)},
+		}}
+	}
+	if route.Form != nil {
+		operation.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+			"multipart/form-data": {Schema: doc.formSchema(route.Form)},
+		}}
+	}
+
+	for status, body := range route.Responses {
+		response := &Response{Description: http.StatusText(status)}
+		if raw, ok := body.(Raw); ok {
+			response.Description = raw.Description
+			response.Content = map[string]MediaType{raw.ContentType: {Schema: &Schema{}}}
+		} else if body != nil {
+			response.Content = map[string]MediaType{"application/json": {Schema: doc.Schema(body)}}
+		}
+		operation.Responses[strconv.Itoa(status)] = response
+	}
+	doc.addErrors(operation, route.Errors)
+
+	item, ok := doc.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		doc.Paths[path] = item
+	}
+	(*item)[strings.ToLower(method)] = operation
+}
+
+// Whether the document has an operation for the method and gin route path
+func (doc *Document) Has(method, path string) bool {
+	path, _ = openAPIPath(path)
+	item, ok := doc.Paths[path]
+	if !ok {
+		return false
+	}
+	_, ok = (*item)[strings.ToLower(method)]
+	return ok
+}
+
+// Problem responses of the codes, one per status listing its codes
+func (doc *Document) addErrors(operation *Operation, codes []services.ErrorCode) {
+	byStatus := map[int][]string{}
+	for _, code := range codes {
+		byStatus[code.Status()] = append(byStatus[code.Status()], string(code))
+	}
+	problem := doc.Schema(services.Problem{})
+	for status, codes := range byStatus {
+		sort.Strings(codes)
+		response := &Response{
+			Description: http.StatusText(status) + ", problem with code " + strings.Join(codes, ", "),
+			Content:     map[string]MediaType{services.ProblemContentType: {Schema: problem}},
+		}
+		if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+			response.Headers = map[string]Header{"Retry-After": {
+				Description: "Seconds to wait before retrying",
+				Schema:      &Schema{Type: "integer"},
+			}}
+		}
+		operation.Responses[strconv.Itoa(status)] = response
+	}
+}
+
+// OpenAPI path of the gin path with the names of its parameters, e.g /data/{id} and id for /data/:id
+func openAPIPath(path string) (string, []string) {
+	var names []string
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			names = append(names, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), names
+}
+
+// Parameter of the path, described by the field of the path struct with its uri tag, else a string
+func (doc *Document) pathParameter(path interface{}, name string) Parameter {
+	if path != nil {
+		for _, parameter := range doc.parameters(path, "path", "uri") {
+			if parameter.Name == name {
+				parameter.Required = true
+				return parameter
+			}
+		}
+	}
+	return Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}}
+}
+
+// Parameters of the fields of the struct named by the tag
+func (doc *Document) parameters(value interface{}, in, tag string) []Parameter {
+	var parameters []Parameter
+	eachField(reflect.TypeOf(value), tag, func(name string, field reflect.StructField) {
+		parameter := Parameter{
+			Name:     name,
+			In:       in,
+			Required: isRequired(field),
+			Schema:   doc.fieldSchema(field),
+		}
+		parameter.Description, parameter.Schema.Description = parameter.Schema.Description, ""
+		parameters = append(parameters, parameter)
+	})
+	return parameters
+}
+
+// Schema of the multipart form, file fields are binary strings
+func (doc *Document) formSchema(form interface{}) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	eachField(reflect.TypeOf(form), "form", func(name string, field reflect.StructField) {
+		schema.Properties[name] = doc.fieldSchema(field)
+		if isRequired(field) {
+			schema.Required = append(schema.Required, name)
+		}
+	})
+	return schema
+}
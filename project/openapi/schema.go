@@ -0,0 +1,186 @@
+package openapi
+
+import (
+	"csvapi-test/services"
+	"csvapi-test/validation"
+	"mime/multipart"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// JSON schema subset of OpenAPI 3.0
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	fileType      = reflect.TypeOf(multipart.FileHeader{})
+	errorCodeType = reflect.TypeOf(services.ErrorCode(""))
+)
+
+// Schema of the Go value. Named structs are added to the components by their capitalized name and referenced,
+// generic and anonymous ones are inlined.
+func (doc *Document) Schema(value interface{}) *Schema {
+	return doc.typeSchema(reflect.TypeOf(value))
+}
+
+func (doc *Document) typeSchema(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	if t.Kind() == reflect.Pointer {
+		schema := doc.typeSchema(t.Elem())
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return schema
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case fileType:
+		return &Schema{Type: "string", Format: "binary"}
+	case errorCodeType:
+		codes := services.ErrorCodes()
+		schema := &Schema{Type: "string", Enum: make([]string, len(codes))}
+		for i, code := range codes {
+			schema.Enum[i] = string(code)
+		}
+		return schema
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: doc.typeSchema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: doc.typeSchema(t.Elem())}
+	case reflect.Struct:
+		name := t.Name()
+		if name == "" || strings.Contains(name, "[") {
+			return doc.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + doc.component(t)}
+	}
+	// Interfaces hold any value
+	return &Schema{}
+}
+
+// Name of the component of the struct, added on first use.
+// Structs of different packages with the same name are told apart by their package name.
+func (doc *Document) component(t reflect.Type) string {
+	if name, ok := doc.components[t]; ok {
+		return name
+	}
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if _, taken := doc.Components.Schemas[name]; taken {
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	doc.components[t] = name
+	doc.Components.Schemas[name] = doc.structSchema(t)
+	return name
+}
+
+// Object schema of the json fields of the struct
+func (doc *Document) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	eachField(t, "json", func(name string, field reflect.StructField) {
+		schema.Properties[name] = doc.fieldSchema(field)
+		if isRequired(field) {
+			schema.Required = append(schema.Required, name)
+		}
+	})
+	return schema
+}
+
+// Schema of the field type with its doc tag description and the rules of its binding tag
+func (doc *Document) fieldSchema(field reflect.StructField) *Schema {
+	schema := doc.typeSchema(field.Type)
+	description := field.Tag.Get("doc")
+	var enum []string
+	pattern := ""
+	for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+		tag, param, _ := strings.Cut(rule, "=")
+		switch tag {
+		case "oneof":
+			enum = strings.Fields(param)
+		case validation.TagSymbol:
+			pattern = validation.SymbolPattern
+		}
+	}
+	if schema.Ref != "" {
+		if description == "" {
+			return schema
+		}
+		// Siblings of $ref are ignored in OpenAPI 3.0
+		return &Schema{Description: description, AllOf: []*Schema{schema}}
+	}
+	schema.Description = description
+	if enum != nil {
+		schema.Enum = enum
+	}
+	if pattern != "" {
+		schema.Pattern = pattern
+	}
+	return schema
+}
+
+// Call fn with the name in the tag of each exported field, embedded structs fields included.
+// Fields without the tag are named after their go name, "-" fields are skipped.
+func eachField(t reflect.Type, tag string, fn func(name string, field reflect.StructField)) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get(tag), ",")[0]
+		if field.Anonymous && name == "" {
+			eachField(field.Type, tag, fn)
+			continue
+		}
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fn(name, field)
+	}
+}
+
+// Whether the binding tag of the field requires it
+func isRequired(field reflect.StructField) bool {
+	for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+		if rule == "required" {
+			return true
+		}
+	}
+	return false
+}
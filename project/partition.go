@@ -0,0 +1,65 @@
+package main
+
+import (
+	"csvapi-test/config"
+	"csvapi-test/model"
+	"csvapi-test/repository"
+	"fmt"
+	"time"
+)
+
+const partitionUsage = `Usage: partition <command>
+
+Commands:
+  list             List the monthly partitions of the ohcls table
+  detach YYYY-MM   Detach the partition of the month for archival, renamed to archived_<name>_<detach time>`
+
+// Run the partition subcommand against the live postgres database and return the process exit code
+func runPartition(dbConfig config.DatabaseConfig, args []string) int {
+	if len(args) == 0 {
+		fmt.Println(partitionUsage)
+		return 2
+	}
+	if dbConfig.Driver != "postgres" {
+		fmt.Println("partitions are only supported by the postgres driver")
+		return 2
+	}
+
+	db, err := model.Connect(dbConfig)
+	if err != nil {
+		fmt.Println("Database connection error:", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "list":
+		partitions, err := model.ListPartitions(db)
+		if err != nil {
+			fmt.Println("Error listing partitions:", err)
+			return 1
+		}
+		for _, partition := range partitions {
+			fmt.Printf("%s\t%s\n", partition.Name, partition.Bound)
+		}
+	case "detach":
+		if len(args) < 2 {
+			fmt.Println(partitionUsage)
+			return 2
+		}
+		month, err := time.Parse("2006-01", args[1])
+		if err != nil {
+			fmt.Println("detach expects a month as YYYY-MM")
+			return 2
+		}
+		archivedName, err := repository.NewGormCandleStore(db).DetachPartition(month)
+		if err != nil {
+			fmt.Println("Error detaching partition:", err)
+			return 1
+		}
+		fmt.Printf("detached %s as %s\n", model.PartitionName(month), archivedName)
+	default:
+		fmt.Println(partitionUsage)
+		return 2
+	}
+	return 0
+}
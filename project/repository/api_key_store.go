@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"csvapi-test/audit"
+	"csvapi-test/auth"
+	"csvapi-test/model"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// Returned when no api key has the requested id
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	// Returned when the presented key is unknown or revoked
+	ErrInvalidAPIKey = errors.New("invalid or revoked api key")
+)
+
+// Api keys of the clients, stored hashed. Keys are managed within the context tenant.
+type APIKeyStore interface {
+	// Generate and audit a key of the context tenant, returns the key in clear text which is not stored
+	Create(ctx context.Context, name string, scopes []string) (model.APIKey, string, error)
+	// Find the active key matching the clear text key in any tenant and track its usage, ErrInvalidAPIKey if none
+	Authenticate(ctx context.Context, key string) (model.APIKey, error)
+	// Every key of the context tenant including revoked ones, latest first
+	List(ctx context.Context) ([]model.APIKey, error)
+	// Revoke and audit the key of the context tenant with the id, ErrAPIKeyNotFound if missing
+	Revoke(ctx context.Context, id uint64) (model.APIKey, error)
+}
+
+// APIKeyStore backed by a gorm database connection
+type GormAPIKeyStore struct {
+	db *gorm.DB
+}
+
+// Last used timestamps are only written when older than this, so every request does not write
+const lastUsedResolution = time.Minute
+
+func NewGormAPIKeyStore(db *gorm.DB) *GormAPIKeyStore {
+	return &GormAPIKeyStore{db: db}
+}
+
+func (store *GormAPIKeyStore) Create(ctx context.Context, name string, scopes []string) (apiKey model.APIKey, key string, err error) {
+	if key, err = auth.GenerateAPIKey(); err != nil {
+		return apiKey, "", err
+	}
+	apiKey = model.APIKey{
+		Name:      name,
+		Tenant:    auth.Tenant(ctx),
+		Prefix:    auth.APIKeyDisplayPrefix(key),
+		KeyHash:   auth.HashAPIKey(key),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	err = store.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&apiKey).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, model.AuditAPIKeyCreate, apiKeyTarget(apiKey.ID), nil, apiKey)
+	})
+	return apiKey, key, err
+}
+
+func (store *GormAPIKeyStore) Authenticate(ctx context.Context, key string) (apiKey model.APIKey, err error) {
+	db := store.db.WithContext(ctx)
+	err = db.Where("key_hash = ? AND revoked_at IS NULL", auth.HashAPIKey(key)).First(&apiKey).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apiKey, ErrInvalidAPIKey
+	} else if err != nil {
+		return apiKey, err
+	}
+
+	now := time.Now().UTC()
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= lastUsedResolution {
+		err = db.Model(&model.APIKey{}).Where("id = ?", apiKey.ID).Update("last_used_at", now).Error
+		apiKey.LastUsedAt = &now
+	}
+	return apiKey, err
+}
+
+func (store *GormAPIKeyStore) List(ctx context.Context) (apiKeys []model.APIKey, err error) {
+	err = store.db.WithContext(ctx).Where("tenant = ?", auth.Tenant(ctx)).Order("id DESC").Find(&apiKeys).Error
+	return apiKeys, err
+}
+
+func (store *GormAPIKeyStore) Revoke(ctx context.Context, id uint64) (apiKey model.APIKey, err error) {
+	err = store.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tenant = ?", auth.Tenant(ctx)).First(&apiKey, id).Error; err != nil {
+			return err
+		}
+		if apiKey.RevokedAt != nil {
+			return nil // already revoked, keep the original time
+		}
+		before := apiKey
+		now := time.Now().UTC()
+		apiKey.RevokedAt = &now
+		if err := tx.Model(&apiKey).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, model.AuditAPIKeyRevoke, apiKeyTarget(id), before, apiKey)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrAPIKeyNotFound
+	}
+	return apiKey, err
+}
+
+func apiKeyTarget(id uint64) string {
+	return fmt.Sprintf("api_keys/%d", id)
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"csvapi-test/auth"
+	"csvapi-test/model"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Criteria for listing audit entries of the context tenant, empty fields are not filtered on
+type AuditQuery struct {
+	Action    string
+	Actor     string
+	Target    string // Prefix of the target e.g ohcls/ for every single candle mutation
+	RequestID string
+	Since     time.Time // Inclusive start of the creation time range
+	Until     time.Time // Exclusive end of the creation time range
+	Limit     int
+	Offset    int
+}
+
+// Read access to the append-only audit log, entries are written by the mutations they record
+type AuditStore interface {
+	// Find entries matching the query latest first, limited and offset by the query
+	List(ctx context.Context, query AuditQuery) ([]model.AuditEntry, error)
+	// Count every entry matching the query, ignoring limit and offset
+	Count(ctx context.Context, query AuditQuery) (int64, error)
+}
+
+// AuditStore backed by a gorm database connection
+type GormAuditStore struct {
+	db *gorm.DB
+}
+
+func NewGormAuditStore(db *gorm.DB) *GormAuditStore {
+	return &GormAuditStore{db: db}
+}
+
+func (store *GormAuditStore) scope(ctx context.Context, query AuditQuery) *gorm.DB {
+	db := store.db.WithContext(ctx).Model(&model.AuditEntry{}).Where("tenant = ?", auth.Tenant(ctx))
+	if query.Action != "" {
+		db = db.Where("action = ?", query.Action)
+	}
+	if query.Actor != "" {
+		db = db.Where("actor = ?", query.Actor)
+	}
+	if query.Target != "" {
+		db = db.Where("target LIKE ? ESCAPE '!'", escapeLike(query.Target)+"%")
+	}
+	if query.RequestID != "" {
+		db = db.Where("request_id = ?", query.RequestID)
+	}
+	if !query.Since.IsZero() {
+		db = db.Where("created_at >= ?", query.Since.UTC())
+	}
+	if !query.Until.IsZero() {
+		db = db.Where("created_at < ?", query.Until.UTC())
+	}
+	return db
+}
+
+func (store *GormAuditStore) List(ctx context.Context, query AuditQuery) (entries []model.AuditEntry, err error) {
+	err = store.scope(ctx, query).
+		Order("id DESC").
+		Limit(query.Limit).
+		Offset(query.Offset).
+		Find(&entries).Error
+	return entries, err
+}
+
+func (store *GormAuditStore) Count(ctx context.Context, query AuditQuery) (total int64, err error) {
+	err = store.scope(ctx, query).Count(&total).Error
+	return total, err
+}
+
+// Escape the LIKE wildcards of a literal prefix, with an escape character every supported database accepts
+func escapeLike(value string) string {
+	replacer := strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`)
+	return replacer.Replace(value)
+}
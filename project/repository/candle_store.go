@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"csvapi-test/model"
+	"csvapi-test/services"
+	"errors"
+)
+
+var (
+	// Returned when no candle of the tenant has the requested id
+	ErrCandleNotFound = errors.New("candle not found")
+	// Returned when an import would store more rows than the tenant quota
+	ErrQuotaExceeded = errors.New("row quota exceeded")
+)
+
+// Criteria for querying and counting candles
+type CandleQuery struct {
+	Search string                    // Full text search, ignored by stores without search support
+	Filter services.FilterExpression // Structured filter conditions joined with AND
+	From   uint64                    // Inclusive start of the unix milliseconds range, 0 if unset
+	To     uint64                    // Exclusive end of the unix milliseconds range, 0 if unset
+	Limit  int
+	Offset int
+}
+
+// Candles of a symbol within a unix milliseconds range, From inclusive and To exclusive
+type CandleRange struct {
+	Symbol string
+	From   uint64
+	To     uint64
+}
+
+// Storage of ohcl candles used by the controllers.
+// Every method is limited to the candles of the context tenant, see auth.WithTenant.
+type CandleStore interface {
+	// Start an import whose batches are committed or rolled back together
+	BeginImport(ctx context.Context) (CandleImport, error)
+	// Find candles matching the query, limited and offset by the query
+	Query(ctx context.Context, query CandleQuery) ([]model.Ohcl, error)
+	// Count every candle matching the query, ignoring limit and offset
+	Count(ctx context.Context, query CandleQuery) (int64, error)
+	// Find the candle with the id, ErrCandleNotFound if missing
+	Get(ctx context.Context, id uint64) (model.Ohcl, error)
+	// Save every field of the candle and audit it, returns the candle before the update
+	Update(ctx context.Context, candle model.Ohcl) (before model.Ohcl, err error)
+	// Delete the candle with the id and audit it, returns the deleted candle
+	Delete(ctx context.Context, id uint64) (model.Ohcl, error)
+	// Delete every candle in the range and audit it, returns the number of deleted candles
+	DeleteRange(ctx context.Context, candleRange CandleRange) (int64, error)
+}
+
+// Single import of candles, InsertBatch must not be called concurrently
+type CandleImport interface {
+	InsertBatch(rows []model.Ohcl) error
+	// Audit the import with its summary and commit every inserted batch
+	Commit(summary model.ImportSummary) error
+	Rollback() error
+	// Roll back the import stopped by a shutdown and audit it as interrupted, so it can be found and retried
+	Interrupt(summary model.ImportSummary) error
+}
@@ -0,0 +1,326 @@
+package repository
+
+import (
+	"context"
+	"csvapi-test/audit"
+	"csvapi-test/auth"
+	"csvapi-test/config"
+	"csvapi-test/model"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// CandleStore backed by a gorm database connection
+type GormCandleStore struct {
+	db         *gorm.DB
+	quotas     config.TenantsConfig
+	partitions sync.Map // Time postgres month partitions were known to exist, keyed by month start in unix milliseconds
+}
+
+// Recheck known partitions after a while, in case they were detached for archival
+const partitionCacheTTL = time.Minute
+
+func NewGormCandleStore(db *gorm.DB) *GormCandleStore {
+	return &GormCandleStore{db: db}
+}
+
+// Enforce the tenant row quotas on imports
+func (store *GormCandleStore) WithQuotas(quotas config.TenantsConfig) *GormCandleStore {
+	store.quotas = quotas
+	return store
+}
+
+// Underlying database connection
+func (store *GormCandleStore) DB() *gorm.DB {
+	return store.db
+}
+
+type gormCandleImport struct {
+	store   *GormCandleStore
+	tx      *gorm.DB
+	tenant  string
+	maxRows int64 // Row quota of the tenant, 0 is unlimited
+	stored  int64 // Rows of the tenant, counted when the import began plus the inserted ones
+}
+
+func (store *GormCandleStore) BeginImport(ctx context.Context) (CandleImport, error) {
+	// Only log failed and slow queries of the session, the batch inserts are too large for query logs.
+	// Disable default transaction for the database session
+	db := store.db.Session(&gorm.Session{
+		Context:                ctx,
+		Logger:                 store.db.Logger.LogMode(logger.Warn),
+		SkipDefaultTransaction: true, //disable default transaction to help speed
+	})
+
+	candleImport := &gormCandleImport{
+		store:   store,
+		tenant:  auth.Tenant(ctx),
+		maxRows: store.quotas.Quota(auth.Tenant(ctx)).MaxRows,
+	}
+	// Concurrent imports of a tenant may each pass the quota check, so the quota can be exceeded by one import
+	if candleImport.maxRows > 0 {
+		if err := db.Model(&model.Ohcl{}).Where("tenant = ?", candleImport.tenant).Count(&candleImport.stored).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	candleImport.tx = db.Begin() // Perform the saving with explicit transaction to enable rollback
+	if candleImport.tx.Error != nil {
+		return nil, candleImport.tx.Error
+	}
+	return candleImport, nil
+}
+
+func (candleImport *gormCandleImport) InsertBatch(rows []model.Ohcl) error {
+	if candleImport.maxRows > 0 && candleImport.stored+int64(len(rows)) > candleImport.maxRows {
+		return fmt.Errorf("%w: the tenant may store at most %d rows", ErrQuotaExceeded, candleImport.maxRows)
+	}
+	for i := range rows {
+		rows[i].Tenant = candleImport.tenant
+	}
+	if err := candleImport.store.ensurePartitions(rows); err != nil {
+		return err
+	}
+	if err := candleImport.insert(rows); err != nil {
+		return err
+	}
+	candleImport.stored += int64(len(rows))
+	return nil
+}
+
+// Insert the rows, on postgres retried once when a cached partition was detached by another process.
+// The failed insert aborts the transaction, so it runs in a savepoint the import is rolled back to.
+func (candleImport *gormCandleImport) insert(rows []model.Ohcl) error {
+	tx := candleImport.tx
+	if tx.Dialector.Name() != "postgres" {
+		return insertRows(tx, rows)
+	}
+	if err := tx.SavePoint("insert_rows").Error; err != nil {
+		return err
+	}
+	err := insertRows(tx, rows)
+	if !model.IsMissingPartition(err) {
+		return err
+	}
+	if err := tx.RollbackTo("insert_rows").Error; err != nil {
+		return err
+	}
+	candleImport.store.forgetPartitions(rows)
+	if err := candleImport.store.ensurePartitions(rows); err != nil {
+		return err
+	}
+	return insertRows(tx, rows)
+}
+
+// Insert the rows in a single statement. Mysql gets a plain multi-row INSERT, as gorm would read back the
+// auto increment id of every row which the import does not use.
+func insertRows(tx *gorm.DB, rows []model.Ohcl) error {
+	if tx.Dialector.Name() != "mysql" {
+		return tx.Create(rows).Error
+	}
+	var statement strings.Builder
+	statement.WriteString("INSERT INTO ohcls (tenant, unix, symbol, open, high, low, close) VALUES ")
+	values := make([]interface{}, 0, len(rows)*7)
+	for i, row := range rows {
+		if i > 0 {
+			statement.WriteString(",")
+		}
+		statement.WriteString("(?,?,?,?,?,?,?)")
+		values = append(values, row.Tenant, row.UNIX, row.SYMBOL, row.OPEN, row.HIGH, row.LOW, row.CLOSE)
+	}
+	return tx.Exec(statement.String(), values...).Error
+}
+
+// Create the postgres partitions of every month in rows, and of the month after the latest
+// one ahead of the next rows. Runs outside the import transaction, so the partition is
+// attached and visible before the rows are inserted.
+func (store *GormCandleStore) ensurePartitions(rows []model.Ohcl) error {
+	if store.db.Dialector.Name() != "postgres" || len(rows) == 0 {
+		return nil
+	}
+
+	var latest int64
+	months := map[int64]bool{}
+	for _, row := range rows {
+		month := model.PartitionMonth(row.UNIX)
+		months[month] = true
+		if month > latest {
+			latest = month
+		}
+	}
+	months[time.UnixMilli(latest).UTC().AddDate(0, 1, 0).UnixMilli()] = true
+
+	for month := range months {
+		if checkedAt, known := store.partitions.Load(month); known && time.Since(checkedAt.(time.Time)) < partitionCacheTTL {
+			continue
+		}
+		if _, err := model.EnsurePartition(store.db, month); err != nil {
+			return err
+		}
+		store.partitions.Store(month, time.Now())
+	}
+	return nil
+}
+
+// Forget the cached partitions of the months in rows, so they are checked again
+func (store *GormCandleStore) forgetPartitions(rows []model.Ohcl) {
+	for _, row := range rows {
+		store.partitions.Delete(model.PartitionMonth(row.UNIX))
+	}
+}
+
+// Detach the partition of the month for archival, see model.DetachPartition. The partition is forgotten
+// so the next import of the month creates it again, other processes recheck it after partitionCacheTTL
+// or as soon as an insert into the month fails.
+func (store *GormCandleStore) DetachPartition(month time.Time) (archivedName string, err error) {
+	archivedName, err = model.DetachPartition(store.db, month)
+	if err == nil {
+		store.partitions.Delete(model.PartitionMonth(uint64(month.UnixMilli())))
+	}
+	return archivedName, err
+}
+
+func (candleImport *gormCandleImport) Commit(summary model.ImportSummary) error {
+	if err := audit.Record(candleImport.tx, model.AuditCandleImport, "ohcls", nil, summary); err != nil {
+		candleImport.tx.Rollback()
+		return err
+	}
+	return candleImport.tx.Commit().Error
+}
+
+func (candleImport *gormCandleImport) Rollback() error {
+	return candleImport.tx.Rollback().Error
+}
+
+func (candleImport *gormCandleImport) Interrupt(summary model.ImportSummary) error {
+	candleImport.tx.Rollback() // the failed commit of a cancelled import may have rolled it back already
+
+	// The import context is cancelled, keep its values for the audit actor, request id and tenant
+	ctx := context.WithoutCancel(candleImport.tx.Statement.Context)
+	return candleImport.store.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return audit.Record(tx, model.AuditCandleImportInterrupted, "ohcls", nil, summary)
+	})
+}
+
+// Apply tenant, search and filter conditions of the query
+func (store *GormCandleStore) scope(ctx context.Context, query CandleQuery) *gorm.DB {
+	db := store.tenantScope(ctx).Model(&model.Ohcl{})
+
+	if query.Search != "" {
+		switch db.Dialector.Name() {
+		case "postgres":
+			// Query the precomputed and GIN indexed tsvector column
+			db = db.Where("search_vector @@ to_tsquery('english', ?)", query.Search)
+		case "mysql":
+			// Query the precomputed and FULLTEXT indexed text column
+			db = db.Where("MATCH(search_text) AGAINST (? IN BOOLEAN MODE)", query.Search)
+		}
+	}
+	// Plain range on the partition key, so postgres prunes the month partitions outside it
+	if query.From > 0 {
+		db = db.Where("unix >= ?", query.From)
+	}
+	if query.To > 0 {
+		db = db.Where("unix < ?", query.To)
+	}
+	return query.Filter.Apply(db)
+}
+
+func (store *GormCandleStore) Query(ctx context.Context, query CandleQuery) (ohlcs []model.Ohcl, err error) {
+	err = store.scope(ctx, query).
+		Limit(query.Limit).
+		Offset(query.Offset).
+		Find(&ohlcs).Error
+	return ohlcs, err
+}
+
+func (store *GormCandleStore) Count(ctx context.Context, query CandleQuery) (total int64, err error) {
+	err = store.scope(ctx, query).Count(&total).Error
+	return total, err
+}
+
+// Session limited to the candles of the context tenant
+func (store *GormCandleStore) tenantScope(ctx context.Context) *gorm.DB {
+	return store.db.WithContext(ctx).Where("tenant = ?", auth.Tenant(ctx))
+}
+
+func (store *GormCandleStore) Get(ctx context.Context, id uint64) (candle model.Ohcl, err error) {
+	err = store.tenantScope(ctx).First(&candle, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrCandleNotFound
+	}
+	return candle, err
+}
+
+func (store *GormCandleStore) Update(ctx context.Context, candle model.Ohcl) (before model.Ohcl, err error) {
+	// The updated unix may fall in a month without a partition yet
+	if err = store.ensurePartitions([]model.Ohcl{candle}); err != nil {
+		return before, err
+	}
+	tenant := auth.Tenant(ctx)
+	candle.Tenant = tenant
+	update := func(tx *gorm.DB) error {
+		if err := tx.Where("tenant = ?", tenant).First(&before, candle.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(&candle).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, model.AuditCandleUpdate, candleTarget(candle.ID), before, candle)
+	}
+	err = store.db.WithContext(ctx).Transaction(update)
+	if model.IsMissingPartition(err) {
+		// The cached partition was detached by another process, create it again and retry once
+		store.forgetPartitions([]model.Ohcl{candle})
+		if err = store.ensurePartitions([]model.Ohcl{candle}); err != nil {
+			return before, err
+		}
+		err = store.db.WithContext(ctx).Transaction(update)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrCandleNotFound
+	}
+	return before, err
+}
+
+func (store *GormCandleStore) Delete(ctx context.Context, id uint64) (candle model.Ohcl, err error) {
+	tenant := auth.Tenant(ctx)
+	err = store.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tenant = ?", tenant).First(&candle, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tenant = ?", tenant).Delete(&model.Ohcl{}, id).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, model.AuditCandleDelete, candleTarget(id), candle, nil)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrCandleNotFound
+	}
+	return candle, err
+}
+
+func (store *GormCandleStore) DeleteRange(ctx context.Context, candleRange CandleRange) (deleted int64, err error) {
+	err = store.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("tenant = ? AND symbol = ? AND unix >= ? AND unix < ?",
+			auth.Tenant(ctx), candleRange.Symbol, candleRange.From, candleRange.To).
+			Delete(&model.Ohcl{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		target := fmt.Sprintf("ohcls?symbol=%s&from=%d&to=%d", candleRange.Symbol, candleRange.From, candleRange.To)
+		return audit.Record(tx, model.AuditCandleDeleteRange, target, map[string]int64{"rows": deleted}, nil)
+	})
+	return deleted, err
+}
+
+func candleTarget(id uint64) string {
+	return fmt.Sprintf("ohcls/%d", id)
+}
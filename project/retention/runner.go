@@ -0,0 +1,440 @@
+package retention
+
+import (
+	"compress/gzip"
+	"context"
+	"csvapi-test/audit"
+	"csvapi-test/auth"
+	"csvapi-test/config"
+	"csvapi-test/model"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Returned when a run is requested while another one is still going
+var ErrRunInProgress = errors.New("a retention run is already in progress")
+
+// Ids deleted per statement, below the bind variable limits of every dialect
+const deleteBatchSize = 500
+
+// Applies the configured retention policies on a schedule or on demand
+type Runner struct {
+	db      *gorm.DB
+	config  config.RetentionConfig
+	running sync.Mutex
+	started atomic.Bool // Scheduled runs loop is running
+}
+
+func NewRunner(db *gorm.DB, retentionConfig config.RetentionConfig) *Runner {
+	return &Runner{db: db, config: retentionConfig}
+}
+
+// Configured policies
+func (runner *Runner) Policies() []config.RetentionPolicy {
+	return runner.config.Policies
+}
+
+// Whether runs are scheduled, they are not without an interval or policies
+func (runner *Runner) Scheduled() bool {
+	return runner.config.Interval > 0 && len(runner.config.Policies) > 0
+}
+
+// Whether the scheduled runs loop is running
+func (runner *Runner) Alive() bool {
+	return runner.started.Load()
+}
+
+// Run the policies every configured interval until ctx is done, no-op if the interval is 0
+func (runner *Runner) Start(ctx context.Context) {
+	if !runner.Scheduled() {
+		return
+	}
+	runner.started.Store(true)
+	defer runner.started.Store(false)
+	ticker := time.NewTicker(runner.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := runner.RunOnce(ctx); err != nil && !errors.Is(err, ErrRunInProgress) {
+				slog.ErrorContext(ctx, "retention run failed", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+// Apply every policy to every symbol it covers, returns the logged runs
+func (runner *Runner) RunOnce(ctx context.Context) (runs []model.RetentionRun, err error) {
+	if !runner.running.TryLock() {
+		return nil, ErrRunInProgress
+	}
+	defer runner.running.Unlock()
+
+	// Scheduled runs are audited as the runner, runs requested over http as the client
+	if audit.FromContext(ctx).Actor == "" {
+		ctx = audit.WithActor(ctx, "retention")
+	}
+	db := runner.db.WithContext(ctx)
+	policySymbols := map[string]bool{}
+	for _, policy := range runner.config.Policies {
+		if policy.Symbol != "" {
+			policySymbols[policy.Symbol] = true
+		}
+	}
+
+	for _, policy := range runner.config.Policies {
+		cutoff := runner.cutoff(policy)
+
+		// Every tenant symbol with old rows, a global policy skips the symbols with their own policy
+		var found []struct{ Tenant, Symbol string }
+		query := db.Model(&model.Ohcl{}).Where("unix < ?", cutoff)
+		if policy.Symbol != "" {
+			query = query.Where("symbol = ?", policy.Symbol)
+		}
+		if err := query.Distinct("tenant", "symbol").Order("tenant").Order("symbol").Find(&found).Error; err != nil {
+			return runs, err
+		}
+
+		for _, tenantSymbol := range found {
+			if policy.Symbol == "" && policySymbols[tenantSymbol.Symbol] {
+				continue
+			}
+			run, err := runner.apply(ctx, policy, tenantSymbol.Tenant, tenantSymbol.Symbol, cutoff)
+			runs = append(runs, run)
+			attrs := []slog.Attr{
+				slog.String("policy", policy.Name),
+				slog.String("tenant", tenantSymbol.Tenant),
+				slog.String("symbol", tenantSymbol.Symbol),
+			}
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+				slog.Default().LogAttrs(ctx, slog.LevelError, "retention policy failed", attrs...)
+				continue
+			}
+			attrs = append(attrs,
+				slog.Int64("rows_processed", run.RowsProcessed),
+				slog.Int64("rollups_written", run.RollupsWritten),
+				slog.Int64("rows_deleted", run.RowsDeleted),
+			)
+			slog.Default().LogAttrs(ctx, slog.LevelInfo, "retention policy applied", attrs...)
+		}
+	}
+	return runs, nil
+}
+
+// Logged runs, latest first
+func (runner *Runner) ListRuns(ctx context.Context, limit, offset int) (runs []model.RetentionRun, total int64, err error) {
+	db := runner.db.WithContext(ctx).Model(&model.RetentionRun{})
+	if err = db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = db.Order("id DESC").Limit(limit).Offset(offset).Find(&runs).Error
+	return runs, total, err
+}
+
+// Start of the resolution bucket holding now - max_age, so only complete buckets are processed
+func (runner *Runner) cutoff(policy config.RetentionPolicy) uint64 {
+	cutoff := time.Now().Add(-policy.MaxAge).UnixMilli()
+	resolution := policy.Resolution.Milliseconds()
+	return uint64(cutoff - cutoff%resolution)
+}
+
+// Apply policy to rows of the tenant symbol before cutoff and log the run
+func (runner *Runner) apply(ctx context.Context, policy config.RetentionPolicy, tenant, symbol string, cutoff uint64) (model.RetentionRun, error) {
+	db := runner.db.WithContext(auth.WithTenant(ctx, tenant)) // audited in the tenant of the rows
+	run := model.RetentionRun{
+		Policy:    policy.Name,
+		Tenant:    tenant,
+		SYMBOL:    symbol,
+		Cutoff:    cutoff,
+		Status:    model.RetentionRunning,
+		StartedAt: time.Now().UTC(),
+	}
+	if err := db.Create(&run).Error; err != nil {
+		return run, err
+	}
+
+	err := runner.process(db, policy, &run)
+	finishedAt := time.Now().UTC()
+	run.FinishedAt = &finishedAt
+	run.Status = model.RetentionSucceeded
+	if err != nil {
+		run.Status = model.RetentionFailed
+		run.Error = err.Error()
+	}
+	if saveErr := db.Save(&run).Error; err == nil {
+		err = saveErr
+	}
+	return run, err
+}
+
+// Downsample, archive and delete the rows of the run, one window of rows at a time
+func (runner *Runner) process(db *gorm.DB, policy config.RetentionPolicy, run *model.RetentionRun) (err error) {
+	var archive *archiveWriter
+	if policy.Action == "archive" {
+		if archive, err = newArchiveWriter(runner.config.ArchiveDir, run); err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := archive.close(); err == nil {
+				err = closeErr
+			}
+			// The rows of the committed windows are only kept in the export
+			if run.RowsDeleted == 0 {
+				os.Remove(archive.path)
+				run.ArchiveFile = ""
+			}
+		}()
+		run.ArchiveFile = archive.path
+	}
+
+	var (
+		after      *model.Ohcl // Last row of the previous window
+		lastBucket *uint64     // Bucket of the last row of the previous window, counted once when continued
+	)
+	for {
+		window, err := runner.processWindow(db, policy, run, archive, after)
+		if err != nil || len(window.buckets) == 0 {
+			return err
+		}
+		run.RollupsWritten += int64(len(window.buckets))
+		if lastBucket != nil && *lastBucket == window.buckets[0].UNIX {
+			run.RollupsWritten--
+		}
+		lastBucket = &window.buckets[len(window.buckets)-1].UNIX
+		after = &window.last
+	}
+}
+
+// Rows of a window rolled up into buckets
+type retentionWindow struct {
+	last    model.Ohcl // Last row of the window, the next window starts after it
+	buckets []*model.OhclRollup
+}
+
+// Roll up, archive and delete the next runner.config.WindowRows rows after the last row of the previous window,
+// in a single transaction. The rows are locked while read, so a concurrent update waits for the delete
+// instead of being deleted with its rolled up values. Rows an import commits before the window start are
+// kept for the next run. Returns no buckets once every row before the cutoff is processed.
+func (runner *Runner) processWindow(db *gorm.DB, policy config.RetentionPolicy, run *model.RetentionRun, archive *archiveWriter, after *model.Ohcl) (window retentionWindow, err error) {
+	resolution := policy.Resolution.Milliseconds()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("tenant = ? AND symbol = ? AND unix < ?", run.Tenant, run.SYMBOL, run.Cutoff)
+		if after != nil {
+			query = query.Where("unix > ? OR (unix = ? AND id > ?)", after.UNIX, after.UNIX, after.ID)
+		}
+		var rows []model.Ohcl
+		if err := query.Order("unix").Order("id").Limit(runner.config.WindowRows).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Find(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		var current *model.OhclRollup
+		ids := make([]uint64, 0, len(rows))
+		for _, ohlc := range rows {
+			ids = append(ids, ohlc.ID)
+			if archive != nil {
+				if err := archive.write(ohlc); err != nil {
+					return err
+				}
+			}
+			bucket := ohlc.UNIX - ohlc.UNIX%uint64(resolution)
+			if current == nil || current.UNIX != bucket {
+				current = &model.OhclRollup{
+					Tenant:     ohlc.Tenant,
+					SYMBOL:     ohlc.SYMBOL,
+					Resolution: resolution,
+					UNIX:       bucket,
+					OPEN:       ohlc.OPEN,
+					HIGH:       ohlc.HIGH,
+					LOW:        ohlc.LOW,
+					FirstUNIX:  ohlc.UNIX,
+				}
+				window.buckets = append(window.buckets, current)
+			}
+			current.HIGH = max32(current.HIGH, ohlc.HIGH)
+			current.LOW = min32(current.LOW, ohlc.LOW)
+			current.CLOSE = ohlc.CLOSE
+			current.LastUNIX = ohlc.UNIX
+			current.SourceRows++
+		}
+		window.last = rows[len(rows)-1]
+
+		if err := saveRollups(tx, run, resolution, window.buckets); err != nil {
+			return err
+		}
+
+		var deleted int64
+		for start := 0; start < len(ids); start += deleteBatchSize {
+			batch := ids[start:min(start+deleteBatchSize, len(ids))]
+			result := tx.Where("tenant = ? AND symbol = ? AND unix < ? AND id IN ?", run.Tenant, run.SYMBOL, run.Cutoff, batch).
+				Delete(&model.Ohcl{})
+			if result.Error != nil {
+				return result.Error
+			}
+			deleted += result.RowsAffected
+		}
+		// The export holds the rows before they are deleted, a failed commit leaves them in both
+		if archive != nil {
+			if err := archive.flush(); err != nil {
+				return err
+			}
+		}
+
+		target := fmt.Sprintf("ohcls?symbol=%s&from=%d&to=%d", run.SYMBOL, rows[0].UNIX, window.last.UNIX+1)
+		before := map[string]interface{}{"policy": run.Policy, "run": run.ID, "rows": deleted}
+		after := map[string]interface{}{"rollups": len(window.buckets), "archive_file": run.ArchiveFile}
+		if err := audit.Record(tx, model.AuditCandleRetention, target, before, after); err != nil {
+			return err
+		}
+		run.RowsProcessed += int64(len(rows))
+		run.RowsDeleted += deleted
+		return nil
+	})
+	if err != nil {
+		return retentionWindow{}, err
+	}
+	return window, nil
+}
+
+// Create the rollups of the buckets, or merge them into the rollups of previous windows and runs
+func saveRollups(tx *gorm.DB, run *model.RetentionRun, resolution int64, buckets []*model.OhclRollup) error {
+	var existing []model.OhclRollup
+	if err := tx.Where("tenant = ? AND symbol = ? AND resolution = ? AND unix >= ? AND unix <= ?",
+		run.Tenant, run.SYMBOL, resolution, buckets[0].UNIX, buckets[len(buckets)-1].UNIX).
+		Find(&existing).Error; err != nil {
+		return err
+	}
+	previous := map[uint64]model.OhclRollup{}
+	for _, rollup := range existing {
+		previous[rollup.UNIX] = rollup
+	}
+
+	var created []*model.OhclRollup
+	for _, bucket := range buckets {
+		rollup, found := previous[bucket.UNIX]
+		if !found {
+			created = append(created, bucket)
+			continue
+		}
+		// Rows of a bucket already rolled up move its open and close when they are outside the
+		// source range of the rollup. Windows are read in unix order, so a row of the next window
+		// at the same unix as the close comes after it.
+		if bucket.FirstUNIX < rollup.FirstUNIX {
+			rollup.OPEN = bucket.OPEN
+			rollup.FirstUNIX = bucket.FirstUNIX
+		}
+		if bucket.LastUNIX >= rollup.LastUNIX {
+			rollup.CLOSE = bucket.CLOSE
+			rollup.LastUNIX = bucket.LastUNIX
+		}
+		rollup.HIGH = max32(bucket.HIGH, rollup.HIGH)
+		rollup.LOW = min32(bucket.LOW, rollup.LOW)
+		rollup.SourceRows += bucket.SourceRows
+		if err := tx.Save(&rollup).Error; err != nil {
+			return err
+		}
+	}
+	if len(created) > 0 {
+		return tx.CreateInBatches(created, 500).Error
+	}
+	return nil
+}
+
+// Gzip compressed csv export of archived rows, in the upload format so it can be imported again
+type archiveWriter struct {
+	path   string
+	file   *os.File
+	gzip   *gzip.Writer
+	csv    *csv.Writer
+	closed bool
+}
+
+func newArchiveWriter(archiveDir string, run *model.RetentionRun) (*archiveWriter, error) {
+	dir := filepath.Join(archiveDir, run.Tenant, run.SYMBOL) // tenant names are path safe, the default tenant is at the root
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s_before_%d_run_%d.csv.gz", run.SYMBOL, run.Cutoff, run.ID))
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	writer := &archiveWriter{path: path, file: file, gzip: gzip.NewWriter(file)}
+	writer.csv = csv.NewWriter(writer.gzip)
+	if err := writer.csv.Write([]string{"UNIX", "SYMBOL", "OPEN", "HIGH", "LOW", "CLOSE"}); err != nil {
+		writer.close()
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (writer *archiveWriter) write(ohlc model.Ohcl) error {
+	return writer.csv.Write([]string{
+		strconv.FormatUint(ohlc.UNIX, 10),
+		ohlc.SYMBOL,
+		strconv.FormatFloat(float64(ohlc.OPEN), 'f', -1, 32),
+		strconv.FormatFloat(float64(ohlc.HIGH), 'f', -1, 32),
+		strconv.FormatFloat(float64(ohlc.LOW), 'f', -1, 32),
+		strconv.FormatFloat(float64(ohlc.CLOSE), 'f', -1, 32),
+	})
+}
+
+// Write the buffered rows through to the file
+func (writer *archiveWriter) flush() error {
+	writer.csv.Flush()
+	if err := writer.csv.Error(); err != nil {
+		return err
+	}
+	if err := writer.gzip.Flush(); err != nil {
+		return err
+	}
+	return writer.file.Sync()
+}
+
+func (writer *archiveWriter) close() error {
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+	writer.csv.Flush()
+	err := writer.csv.Error()
+	if gzipErr := writer.gzip.Close(); err == nil {
+		err = gzipErr
+	}
+	if fileErr := writer.file.Close(); err == nil {
+		err = fileErr
+	}
+	return err
+}
+
+func max32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
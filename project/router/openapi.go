@@ -0,0 +1,64 @@
+package router
+
+import (
+	"csvapi-test/config"
+	"csvapi-test/controller"
+	"csvapi-test/middleware"
+	"csvapi-test/openapi"
+	"csvapi-test/services"
+	"net/http"
+	"strings"
+)
+
+// Problem codes every route can respond with
+var routeErrors = []services.ErrorCode{services.CodeRequestTimeout, services.CodeInternal}
+
+// Problem codes of the routes requiring an api key or bearer token
+var authErrors = []services.ErrorCode{
+	services.CodeUnauthorized, services.CodeInvalidCredentials, services.CodeForbidden, services.CodeAuthUnavailable,
+}
+
+// OpenAPI document of the routes registered by AppInstance, the api routes are described by the version routes
+func apiSpec(api config.APIConfig, h *handlers) *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:       "CSV API",
+		Description: "Import, query and correct historical OHLC candles. Errors are RFC 7807 problems with a stable code.",
+		Version:     "1.0.0",
+	}, middleware.APIKeyHeader)
+	doc.AddTag("candles", "Candle imports and queries, scoped to the tenant of the credentials")
+	doc.AddTag("admin", "Api keys, retention and audit log")
+	doc.AddTag("health", "Probes and metrics")
+	doc.AddTag("docs", "This documentation")
+
+	add := func(method, path, scope string, route openapi.Route) {
+		route.Scope = scope
+		route.Errors = append(append([]services.ErrorCode{}, route.Errors...), routeErrors...)
+		if scope != "" {
+			route.Errors = append(route.Errors, authErrors...)
+		}
+		doc.Add(method, path, route)
+	}
+
+	add(http.MethodGet, "/openapi.json", "", controller.SpecDoc)
+	add(http.MethodGet, "/docs", "", controller.DocsUIDoc)
+	add(http.MethodGet, "/healthz", "", controller.LiveDoc)
+	add(http.MethodGet, "/readyz", "", controller.ReadyDoc)
+	add(http.MethodGet, "/metrics", "", controller.MetricsDoc)
+
+	for _, version := range versions {
+		for _, route := range version.routes(h) {
+			route.doc.ID = version.name + strings.ToUpper(route.doc.ID[:1]) + route.doc.ID[1:]
+			add(route.method, "/"+version.name+route.path, route.scope, route.doc)
+		}
+	}
+	if api.LegacyRoutes {
+		for _, route := range versionRoutes(legacyVersion, h) {
+			route.doc.ID = "legacy" + strings.ToUpper(route.doc.ID[:1]) + route.doc.ID[1:]
+			route.doc.Deprecated = true
+			route.doc.Description = strings.TrimSpace("Deprecated alias of `/" + legacyVersion + route.path + "`. " + route.doc.Description)
+			add(route.method, route.path, route.scope, route.doc)
+		}
+	}
+
+	return doc
+}
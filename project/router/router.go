@@ -1,22 +1,85 @@
 package router
 
 import (
+	"csvapi-test/auth"
+	"csvapi-test/config"
 	"csvapi-test/controller"
+	"csvapi-test/drain"
+	"csvapi-test/health"
+	"csvapi-test/metrics"
 	"csvapi-test/middleware"
+	"csvapi-test/repository"
+	"csvapi-test/retention"
+	"csvapi-test/services"
+	"csvapi-test/validation"
 
 	"github.com/gin-gonic/gin"
 )
 
-// App server engine instance with registered routes
-func AppInstance() *gin.Engine {
-	// Go gin Default engine visit https://github.com/gin-gonic/gin
-	app := gin.Default()
+// Services the route handlers are constructed with
+type Dependencies struct {
+	Store     repository.CandleStore
+	Audit     repository.AuditStore
+	APIKeys   repository.APIKeyStore
+	Tokens    *auth.TokenVerifier // Nil when bearer tokens are not configured
+	Retention *retention.Runner
+	Health    *health.Checker
+	Imports   *drain.Drain // Running imports, drained on shutdown
+}
+
+// App server engine instance with registered routes, handlers are constructed with deps
+func AppInstance(cfg *config.Config, deps Dependencies) *gin.Engine {
+	// Go gin engine visit https://github.com/gin-gonic/gin, with structured request logs instead of the default logger
+	app := gin.New()
+
+	// Validation messages of the bindings and the custom candle tags
+	validation.Setup()
+
+	app.Use(middleware.RequestContextMiddleware())
+	app.Use(middleware.TracingMiddleware())
+	app.Use(middleware.LoggerMiddleware())
+	app.Use(middleware.RecoveryMiddleware())
+	app.Use(middleware.MetricsMiddleware())
+	app.Use(middleware.CORSMiddleware(cfg.CORS))
+	app.Use(middleware.TimeoutMiddleware(cfg.Server.RequestTimeout))
+
+	h := &handlers{
+		candles:     controller.NewCandleHandler(deps.Store, deps.Audit, cfg.Import, cfg.Tenants, deps.Imports, cfg.Limits.ImportRetryAfter),
+		retention:   controller.NewRetentionHandler(deps.Retention),
+		audit:       controller.NewAuditHandler(deps.Audit),
+		apiKeys:     controller.NewAPIKeyHandler(deps.APIKeys),
+		queryLimit:  middleware.RateLimitMiddleware(cfg.Limits),
+		importLimit: middleware.ImportLimitMiddleware(cfg.Limits),
+	}
+	healthHandler := controller.NewHealthHandler(deps.Health)
+	docsHandler := controller.NewDocsHandler(apiSpec(cfg.API, h))
+
+	// OpenAPI document of the routes and its Swagger UI, not authenticated
+	app.GET("/openapi.json", docsHandler.Spec)
+	app.GET("/docs", docsHandler.UI)
+
+	// Probes of container orchestrators, not authenticated
+	app.GET("/healthz", healthHandler.Live)
+	app.GET("/readyz", healthHandler.Ready)
+
+	// Prometheus scrape endpoint, not authenticated so keep it on a private network
+	metrics.SetLimits(cfg.Limits)
+	app.GET("/metrics", gin.WrapH(metrics.Handler()))
 
-	app.Use(middleware.CORSMiddleware())
-	app.Use(middleware.TimeoutMiddleware())
+	// Every api route requires an api key or bearer token granted the route scope
+	authenticate := middleware.AuthMiddleware(deps.APIKeys, deps.Tokens, cfg.Auth)
+	for _, version := range versions {
+		registerRoutes(app.Group("/"+version.name, authenticate), version.routes(h))
+	}
+	// Unversioned paths of the first clients, aliases of the legacy version
+	if cfg.API.LegacyRoutes {
+		deprecated := middleware.DeprecationMiddleware(cfg.API, "/"+legacyVersion)
+		registerRoutes(app.Group("", deprecated, authenticate), versionRoutes(legacyVersion, h))
+	}
 
-	app.POST("/data", controller.Create)
-	app.GET("/data", controller.Fetch)
+	app.NoRoute(func(c *gin.Context) {
+		services.ErrorResponse(c, services.CodeRouteNotFound, "No route matches "+c.Request.Method+" "+c.Request.URL.Path)
+	})
 
 	return app
 }
@@ -0,0 +1,86 @@
+package router
+
+import (
+	"csvapi-test/auth"
+	"csvapi-test/controller"
+	"csvapi-test/middleware"
+	"csvapi-test/openapi"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Api route of a version, with its OpenAPI description
+type route struct {
+	method   string
+	path     string // Relative to the version prefix
+	scope    string // Scope the api key or bearer token must be granted
+	handlers []gin.HandlerFunc
+	doc      openapi.Route
+}
+
+// Api version served under /<name>, with every route listed by its routes function.
+// Nothing is inherited between versions, a new version that keeps routes of the previous one lists them again.
+type version struct {
+	name   string
+	routes func(h *handlers) []route
+}
+
+// Api versions, oldest first
+var versions = []version{
+	{name: "v1", routes: v1Routes},
+}
+
+// Version aliased by the deprecated unversioned paths
+const legacyVersion = "v1"
+
+// Handlers and middlewares shared by the routes of every version.
+// Limiters are built once so a client gets the same limits on every version and alias.
+type handlers struct {
+	candles   *controller.CandleHandler
+	retention *controller.RetentionHandler
+	audit     *controller.AuditHandler
+	apiKeys   *controller.APIKeyHandler
+
+	queryLimit  gin.HandlerFunc
+	importLimit gin.HandlerFunc
+}
+
+func v1Routes(h *handlers) []route {
+	// Retention runs cover every tenant, so only the default tenant manages them
+	operator := middleware.RequireDefaultTenant()
+	return []route{
+		{http.MethodPost, "/data", auth.ScopeDataWrite, []gin.HandlerFunc{h.importLimit, h.candles.Create}, controller.CreateDoc},
+		{http.MethodGet, "/imports/:request_id", auth.ScopeDataWrite, []gin.HandlerFunc{h.queryLimit, h.candles.ImportStatus}, controller.ImportStatusDoc},
+		{http.MethodGet, "/data", auth.ScopeDataRead, []gin.HandlerFunc{h.queryLimit, h.candles.Fetch}, controller.FetchDoc},
+		{http.MethodPatch, "/data/:id", auth.ScopeDataWrite, []gin.HandlerFunc{h.candles.Update}, controller.UpdateDoc},
+		{http.MethodDelete, "/data/:id", auth.ScopeDataWrite, []gin.HandlerFunc{h.candles.Delete}, controller.DeleteDoc},
+		{http.MethodDelete, "/data", auth.ScopeDataWrite, []gin.HandlerFunc{h.candles.DeleteRange}, controller.DeleteRangeDoc},
+
+		{http.MethodGet, "/audit", auth.ScopeAdmin, []gin.HandlerFunc{h.audit.List}, controller.AuditDoc},
+
+		{http.MethodGet, "/admin/retention", auth.ScopeAdmin, []gin.HandlerFunc{operator, h.retention.ListRuns}, controller.ListRunsDoc},
+		{http.MethodPost, "/admin/retention/run", auth.ScopeAdmin, []gin.HandlerFunc{operator, h.retention.Run}, controller.RunRetentionDoc},
+		{http.MethodGet, "/admin/api-keys", auth.ScopeAdmin, []gin.HandlerFunc{h.apiKeys.List}, controller.ListAPIKeysDoc},
+		{http.MethodPost, "/admin/api-keys", auth.ScopeAdmin, []gin.HandlerFunc{h.apiKeys.Create}, controller.CreateAPIKeyDoc},
+		{http.MethodDelete, "/admin/api-keys/:id", auth.ScopeAdmin, []gin.HandlerFunc{h.apiKeys.Revoke}, controller.RevokeAPIKeyDoc},
+	}
+}
+
+// Register the routes on the group, behind the check of their scope
+func registerRoutes(group *gin.RouterGroup, routes []route) {
+	for _, route := range routes {
+		chain := append([]gin.HandlerFunc{middleware.RequireScope(route.scope)}, route.handlers...)
+		group.Handle(route.method, route.path, chain...)
+	}
+}
+
+// Routes of the named version
+func versionRoutes(name string, h *handlers) []route {
+	for _, version := range versions {
+		if version.name == name {
+			return version.routes(h)
+		}
+	}
+	panic("router: unknown api version " + name)
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"net/http"
+	"sort"
+)
+
+// Stable machine readable error code of a problem response, clients match on it instead of the detail message
+type ErrorCode string
+
+const (
+	// Request
+	CodeInvalidParameter ErrorCode = "INVALID_PARAMETER" // Query or path parameter of the wrong format
+	CodeInvalidBody      ErrorCode = "INVALID_BODY"      // Request body that cannot be decoded
+	CodeValidationFailed ErrorCode = "VALIDATION_FAILED" // Decoded payload failing validation, see the field errors
+	CodeInvalidFilter    ErrorCode = "INVALID_FILTER"    // Filter query that cannot be parsed, see the details
+	CodeRouteNotFound    ErrorCode = "ROUTE_NOT_FOUND"
+	CodeNotFound         ErrorCode = "NOT_FOUND"
+	CodeRequestTimeout   ErrorCode = "REQUEST_TIMEOUT"
+
+	// Authentication
+	CodeUnauthorized       ErrorCode = "UNAUTHORIZED"        // No credentials sent
+	CodeInvalidCredentials ErrorCode = "INVALID_CREDENTIALS" // Unknown or revoked api key, invalid bearer token
+	CodeForbidden          ErrorCode = "FORBIDDEN"           // Missing scope or tenant access
+	CodeAuthUnavailable    ErrorCode = "AUTH_UNAVAILABLE"    // Keys of the bearer token issuer cannot be fetched, retry the request
+
+	// Csv imports
+	CodeCsvInvalidForm    ErrorCode = "CSV_INVALID_FORM" // Multipart form without a csv_file
+	CodeCsvInvalidFile    ErrorCode = "CSV_INVALID_FILE" // Not a .csv file
+	CodeCsvInvalidHeader  ErrorCode = "CSV_INVALID_HEADER"
+	CodeRowParseError     ErrorCode = "ROW_PARSE_ERROR" // Invalid row, the details carry its line
+	CodeUploadTooLarge    ErrorCode = "UPLOAD_TOO_LARGE"
+	CodeQuotaExceeded     ErrorCode = "QUOTA_EXCEEDED"
+	CodeImportFailed      ErrorCode = "IMPORT_FAILED"      // Saving the rows failed, the import is rolled back
+	CodeImportInterrupted ErrorCode = "IMPORT_INTERRUPTED" // Rolled back by a server shutdown, retry the upload
+
+	// Server
+	CodeRateLimited      ErrorCode = "RATE_LIMITED"
+	CodeTooManyImports   ErrorCode = "TOO_MANY_IMPORTS"
+	CodeDBConflict       ErrorCode = "DB_CONFLICT" // Unique key, lock or serialization conflict, retry the request
+	CodeRetentionRunning ErrorCode = "RETENTION_RUN_IN_PROGRESS"
+	CodeShuttingDown     ErrorCode = "SHUTTING_DOWN"
+	CodeInternal         ErrorCode = "INTERNAL_ERROR"
+)
+
+type errorCodeInfo struct {
+	status int
+	title  string
+}
+
+// Status and title of every error code
+var errorCodes = map[ErrorCode]errorCodeInfo{
+	CodeInvalidParameter:   {http.StatusBadRequest, "Invalid parameter"},
+	CodeInvalidBody:        {http.StatusBadRequest, "Invalid request body"},
+	CodeValidationFailed:   {http.StatusBadRequest, "Validation failed"},
+	CodeInvalidFilter:      {http.StatusBadRequest, "Invalid filter"},
+	CodeRouteNotFound:      {http.StatusNotFound, "Route not found"},
+	CodeNotFound:           {http.StatusNotFound, "Resource not found"},
+	CodeRequestTimeout:     {http.StatusRequestTimeout, "Request timed out"},
+	CodeUnauthorized:       {http.StatusUnauthorized, "Authentication required"},
+	CodeInvalidCredentials: {http.StatusUnauthorized, "Invalid credentials"},
+	CodeForbidden:          {http.StatusForbidden, "Forbidden"},
+	CodeAuthUnavailable:    {http.StatusServiceUnavailable, "Authentication unavailable"},
+	CodeCsvInvalidForm:     {http.StatusBadRequest, "Invalid upload form"},
+	CodeCsvInvalidFile:     {http.StatusBadRequest, "Invalid csv file"},
+	CodeCsvInvalidHeader:   {http.StatusBadRequest, "Invalid csv header"},
+	CodeRowParseError:      {http.StatusBadRequest, "Invalid csv row"},
+	CodeUploadTooLarge:     {http.StatusRequestEntityTooLarge, "Upload too large"},
+	CodeQuotaExceeded:      {http.StatusForbidden, "Quota exceeded"},
+	CodeImportFailed:       {http.StatusInternalServerError, "Import failed"},
+	CodeImportInterrupted:  {http.StatusServiceUnavailable, "Import interrupted"},
+	CodeRateLimited:        {http.StatusTooManyRequests, "Rate limit exceeded"},
+	CodeTooManyImports:     {http.StatusTooManyRequests, "Too many concurrent imports"},
+	CodeDBConflict:         {http.StatusConflict, "Database conflict"},
+	CodeRetentionRunning:   {http.StatusConflict, "Retention run in progress"},
+	CodeShuttingDown:       {http.StatusServiceUnavailable, "Server shutting down"},
+	CodeInternal:           {http.StatusInternalServerError, "Internal server error"},
+}
+
+// Http status of the code, 500 for unknown codes
+func (code ErrorCode) Status() int {
+	if info, ok := errorCodes[code]; ok {
+		return info.status
+	}
+	return http.StatusInternalServerError
+}
+
+// Short summary of the code, the same for every occurrence
+func (code ErrorCode) Title() string {
+	if info, ok := errorCodes[code]; ok {
+		return info.title
+	}
+	return http.StatusText(code.Status())
+}
+
+// Problem type uri of the code
+func (code ErrorCode) Type() string {
+	return "urn:csvapi:error:" + string(code)
+}
+
+// Every error code sorted, for documenting them
+func ErrorCodes() []ErrorCode {
+	codes := make([]ErrorCode, 0, len(errorCodes))
+	for code := range errorCodes {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
@@ -1,14 +1,19 @@
 package services
 
 import (
+	"csvapi-test/audit"
+	"csvapi-test/model"
+	"csvapi-test/validation"
 	"errors"
-	"fmt"
-	"net/http"
+	"log/slog"
+	"math"
+	"net/url"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -21,130 +26,209 @@ func ErrorExists(err error) string {
 	return ""
 }
 
-// Compute 500 Server Error response
-func ServerErrror(c *gin.Context, err error, extra string) {
-	response := gin.H{
-		"status":  "failed",
-		"error":   true,
-		"message": ErrorExists(err) + " " + extra,
-	}
+// Content type of the error responses, RFC 7807
+const ProblemContentType = "application/problem+json"
+
+// Error response body following RFC 7807 problem details, with the error code and the request id as extensions
+type Problem struct {
+	Type      string       `json:"type"`               // Uri of the error code e.g urn:csvapi:error:CSV_INVALID_HEADER
+	Title     string       `json:"title"`              // Summary of the error code
+	Status    int          `json:"status"`             // Http status code
+	Detail    string       `json:"detail,omitempty"`   // Message of this occurrence
+	Instance  string       `json:"instance,omitempty"` // Request path
+	Code      ErrorCode    `json:"code"`
+	RequestID string       `json:"request_id,omitempty"` // For matching the response with the request logs
+	Errors    []FieldError `json:"errors,omitempty"`     // Invalid payload fields
+	Details   interface{}  `json:"details,omitempty"`    // Structured context of the error, e.g the invalid csv line
+}
 
-	c.JSON(http.StatusInternalServerError, response)
+// Invalid field of a request payload
+type FieldError struct {
+	Field   string `json:"field"`   // Json name of the field
+	Rule    string `json:"rule"`    // Failed validation rule e.g required
+	Message string `json:"message"` // Human readable message
 }
 
-// Compute 400 Bad Request Error response
-func BadRequestErrror(c *gin.Context, err error, extra string) {
-	response := gin.H{
-		"status":  "failed",
-		"error":   true,
-		"message": ErrorExists(err) + " " + extra,
+// Problem of the code for the request, detail is the message of this occurrence
+func NewProblem(c *gin.Context, code ErrorCode, detail string) *Problem {
+	problem := &Problem{
+		Type:     code.Type(),
+		Title:    code.Title(),
+		Status:   code.Status(),
+		Detail:   detail,
+		Instance: c.Request.URL.Path,
+		Code:     code,
+	}
+	if requestID := audit.FromContext(c.Request.Context()).RequestID; requestID != "" {
+		problem.RequestID = requestID
 	}
+	return problem
+}
 
-	c.JSON(http.StatusBadRequest, response)
+// Send the problem response and abort the remaining handlers
+func (problem *Problem) Abort(c *gin.Context) {
+	c.Header("Content-Type", ProblemContentType)
+	c.AbortWithStatusJSON(problem.Status, problem)
 }
 
-// Compute 404 Not Found Error response
-func NotFoundError(c *gin.Context, err error, extra string) {
-	response := gin.H{
-		"status":  "failed",
-		"error":   true,
-		"message": ErrorExists(err) + " " + extra,
-	}
+// Compute the problem response of the code, its status is the one of the code
+func ErrorResponse(c *gin.Context, code ErrorCode, detail string) {
+	NewProblem(c, code, detail).Abort(c)
+}
 
-	c.JSON(http.StatusNotFound, response)
+// Compute the problem response of the code with a Retry-After header, the client may retry after retryAfter
+func RetryableError(c *gin.Context, code ErrorCode, retryAfter time.Duration, detail string) {
+	setRetryAfter(c, retryAfter)
+	ErrorResponse(c, code, detail)
 }
 
-// Compute 401 Unauthorized Error response
-func UnauthorizedError(c *gin.Context, err error, extra string) {
-	response := gin.H{
-		"status":  "failed",
-		"error":   true,
-		"message": ErrorExists(err) + " " + extra,
+// Compute 500 Server Error response of the code, the error is logged as it is not a client mistake and only
+// the request id is returned to match the logs. Database conflicts are retryable and sent as 409 DB_CONFLICT.
+func ServerErrror(c *gin.Context, code ErrorCode, err error) {
+	if model.IsConflict(err) {
+		slog.WarnContext(c.Request.Context(), "database conflict", slog.String("error", ErrorExists(err)),
+			slog.String("route", c.FullPath()))
+		ErrorResponse(c, CodeDBConflict, "The request conflicted with a concurrent change, retry it")
+		return
 	}
+	slog.ErrorContext(c.Request.Context(), "server error", slog.String("error", ErrorExists(err)),
+		slog.String("code", string(code)), slog.String("route", c.FullPath()))
+	ErrorResponse(c, code, "")
+}
 
-	c.JSON(http.StatusUnauthorized, response)
+// Retry-After header in whole seconds, at least 1
+func setRetryAfter(c *gin.Context, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
 }
 
-// Compute 40 Forbidden Error response
-func ForbiddenError(c *gin.Context, err error, extra string) {
-	response := gin.H{
-		"status":  "failed",
-		"error":   true,
-		"message": ErrorExists(err) + " " + extra,
+// Compute 400 Bad Request Error response for an invalid filter query, pointing to the offending token
+func FilterQueryError(c *gin.Context, err error) {
+	problem := NewProblem(c, CodeInvalidFilter, err.Error())
+	var filterErr *FilterError
+	if errors.As(err, &filterErr) {
+		problem.Details = filterErr
 	}
+	problem.Abort(c)
+}
 
-	c.JSON(http.StatusForbidden, response)
+// Compute 400 Bad Request Error response of the payload binding error, with the failed validation of each field
+// in the language of the Accept-Language header
+func AbortWithRequestError(c *gin.Context, model interface{}, err error) {
+	abortWithBindingError(c, model, err, CodeInvalidBody)
 }
 
-/** Check error from *gorm.DB operation or query, send error response if found and return true */
-func GormQueryErrorCheck(c *gin.Context, result *gorm.DB, model, customMessage string) (found bool) {
-	var errorMessage string
-	if customMessage != "" {
-		errorMessage = customMessage
-	} else {
-		errorMessage = model + "not found"
+// Same as AbortWithRequestError for the query parameters binding error,
+// a value that could not be decoded is reported on its parameter
+func AbortWithQueryError(c *gin.Context, model interface{}, err error) {
+	field, kind, value := "", "", ""
+	var timeErr *time.ParseError
+	var numErr *strconv.NumError
+	if errors.As(err, &timeErr) {
+		kind, value = "time", timeErr.Value
+	} else if errors.As(err, &numErr) {
+		kind, value = "number", numErr.Num
+	}
+	if kind != "" {
+		field = failedParameter(reflect.TypeOf(model).Elem(), c.Request.URL.Query(), kind, value)
 	}
+	if field == "" {
+		abortWithBindingError(c, model, err, CodeInvalidParameter)
+		return
+	}
+
+	locale := validation.Locale(c.GetHeader("Accept-Language"))
+	c.Header("Content-Language", locale)
+	message := validation.DecodeMessage(locale, field, kind)
+	problem := NewProblem(c, CodeInvalidParameter, message)
+	problem.Errors = []FieldError{{Field: field, Rule: kind, Message: message}}
+	problem.Abort(c)
+}
+
+// Query parameter of the first field of the struct type, in the binding order, whose type is decoded as kind
+// and whose value is the one that failed. Parameters of other types sharing the value are not reported.
+func failedParameter(modelType reflect.Type, query url.Values, kind, value string) string {
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && fieldType.Kind() == reflect.Struct {
+			if name := failedParameter(fieldType, query, kind, value); name != "" {
+				return name
+			}
+			continue
+		}
 
-	if result.Error != nil {
-		if result.Error.Error() == "record not found" {
-			NotFoundError(c, nil, errorMessage)
-		} else {
-			ServerErrror(c, result.Error, "")
+		name, _, _ := strings.Cut(field.Tag.Get("form"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		} else if name == "" {
+			name = field.Name
+		}
+		if decodeKind(fieldType) != kind {
+			continue
+		}
+		for _, v := range query[name] {
+			if v == value {
+				return name
+			}
 		}
-		return true
-	} else if result.RowsAffected == 0 {
-		NotFoundError(c, nil, errorMessage)
-		return true
 	}
-	return
+	return ""
 }
 
-// Validates the request payload with the model struct
-func AbortWithRequestError(c *gin.Context, model interface{}, err error) {
-	//model is a pointer
-	var ers validator.ValidationErrors
-	errorMessage := make([]map[string]string, 0)
-	if errors.As(err, &ers) {
-		for _, er := range err.(validator.ValidationErrors) {
-			field, _ := reflect.TypeOf(model).Elem().FieldByName(er.Field())
-			jsonTag := string(field.Tag.Get("json"))
-			errorTag := er.Tag()
-			errorMessage = append(errorMessage, BindErrorResolver(jsonTag, errorTag, er.Param()))
-		}
-	} else {
-		field := reflect.TypeOf(model).Elem().Name()
-		errorMessage = append(errorMessage, map[string]string{field: err.Error()})
+// Kind of the decode message of a query field of the type, empty if its values are not parsed
+func decodeKind(fieldType reflect.Type) string {
+	if fieldType == reflect.TypeOf(time.Time{}) {
+		return "time"
+	}
+	switch fieldType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
 	}
+	return ""
+}
 
-	response := gin.H{
-		"status":  "failed",
-		"error":   true,
-		"message": errorMessage,
+// Problem of the failed validations, or of the decodeCode if the payload could not be decoded. model is a pointer.
+func abortWithBindingError(c *gin.Context, model interface{}, err error, decodeCode ErrorCode) {
+	var ers validator.ValidationErrors
+	if !errors.As(err, &ers) {
+		ErrorResponse(c, decodeCode, err.Error())
+		return
 	}
 
-	c.AbortWithStatusJSON(http.StatusBadRequest, response)
+	problem := NewProblem(c, CodeValidationFailed, "The request is invalid")
+	problem.Errors = FieldErrors(c, model, ers)
+	problem.Abort(c)
 }
 
-// Bind appropriate message text to error type based on the struct tag validation
-func BindErrorResolver(jsonTagName, errorTag, errorParam string) (message map[string]string) {
-	message = map[string]string{}
-	switch errorTag {
-	case "gte":
-		message[jsonTagName] = fmt.Sprintf("%s must be greater than or equal to %s", jsonTagName, errorParam)
-		return
-	case "lte":
-		message[jsonTagName] = fmt.Sprintf("%s must be less than or equal to %s", jsonTagName, errorParam)
-		return
-	case "required":
-		message[jsonTagName] = fmt.Sprintf("%s is required", jsonTagName)
-		return
-	case "email":
-		message[jsonTagName] = fmt.Sprintf("%s must be a valid email address", jsonTagName)
-		return
-	case "oneof":
-		message[jsonTagName] = fmt.Sprintf("%s must be one of %s", jsonTagName, strings.Join(strings.Split(errorParam, " "), ", "))
-		return
+// Translated field errors of the failed validations of model, a struct pointer
+func FieldErrors(c *gin.Context, model interface{}, ers validator.ValidationErrors) []FieldError {
+	locale := validation.Locale(c.GetHeader("Accept-Language"))
+	c.Header("Content-Language", locale)
+
+	modelType := reflect.TypeOf(model).Elem()
+	fieldName := func(name string) string {
+		if field, ok := modelType.FieldByName(name); ok {
+			return validation.FieldName(field)
+		}
+		return name
+	}
 
+	fieldErrors := make([]FieldError, 0, len(ers))
+	for _, er := range ers {
+		fieldErrors = append(fieldErrors, FieldError{
+			Field:   er.Field(),
+			Rule:    er.Tag(),
+			Message: validation.Message(locale, er, fieldName),
+		})
 	}
-	return
+	return fieldErrors
 }
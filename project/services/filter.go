@@ -0,0 +1,249 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Kind of value a filterable field accepts
+type filterKind int
+
+const (
+	filterUint filterKind = iota
+	filterFloat
+	filterString
+)
+
+// Whitelisted filter fields mapped to their database column and value kind
+var filterFields = map[string]struct {
+	column string
+	kind   filterKind
+}{
+	"unix":   {"unix", filterUint},
+	"symbol": {"symbol", filterString},
+	"open":   {"open", filterFloat},
+	"high":   {"high", filterFloat},
+	"low":    {"low", filterFloat},
+	"close":  {"close", filterFloat},
+}
+
+// Whitelisted comparison operators mapped to their sql operator
+var filterOperators = map[string]string{
+	"=":  "=",
+	"!=": "<>",
+	">":  ">",
+	">=": ">=",
+	"<":  "<",
+	"<=": "<=",
+	"in": "IN",
+}
+
+// Error describing an invalid filter query and the token it failed on
+type FilterError struct {
+	Message  string `json:"message"`
+	Token    string `json:"token"`
+	Position int    `json:"position"` // Zero based offset of the token in the filter query
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("%s at position %d near %q", e.Message, e.Position, e.Token)
+}
+
+// Single parsed filter condition e.g close > 42000
+type FilterCondition struct {
+	Field    string
+	Operator string
+	Values   []interface{}
+}
+
+// Parsed filter query, conditions are joined with AND
+type FilterExpression []FilterCondition
+
+type filterToken struct {
+	value    string
+	position int
+}
+
+// Split the filter query into tokens, keeping track of their positions
+func tokenizeFilter(query string) (tokens []filterToken) {
+	i := 0
+	for i < len(query) {
+		char := query[i]
+		switch {
+		case char == ' ' || char == '\t':
+			i++
+		case strings.ContainsRune(";(),", rune(char)):
+			tokens = append(tokens, filterToken{string(char), i})
+			i++
+		case strings.ContainsRune("=!<>", rune(char)):
+			start := i
+			for i < len(query) && strings.ContainsRune("=!<>", rune(query[i])) {
+				i++
+			}
+			tokens = append(tokens, filterToken{query[start:i], start})
+		default:
+			start := i
+			for i < len(query) && !strings.ContainsRune(" \t;(),=!<>", rune(query[i])) {
+				i++
+			}
+			tokens = append(tokens, filterToken{query[start:i], start})
+		}
+	}
+	return tokens
+}
+
+type filterParser struct {
+	tokens   []filterToken
+	current  int
+	queryLen int
+}
+
+func (p *filterParser) peek() (filterToken, bool) {
+	if p.current >= len(p.tokens) {
+		return filterToken{"", p.queryLen}, false
+	}
+	return p.tokens[p.current], true
+}
+
+func (p *filterParser) next() (filterToken, bool) {
+	token, ok := p.peek()
+	if ok {
+		p.current++
+	}
+	return token, ok
+}
+
+func (p *filterParser) expect(value string) error {
+	token, ok := p.next()
+	if !ok {
+		return &FilterError{Message: fmt.Sprintf("expected %q, got end of filter", value), Position: token.position}
+	}
+	if !strings.EqualFold(token.value, value) {
+		return &FilterError{Message: fmt.Sprintf("expected %q", value), Token: token.value, Position: token.position}
+	}
+	return nil
+}
+
+// Convert a value token into the kind expected by the field
+func parseFilterValue(token filterToken, kind filterKind) (interface{}, error) {
+	switch kind {
+	case filterUint:
+		value, err := strconv.ParseUint(token.value, 10, 64)
+		if err != nil {
+			return nil, &FilterError{Message: "expected an unsigned integer", Token: token.value, Position: token.position}
+		}
+		return value, nil
+	case filterFloat:
+		value, err := strconv.ParseFloat(token.value, 64)
+		if err != nil {
+			return nil, &FilterError{Message: "expected a number", Token: token.value, Position: token.position}
+		}
+		return value, nil
+	}
+	return token.value, nil
+}
+
+func (p *filterParser) parseValue(kind filterKind) (interface{}, error) {
+	token, ok := p.next()
+	if !ok {
+		return nil, &FilterError{Message: "expected a value, got end of filter", Position: token.position}
+	}
+	if strings.ContainsAny(token.value, ";(),=!<>") {
+		return nil, &FilterError{Message: "expected a value", Token: token.value, Position: token.position}
+	}
+	return parseFilterValue(token, kind)
+}
+
+func (p *filterParser) parseCondition() (condition FilterCondition, err error) {
+	fieldToken, ok := p.next()
+	if !ok {
+		return condition, &FilterError{Message: "expected a field, got end of filter", Position: fieldToken.position}
+	}
+	field, found := filterFields[strings.ToLower(fieldToken.value)]
+	if !found {
+		return condition, &FilterError{Message: "unknown field", Token: fieldToken.value, Position: fieldToken.position}
+	}
+	condition.Field = field.column
+
+	operatorToken, ok := p.next()
+	if !ok {
+		return condition, &FilterError{Message: "expected an operator, got end of filter", Position: operatorToken.position}
+	}
+	operator, found := filterOperators[strings.ToLower(operatorToken.value)]
+	if !found {
+		return condition, &FilterError{Message: "unknown operator", Token: operatorToken.value, Position: operatorToken.position}
+	}
+	condition.Operator = operator
+
+	if operator != "IN" {
+		value, err := p.parseValue(field.kind)
+		if err != nil {
+			return condition, err
+		}
+		condition.Values = []interface{}{value}
+		return condition, nil
+	}
+
+	if err := p.expect("("); err != nil {
+		return condition, err
+	}
+	for {
+		value, err := p.parseValue(field.kind)
+		if err != nil {
+			return condition, err
+		}
+		condition.Values = append(condition.Values, value)
+
+		token, ok := p.next()
+		if ok && token.value == "," {
+			continue
+		}
+		if ok && token.value == ")" {
+			break
+		}
+		if !ok {
+			return condition, &FilterError{Message: `expected "," or ")", got end of filter`, Position: token.position}
+		}
+		return condition, &FilterError{Message: `expected "," or ")"`, Token: token.value, Position: token.position}
+	}
+	return condition, nil
+}
+
+// Parse filter query e.g close>42000;symbol in (BTCUSDT,ETHUSDT) into a whitelisted expression
+func ParseFilter(query string) (expression FilterExpression, err error) {
+	parser := &filterParser{tokens: tokenizeFilter(query), queryLen: len(query)}
+	if len(parser.tokens) == 0 {
+		return expression, nil
+	}
+	for {
+		condition, err := parser.parseCondition()
+		if err != nil {
+			return nil, err
+		}
+		expression = append(expression, condition)
+
+		token, ok := parser.next()
+		if !ok {
+			break
+		}
+		if token.value != ";" {
+			return nil, &FilterError{Message: `expected ";"`, Token: token.value, Position: token.position}
+		}
+	}
+	return expression, nil
+}
+
+// Translate the expression into parameterized gorm where clauses
+func (expression FilterExpression) Apply(db *gorm.DB) *gorm.DB {
+	for _, condition := range expression {
+		if condition.Operator == "IN" {
+			db = db.Where(fmt.Sprintf("%s IN ?", condition.Field), condition.Values)
+			continue
+		}
+		db = db.Where(fmt.Sprintf("%s %s ?", condition.Field, condition.Operator), condition.Values[0])
+	}
+	return db
+}
@@ -0,0 +1,99 @@
+package test
+
+import (
+	"bytes"
+	"csvapi-test/model"
+	"csvapi-test/services"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type AuditResponse struct {
+	Data       []model.AuditEntry  `json:"data"`
+	Status     string              `json:"status"`
+	Pagination services.Pagination `json:"pagination"`
+}
+
+func TestAuditLogsMutations(t *testing.T) {
+	appRouter, store := newTestApp(t)
+	seedCandles(t, store, 1)
+
+	req, _ := http.NewRequest(http.MethodPatch, "/data/1", bytes.NewBufferString(`{"close": 42130}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-ID", "req-update")
+	req.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Status code must be 200")
+	assert.Equal(t, "req-update", w.Header().Get("X-Request-ID"), "Request id must be echoed")
+
+	req, _ = http.NewRequest(http.MethodDelete, "/data/2", nil)
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Status code must be 200")
+	assert.NotEmpty(t, w.Header().Get("X-Request-ID"), "Request id must be generated")
+
+	req, _ = http.NewRequest(http.MethodGet, "/audit?action=candle.update", nil)
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+
+	var response AuditResponse
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, http.StatusOK, w.Code, "Status code must be 200")
+	if assert.Len(t, response.Data, 1) {
+		entry := response.Data[0]
+		assert.Equal(t, "ohcls/1", entry.Target)
+		assert.Equal(t, "req-update", entry.RequestID)
+		assert.Equal(t, "192.0.2.1", entry.Actor, "Client must be the actor")
+		assert.NotEmpty(t, entry.Before)
+		assert.NotEmpty(t, entry.After)
+	}
+
+	// Import, update and delete are all audited, latest first
+	req, _ = http.NewRequest(http.MethodGet, "/audit?limit=1", nil)
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+
+	response = AuditResponse{}
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, 3, response.Pagination.Total)
+	if assert.Len(t, response.Data, 1) {
+		assert.Equal(t, model.AuditCandleDelete, response.Data[0].Action)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/audit?target=ohcls/", nil)
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+
+	response = AuditResponse{}
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Len(t, response.Data, 2, "Target must be matched as a prefix")
+}
+
+func TestAuditEntriesAreAppendOnly(t *testing.T) {
+	_, store := newTestApp(t)
+	seedCandles(t, store, 1)
+
+	var entry model.AuditEntry
+	assert.Nil(t, store.DB().Where("action = ?", model.AuditCandleImport).First(&entry).Error)
+	assert.Contains(t, entry.After, fmt.Sprintf(`"rows":%d`, len(fields)))
+
+	assert.NotNil(t, store.DB().Model(&entry).Update("actor", "someone else").Error, "Entries must not be updated")
+	assert.NotNil(t, store.DB().Delete(&entry).Error, "Entries must not be deleted")
+}
+
+func TestAuditInvalidTimeRange(t *testing.T) {
+	appRouter, _ := newTestApp(t)
+
+	req, _ := http.NewRequest(http.MethodGet, "/audit?since=yesterday", nil)
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Status code must be 400")
+	assert.Contains(t, w.Body.String(), "since must be an RFC 3339 time")
+}
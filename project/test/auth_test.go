@@ -0,0 +1,134 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"csvapi-test/auth"
+	"csvapi-test/config"
+	"csvapi-test/model"
+	"csvapi-test/repository"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type APIKeyResponse struct {
+	Data   model.APIKey `json:"data"`
+	Key    string       `json:"key"`
+	Status string       `json:"status"`
+}
+
+// Create an api key with the scopes directly in the store
+func createAPIKey(t *testing.T, store *repository.GormCandleStore, name string, scopes ...string) string {
+	t.Helper()
+	return createTenantAPIKey(t, store, "", name, scopes...)
+}
+
+// Same as createAPIKey in the tenant
+func createTenantAPIKey(t *testing.T, store *repository.GormCandleStore, tenant, name string, scopes ...string) string {
+	t.Helper()
+	ctx := auth.WithTenant(context.Background(), tenant)
+	_, key, err := repository.NewGormAPIKeyStore(store.DB()).Create(ctx, name, scopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestAuthRequiresAPIKey(t *testing.T) {
+	appRouter, _ := newTestAppWithConfig(t, config.Default())
+
+	req, _ := http.NewRequest(http.MethodGet, "/data", nil)
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "Status code must be 401")
+	assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+
+	req, _ = http.NewRequest(http.MethodGet, "/data", nil)
+	req.Header.Set("X-API-Key", "csv_unknown")
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "Unknown key must be rejected")
+}
+
+func TestAuthScopes(t *testing.T) {
+	appRouter, store := newTestAppWithConfig(t, config.Default())
+	seedCandles(t, store, 1)
+	readKey := createAPIKey(t, store, "dashboard", auth.ScopeDataRead)
+
+	req, _ := http.NewRequest(http.MethodGet, "/data", nil)
+	req.Header.Set("Authorization", "Bearer "+readKey)
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Read key must query candles")
+
+	req, _ = http.NewRequest(http.MethodDelete, "/data/1", nil)
+	req.Header.Set("X-API-Key", readKey)
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code, "Read key must not delete candles")
+	assert.Contains(t, w.Body.String(), "data:write")
+
+	req, _ = http.NewRequest(http.MethodGet, "/audit", nil)
+	req.Header.Set("X-API-Key", readKey)
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code, "Read key must not read the audit log")
+
+	var apiKey model.APIKey
+	assert.Nil(t, store.DB().Where("name = ?", "dashboard").First(&apiKey).Error)
+	assert.NotNil(t, apiKey.LastUsedAt, "Key usage must be tracked")
+	assert.NotContains(t, apiKey.KeyHash, readKey, "Key must be hashed at rest")
+	assert.Equal(t, auth.HashAPIKey(readKey), apiKey.KeyHash)
+}
+
+func TestAdminManagesAPIKeys(t *testing.T) {
+	appRouter, store := newTestAppWithConfig(t, config.Default())
+	adminKey := createAPIKey(t, store, "ops", auth.ScopeAdmin)
+
+	body := bytes.NewBufferString(`{"name": "ci-uploader", "scopes": ["data:write"]}`)
+	req, _ := http.NewRequest(http.MethodPost, "/admin/api-keys", body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", adminKey)
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+
+	var created APIKeyResponse
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&created))
+	assert.Equal(t, http.StatusCreated, w.Code, "Status code must be 201")
+	assert.True(t, auth.IsAPIKey(created.Key))
+	assert.Equal(t, model.ScopeList{auth.ScopeDataWrite}, created.Data.Scopes)
+
+	req, _ = http.NewRequest(http.MethodDelete, "/data/1", nil)
+	req.Header.Set("X-API-Key", created.Key)
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code, "Write key must reach the handler")
+
+	var entry model.AuditEntry
+	assert.Nil(t, store.DB().Where("action = ?", model.AuditAPIKeyCreate).Last(&entry).Error)
+	assert.Contains(t, entry.Actor, "apikey:1:ops", "Key must be the audit actor")
+
+	req, _ = http.NewRequest(http.MethodDelete, "/admin/api-keys/2", nil)
+	req.Header.Set("X-API-Key", adminKey)
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Status code must be 200")
+
+	req, _ = http.NewRequest(http.MethodDelete, "/data/1", nil)
+	req.Header.Set("X-API-Key", created.Key)
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "Revoked key must be rejected")
+
+	body = bytes.NewBufferString(`{"name": "bad", "scopes": ["root"]}`)
+	req, _ = http.NewRequest(http.MethodPost, "/admin/api-keys", body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", adminKey)
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Unknown scope must be rejected")
+}
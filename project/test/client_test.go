@@ -0,0 +1,300 @@
+package test
+
+import (
+	"context"
+	"csvapi-test/auth"
+	"csvapi-test/client"
+	"csvapi-test/config"
+	"csvapi-test/model"
+	"csvapi-test/repository"
+	"csvapi-test/services"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Csv content of the sample fields, repeated times times
+func sampleCSV(times int) string {
+	var content strings.Builder
+	content.WriteString(strings.Join(csvHeader, ",") + "\n")
+	for i := 0; i < times; i++ {
+		for _, field := range fields {
+			fmt.Fprintf(&content, "%d,%s,%f,%f,%f,%f\n", field.UNIX, field.SYMBOL, field.OPEN, field.HIGH, field.LOW, field.CLOSE)
+		}
+	}
+	return content.String()
+}
+
+// Client of the app served by server, failing the test on invalid options
+func newTestClient(t *testing.T, server *httptest.Server, options client.Options) *client.Client {
+	t.Helper()
+	apiClient, err := client.New(server.URL, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return apiClient
+}
+
+// Problem code of the client error, empty if err is not one
+func problemCode(err error) services.ErrorCode {
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) {
+		return services.ErrorCode(apiErr.Code)
+	}
+	return ""
+}
+
+func TestClientImport(t *testing.T) {
+	appRouter, _ := newTestApp(t)
+	server := httptest.NewServer(appRouter)
+	defer server.Close()
+	apiClient := newTestClient(t, server, client.Options{})
+	ctx := context.Background()
+
+	content := sampleCSV(2)
+	var sent, total int64
+	result, err := apiClient.Import(ctx, "candles.csv", strings.NewReader(content), client.ImportOptions{
+		RequestID: "client-import",
+		Progress:  func(s, t int64) { sent, total = s, t },
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "10", result.TotalSavedRows)
+	assert.Equal(t, int64(len(content)), total)
+	assert.Equal(t, total, sent, "Progress must reach the file size")
+
+	status, err := apiClient.ImportStatus(ctx, "client-import")
+	assert.Nil(t, err)
+	assert.Equal(t, client.ImportCommitted, status.Status)
+	assert.Equal(t, 10, status.Summary.Rows)
+	assert.Equal(t, "candles.csv", status.Summary.FileName)
+	assert.NotNil(t, status.FinishedAt)
+
+	_, err = apiClient.ImportStatus(ctx, "unknown")
+	assert.Equal(t, services.CodeNotFound, problemCode(err))
+
+	_, err = apiClient.Import(ctx, "candles.csv", strings.NewReader("UNIX,SYMBOL\n"), client.ImportOptions{})
+	assert.Equal(t, services.CodeCsvInvalidHeader, problemCode(err), "Rejected uploads are not retried")
+}
+
+func TestClientWaitImport(t *testing.T) {
+	release := make(chan struct{})
+	appRouter, _ := newHoldingApp(t, func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+	server := httptest.NewServer(appRouter)
+	defer server.Close()
+	apiClient := newTestClient(t, server, client.Options{PollInterval: 10 * time.Millisecond})
+	ctx := context.Background()
+
+	_, done := startUpload(t, appRouter, "held-upload")
+	assert.Eventually(t, func() bool {
+		status, err := apiClient.ImportStatus(ctx, "held-upload")
+		return err == nil && status.Status == client.ImportRunning
+	}, 5*time.Second, 10*time.Millisecond)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	status, err := apiClient.WaitImport(ctx, "held-upload")
+	<-done
+	assert.Nil(t, err)
+	assert.Equal(t, client.ImportCommitted, status.Status, "Waits until the import is no longer running")
+}
+
+func TestClientImportRetries(t *testing.T) {
+	appRouter, store := newTestApp(t)
+	var uploads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			appRouter.ServeHTTP(w, r)
+			return
+		}
+		switch atomic.AddInt32(&uploads, 1) {
+		case 1:
+			// Interrupted by a shutdown
+			w.Header().Set("Content-Type", services.ProblemContentType)
+			w.Header().Set("Retry-After", "20")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, `{"status":503,"code":%q}`, services.CodeImportInterrupted)
+		case 2:
+			// Committed, but the connection drops before the response
+			appRouter.ServeHTTP(httptest.NewRecorder(), r)
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			conn.Close()
+		default:
+			appRouter.ServeHTTP(w, r)
+		}
+	}))
+	defer server.Close()
+	apiClient := newTestClient(t, server, client.Options{RetryWait: time.Millisecond, MaxRetryWait: 10 * time.Millisecond})
+
+	result, err := apiClient.Import(context.Background(), "candles.csv", strings.NewReader(sampleCSV(1)), client.ImportOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "5", result.TotalSavedRows)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&uploads), "The committed upload must not be sent again")
+
+	total, err := store.Count(context.Background(), repository.CandleQuery{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), total)
+
+	atomic.StoreInt32(&uploads, 0)
+	noRetries := newTestClient(t, server, client.Options{Retries: -1})
+	_, err = noRetries.Import(context.Background(), "candles.csv", strings.NewReader(sampleCSV(1)), client.ImportOptions{})
+	assert.Equal(t, services.CodeImportInterrupted, problemCode(err))
+	var apiErr *client.Error
+	assert.True(t, errors.As(err, &apiErr) && apiErr.RetryAfter == 20*time.Second)
+}
+
+func TestClientCandles(t *testing.T) {
+	appRouter, store := newTestApp(t)
+	seedCandles(t, store, 30)
+	var pages int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&pages, 1)
+		appRouter.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	apiClient := newTestClient(t, server, client.Options{})
+	ctx := context.Background()
+
+	candles := apiClient.AllCandles(client.Query{Limit: 40})
+	count := 0
+	ids := map[uint64]bool{}
+	for candles.Next(ctx) {
+		ids[candles.Candle().ID] = true
+		count++
+	}
+	assert.Nil(t, candles.Err())
+	assert.Equal(t, 150, count)
+	assert.Len(t, ids, 150, "Every candle must be read once")
+	assert.Equal(t, int32(4), atomic.LoadInt32(&pages), "The short page is the last one")
+
+	atomic.StoreInt32(&pages, 0)
+	candles = apiClient.AllCandles(client.Query{Limit: 50})
+	for count = 0; candles.Next(ctx); count++ {
+	}
+	assert.Nil(t, candles.Err())
+	assert.Equal(t, 150, count)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&pages), "A full last page is followed by an empty page")
+
+	filtered, err := apiClient.Candles(ctx, client.Query{Filter: "close>42120", Limit: 10}, 2)
+	assert.Nil(t, err)
+	assert.Len(t, filtered, 10)
+	for _, candle := range filtered {
+		assert.Greater(t, candle.CLOSE, float32(42120))
+	}
+
+	_, err = apiClient.Candles(ctx, client.Query{Filter: "close>>1"}, 1)
+	assert.Equal(t, services.CodeInvalidFilter, problemCode(err))
+	var apiErr *client.Error
+	assert.True(t, errors.As(err, &apiErr))
+	assert.NotEmpty(t, apiErr.RequestID)
+
+	invalid := apiClient.AllCandles(client.Query{From: 2, To: 1})
+	assert.False(t, invalid.Next(ctx))
+	assert.Equal(t, services.CodeValidationFailed, problemCode(invalid.Err()))
+}
+
+func TestClientCredentials(t *testing.T) {
+	appRouter, store := newTestAppWithConfig(t, config.Default())
+	server := httptest.NewServer(appRouter)
+	defer server.Close()
+	ctx := context.Background()
+
+	_, err := newTestClient(t, server, client.Options{}).Candles(ctx, client.Query{}, 1)
+	assert.Equal(t, services.CodeUnauthorized, problemCode(err))
+
+	reader := newTestClient(t, server, client.Options{APIKey: createAPIKey(t, store, "reader", auth.ScopeDataRead)})
+	_, err = reader.Candles(ctx, client.Query{}, 1)
+	assert.Nil(t, err)
+	_, err = reader.ImportStatus(ctx, "upload")
+	assert.Equal(t, services.CodeForbidden, problemCode(err), "Import statuses require the data:write scope")
+
+	_, err = client.New("localhost:8080", client.Options{})
+	assert.NotNil(t, err, "The base url must have a scheme")
+}
+
+// Set every field of the value reachable through json to a non zero value
+func fillValue(value reflect.Value) {
+	switch value.Kind() {
+	case reflect.Pointer:
+		value.Set(reflect.New(value.Type().Elem()))
+		fillValue(value.Elem())
+	case reflect.Struct:
+		if value.Type() == reflect.TypeOf(time.Time{}) {
+			value.Set(reflect.ValueOf(time.Date(2022, 2, 13, 2, 35, 0, 0, time.UTC)))
+			return
+		}
+		for i := 0; i < value.NumField(); i++ {
+			if value.Type().Field(i).IsExported() {
+				fillValue(value.Field(i))
+			}
+		}
+	case reflect.Slice:
+		value.Set(reflect.MakeSlice(value.Type(), 1, 1))
+		fillValue(value.Index(0))
+	case reflect.Interface:
+		value.Set(reflect.ValueOf("details"))
+	case reflect.String:
+		value.SetString("value")
+	case reflect.Bool:
+		value.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value.SetInt(42)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value.SetUint(42)
+	case reflect.Float32, reflect.Float64:
+		value.SetFloat(42.5)
+	}
+}
+
+// The client has its own copies of the wire types so it does not import the server packages,
+// every field of each side must survive a round trip through the other side
+func TestClientTypesMatchServer(t *testing.T) {
+	pairs := []struct {
+		name           string
+		server, client interface{}
+	}{
+		{"candle", &model.Ohcl{}, &client.Candle{}},
+		{"import result", &model.ImportResult{}, &client.ImportResult{}},
+		{"import status", &model.ImportStatus{}, &client.ImportStatus{}},
+		{"import summary", &model.ImportSummary{}, &client.ImportSummary{}},
+		{"problem", &services.Problem{}, &client.Problem{}},
+		{"field error", &services.FieldError{}, &client.FieldError{}},
+	}
+	roundTrip := func(from, to interface{}) (sent, received string) {
+		fillValue(reflect.ValueOf(from).Elem())
+		content, err := json.Marshal(from)
+		assert.Nil(t, err)
+		assert.Nil(t, json.Unmarshal(content, to))
+		back, err := json.Marshal(to)
+		assert.Nil(t, err)
+		return string(content), string(back)
+	}
+	for _, pair := range pairs {
+		sent, received := roundTrip(pair.server, pair.client)
+		assert.JSONEq(t, sent, received, "Client %s must read every field of the server", pair.name)
+
+		fresh := reflect.New(reflect.TypeOf(pair.server).Elem()).Interface()
+		sent, received = roundTrip(pair.client, fresh)
+		assert.JSONEq(t, sent, received, "Server %s must have every field of the client", pair.name)
+	}
+	assert.Equal(t, model.ImportRunning, client.ImportRunning)
+	assert.Equal(t, model.ImportCommitted, client.ImportCommitted)
+	assert.Equal(t, model.ImportInterrupted, client.ImportInterrupted)
+}
@@ -0,0 +1,74 @@
+package test
+
+import (
+	"csvapi-test/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yml")
+	content := "server:\n  port: \"9000\"\n  request_timeout: 30s\ndatabase:\n  driver: sqlite\nimport:\n  chunk_size: 500\n"
+	if err := os.WriteFile(configFile, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("IMPORT_CHUNK_SIZE", "1000")
+
+	cfg, args, err := config.Load([]string{"-config", configFile, "-port", "9100", "migrate", "up"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"migrate", "up"}, args, "Args after the flags must be returned")
+	assert.Equal(t, "9100", cfg.Server.Port, "Flag must override the config file")
+	assert.Equal(t, 1000, cfg.Import.ChunkSize, "Environment must override the config file")
+	assert.Equal(t, 30*time.Second, cfg.Server.RequestTimeout, "Config file must override the default")
+	assert.Equal(t, "sqlite", cfg.Database.Driver)
+	assert.Equal(t, 20, cfg.Import.MaxExtraWorkers, "Unset values must keep the default")
+}
+
+func TestLoadConfigEnvOverridesFile(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yml")
+	content := "database:\n  driver: sqlite\nimport:\n  worker_file_size: 1048576\ncors:\n  max_age: 1h\n"
+	if err := os.WriteFile(configFile, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _, err := config.Load([]string{"-config", configFile})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1<<20), cfg.Import.WorkerFileSize)
+	assert.Equal(t, time.Hour, cfg.CORS.MaxAge)
+
+	t.Setenv("IMPORT_WORKER_FILE_SIZE", "8388608")
+	t.Setenv("CORS_MAX_AGE", "30m")
+	cfg, _, err = config.Load([]string{"-config", configFile})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(8<<20), cfg.Import.WorkerFileSize, "Environment must override the config file")
+	assert.Equal(t, 30*time.Minute, cfg.CORS.MaxAge, "Environment must override the config file")
+
+	t.Setenv("CORS_MAX_AGE", "forever")
+	_, _, err = config.Load([]string{"-config", configFile})
+	assert.ErrorContains(t, err, "CORS_MAX_AGE")
+}
+
+func TestInvalidConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.Port = "http"
+	cfg.Database.Driver = "oracle"
+	cfg.Import.ChunkSize = 0
+	cfg.CORS.AllowCredentials = true
+	cfg.Limits.QueryBurst = 0
+	cfg.Import.ReadyDelay = -time.Second
+
+	err := cfg.Validate()
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "server.port")
+	assert.Contains(t, err.Error(), "database.driver")
+	assert.Contains(t, err.Error(), "import.chunk_size")
+	assert.Contains(t, err.Error(), "cors.allow_credentials", "Credentials must not be allowed for every origin")
+	assert.Contains(t, err.Error(), "limits.query_burst")
+	assert.Contains(t, err.Error(), "import.ready_delay")
+}
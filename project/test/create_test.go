@@ -2,8 +2,6 @@ package test
 
 import (
 	"bytes"
-	"csvapi-test/model"
-	"csvapi-test/router"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
@@ -16,7 +14,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -40,7 +37,6 @@ var (
 	}
 	//Expected data header
 	csvHeader = []string{"UNIX", "SYMBOL", "OPEN", "HIGH", "LOW", "CLOSE"}
-	appRouter *gin.Engine
 )
 
 type CreateResponse struct {
@@ -51,12 +47,8 @@ type CreateResponse struct {
 	Status string `json:"status"`
 }
 
-func init() {
-	model.DbConfig("TESTING")
-	appRouter = router.AppInstance()
-}
-
 func TestSaveSCV(t *testing.T) {
+	appRouter, _ := newTestApp(t)
 
 	file, err := os.CreateTemp("", "csv_file*.csv")
 	if err != nil {
@@ -137,6 +129,7 @@ func TestSaveSCV(t *testing.T) {
 
 // Test for non csv file uploaded
 func TestNonCsvFileType(t *testing.T) {
+	appRouter, _ := newTestApp(t)
 
 	// Create a temp test file
 	file, err := os.CreateTemp("", "csv_file*.txt")
@@ -183,6 +176,8 @@ func TestNonCsvFileType(t *testing.T) {
 
 // Test for invalid csv header against expected format
 func TestUnexpectedCvsFormat(t *testing.T) {
+	appRouter, _ := newTestApp(t)
+
 	// Create a temp test file
 	file, err := os.CreateTemp("", "csv_file.csv")
 	if err != nil {
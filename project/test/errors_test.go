@@ -0,0 +1,156 @@
+package test
+
+import (
+	"bytes"
+	"csvapi-test/config"
+	"csvapi-test/model"
+	"csvapi-test/services"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+// Decode the problem response, failing the test if it is not problem+json
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) services.Problem {
+	t.Helper()
+	assert.Equal(t, services.ProblemContentType, w.Header().Get("Content-Type"))
+	var problem services.Problem
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, w.Code, problem.Status, "Problem status must be the response status")
+	assert.Equal(t, problem.Code.Type(), problem.Type)
+	assert.Equal(t, problem.Code.Title(), problem.Title)
+	return problem
+}
+
+// Csv upload request of the file content
+func csvFileRequest(t *testing.T, content string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("csv_file", "candles.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(part, content)
+	form.Close()
+	req, _ := http.NewRequest(http.MethodPost, "/data", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req
+}
+
+func TestErrorResponses(t *testing.T) {
+	appRouter, store := newTestApp(t)
+	seedCandles(t, store, 1)
+
+	request := func(method, path, body string) *http.Request {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	for _, test := range []struct {
+		name   string
+		req    *http.Request
+		status int
+		code   services.ErrorCode
+	}{
+		{"unknown route", request(http.MethodGet, "/unknown", ""), http.StatusNotFound, services.CodeRouteNotFound},
+		{"invalid id", request(http.MethodPatch, "/data/first", ""), http.StatusBadRequest, services.CodeInvalidParameter},
+		{"missing candle", request(http.MethodDelete, "/data/404", ""), http.StatusNotFound, services.CodeNotFound},
+		{"invalid json", request(http.MethodPatch, "/data/1", "{"), http.StatusBadRequest, services.CodeInvalidBody},
+		{"invalid filter", request(http.MethodGet, "/data?filter=volume%3D1", ""), http.StatusBadRequest, services.CodeInvalidFilter},
+		{"invalid time", request(http.MethodGet, "/audit?since=yesterday", ""), http.StatusBadRequest, services.CodeInvalidParameter},
+		{"empty file", csvFileRequest(t, ""), http.StatusBadRequest, services.CodeCsvInvalidHeader},
+		{"invalid header", csvFileRequest(t, "TIME,SYMBOL\n1,BTCUSDT\n"), http.StatusBadRequest, services.CodeCsvInvalidHeader},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.req.Header.Set("X-Request-ID", "req-"+test.name)
+			w := httptest.NewRecorder()
+			appRouter.ServeHTTP(w, test.req)
+			assert.Equal(t, test.status, w.Code)
+			problem := decodeProblem(t, w)
+			assert.Equal(t, test.code, problem.Code)
+			assert.Equal(t, "req-"+test.name, problem.RequestID)
+			assert.Equal(t, test.req.URL.Path, problem.Instance)
+			assert.NotEmpty(t, problem.Detail)
+		})
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	appRouter, _ := newTestApp(t)
+
+	req, _ := http.NewRequest(http.MethodDelete, "/data?from=10&to=5", nil)
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Status code must be 400")
+
+	problem := decodeProblem(t, w)
+	assert.Equal(t, services.CodeValidationFailed, problem.Code)
+	assert.ElementsMatch(t, []services.FieldError{
+		{Field: "symbol", Rule: "required", Message: "symbol is required"},
+		{Field: "to", Rule: "gtfield", Message: "to must be greater than from"},
+	}, problem.Errors)
+}
+
+func TestRowParseError(t *testing.T) {
+	appRouter, _ := newTestApp(t)
+
+	for _, test := range []struct {
+		name, content string
+		line          int
+	}{
+		{"invalid number", "UNIX,SYMBOL,OPEN,HIGH,LOW,CLOSE\n1644719700000,BTCUSDT,1,1,1,1\n1644719640000,BTCUSDT,1,high,1,1\n", 3},
+		{"missing columns", "UNIX,SYMBOL,OPEN,HIGH,LOW,CLOSE\n1644719700000,BTCUSDT,1\n", 2},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			appRouter.ServeHTTP(w, csvFileRequest(t, test.content))
+			assert.Equal(t, http.StatusBadRequest, w.Code, "Status code must be 400")
+
+			problem := decodeProblem(t, w)
+			assert.Equal(t, services.CodeRowParseError, problem.Code)
+			details, _ := problem.Details.(map[string]interface{})
+			assert.Equal(t, float64(test.line), details["line"], "Details must point to the invalid line")
+			assert.Contains(t, problem.Detail, fmt.Sprintf("line %d", test.line))
+		})
+	}
+}
+
+func TestAuthenticationErrors(t *testing.T) {
+	appRouter, _ := newTestAppWithConfig(t, config.Default())
+
+	req, _ := http.NewRequest(http.MethodGet, "/data", nil)
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "Status code must be 401")
+	assert.Equal(t, services.CodeUnauthorized, decodeProblem(t, w).Code)
+
+	req.Header.Set("X-API-Key", "unknown")
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, services.CodeInvalidCredentials, decodeProblem(t, w).Code)
+}
+
+func TestErrorCodeCatalogue(t *testing.T) {
+	codes := services.ErrorCodes()
+	assert.Contains(t, codes, services.CodeCsvInvalidHeader)
+	assert.Contains(t, codes, services.CodeRowParseError)
+	assert.Contains(t, codes, services.CodeDBConflict)
+	for _, code := range codes {
+		assert.NotEqual(t, http.StatusText(code.Status()), "", "%s must have a valid status", code)
+		assert.NotEmpty(t, code.Title(), "%s must have a title", code)
+	}
+
+	assert.True(t, model.IsConflict(fmt.Errorf("insert: %w", sqlite3.Error{Code: sqlite3.ErrBusy})), "Locked databases are conflicts")
+	assert.False(t, model.IsConflict(sqlite3.Error{Code: sqlite3.ErrCorrupt}))
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -44,6 +45,9 @@ type FullPaginationResponse struct {
 }
 
 func TestGetMax100(t *testing.T) {
+	appRouter, store := newTestApp(t)
+	seedCandles(t, store, 30)
+
 	var response SimpleResponse
 
 	w := httptest.NewRecorder()
@@ -82,6 +86,9 @@ func TestGetMax100(t *testing.T) {
 // }
 
 func TestGetWithFullPagination(t *testing.T) {
+	appRouter, store := newTestApp(t)
+	seedCandles(t, store, 30)
+
 	req, err := http.NewRequest("GET", "/data?limit=10&ptype=full", nil)
 	if err != nil {
 		t.Fatal(err)
@@ -98,3 +105,73 @@ func TestGetWithFullPagination(t *testing.T) {
 	assert.Equal(t, response.Status, "success", "Response status must be success")
 	assert.LessOrEqual(t, len(response.Data), 100, "Length data must not be greater than 100")
 }
+
+func TestGetWithFilter(t *testing.T) {
+	appRouter, store := newTestApp(t)
+	seedCandles(t, store, 30)
+
+	filter := url.QueryEscape("close>42114;symbol in (BTCUSDT,ETHUSDT)")
+	req, err := http.NewRequest("GET", "/data?limit=50&filter="+filter, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	var response SimpleResponse
+
+	err = json.NewDecoder(w.Body).Decode(&response)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, w.Code, "Status code must be 200")
+	assert.Equal(t, response.Status, "success", "Response status must be success")
+	assert.NotEmpty(t, response.Data, "Filtered data must not be empty")
+	for _, row := range response.Data {
+		assert.Greater(t, row.CLOSE, float32(42114))
+		assert.Equal(t, "BTCUSDT", row.SYMBOL)
+	}
+}
+
+func TestGetWithInvalidFilter(t *testing.T) {
+	appRouter, _ := newTestApp(t)
+
+	filter := url.QueryEscape("close>42000;volume=1")
+	req, err := http.NewRequest("GET", "/data?filter="+filter, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	var response struct {
+		Details services.FilterError `json:"details"`
+	}
+
+	err = json.NewDecoder(w.Body).Decode(&response)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Status code must be 400")
+	assert.Equal(t, "volume", response.Details.Token)
+	assert.Equal(t, 12, response.Details.Position)
+}
+
+func TestGetWithTimeRange(t *testing.T) {
+	appRouter, store := newTestApp(t)
+	seedCandles(t, store, 10)
+
+	req, err := http.NewRequest("GET", "/data?from=1644719520000&to=1644719640000", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	var response SimpleResponse
+
+	err = json.NewDecoder(w.Body).Decode(&response)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, w.Code, "Status code must be 200")
+	assert.Len(t, response.Data, 20, "Only the two minutes in range must be returned")
+	for _, row := range response.Data {
+		assert.GreaterOrEqual(t, row.UNIX, uint64(1644719520000))
+		assert.Less(t, row.UNIX, uint64(1644719640000))
+	}
+}
@@ -0,0 +1,100 @@
+package test
+
+import (
+	"context"
+	"csvapi-test/config"
+	"csvapi-test/drain"
+	"csvapi-test/health"
+	"csvapi-test/repository"
+	"csvapi-test/retention"
+	"csvapi-test/router"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type ReadinessResponse struct {
+	Status string                   `json:"status"`
+	Data   map[string]health.Result `json:"data"`
+}
+
+// Fetch /readyz
+func readiness(t *testing.T, appRouter *gin.Engine) (int, ReadinessResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/readyz", nil)
+	appRouter.ServeHTTP(w, req)
+	var response ReadinessResponse
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&response))
+	return w.Code, response
+}
+
+func TestHealthProbes(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.MinSpoolFree = 1 // any free space
+	appRouter, _ := newTestAppWithConfig(t, cfg)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/healthz", nil)
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Liveness needs no authentication")
+
+	code, response := readiness(t, appRouter)
+	assert.Equal(t, http.StatusOK, code, "Status code must be 200")
+	assert.Equal(t, "success", response.Status)
+	assert.Equal(t, health.StatusOK, response.Data["database"].Status)
+	assert.Equal(t, health.StatusOK, response.Data["migrations"].Status)
+	assert.Equal(t, health.StatusDisabled, response.Data["jobs"].Status, "Retention runs are not scheduled by default")
+	assert.Equal(t, health.StatusOK, response.Data["spool"].Status)
+}
+
+func TestReadinessFailures(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.Enabled = false
+	cfg.Server.MinSpoolFree = 1 << 62
+	cfg.Retention.Interval = time.Hour
+	cfg.Retention.Policies = []config.RetentionPolicy{
+		{Name: "minutes-90d", MaxAge: 90 * 24 * time.Hour, Resolution: 24 * time.Hour, Action: "delete"},
+	}
+	db := newTestDB(t, cfg)
+	runner := retention.NewRunner(db, cfg.Retention)
+	imports := drain.New()
+	checker := health.NewChecker(db, runner, imports, cfg.Server.MinSpoolFree)
+	appRouter := router.AppInstance(cfg, router.Dependencies{
+		Store:     repository.NewGormCandleStore(db),
+		Audit:     repository.NewGormAuditStore(db),
+		APIKeys:   repository.NewGormAPIKeyStore(db),
+		Retention: runner,
+		Health:    checker,
+		Imports:   imports,
+	})
+
+	code, response := readiness(t, appRouter)
+	assert.Equal(t, http.StatusServiceUnavailable, code, "Status code must be 503")
+	assert.Equal(t, "failed", response.Status)
+	assert.Equal(t, health.StatusFailed, response.Data["jobs"].Status, "The retention runner is not started")
+	assert.Equal(t, health.StatusFailed, response.Data["spool"].Status, "Not enough free space for uploads")
+	assert.Equal(t, health.StatusOK, response.Data["database"].Status)
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go runner.Start(ctx)
+	assert.Eventually(t, runner.Alive, time.Second, 10*time.Millisecond)
+	_, response = readiness(t, appRouter)
+	assert.Equal(t, health.StatusOK, response.Data["jobs"].Status)
+
+	checker.Shutdown()
+	code, response = readiness(t, appRouter)
+	assert.Equal(t, http.StatusServiceUnavailable, code, "Not ready once shutdown starts")
+	assert.Equal(t, health.StatusFailed, response.Data["shutdown"].Status)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/healthz", nil)
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Still alive while shutting down")
+}
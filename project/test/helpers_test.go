@@ -0,0 +1,119 @@
+package test
+
+import (
+	"context"
+	"csvapi-test/auth"
+	"csvapi-test/config"
+	"csvapi-test/drain"
+	"csvapi-test/health"
+	"csvapi-test/metrics"
+	"csvapi-test/model"
+	"csvapi-test/repository"
+	"csvapi-test/retention"
+	"csvapi-test/router"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// App router backed by an in memory store isolated to the test, closed when the test ends.
+// Authentication is disabled, auth tests use newTestAppWithConfig with the default settings.
+func newTestApp(t *testing.T) (*gin.Engine, *repository.GormCandleStore) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Auth.Enabled = false
+	return newTestAppWithConfig(t, cfg)
+}
+
+// Same as newTestApp with the given settings, the database settings are replaced
+func newTestAppWithConfig(t *testing.T, cfg *config.Config) (*gin.Engine, *repository.GormCandleStore) {
+	t.Helper()
+	db := newTestDB(t, cfg)
+
+	var tokens *auth.TokenVerifier
+	if cfg.Auth.JWT.Enabled() {
+		var err error
+		if tokens, err = auth.NewTokenVerifier(cfg.Auth.JWT); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := repository.NewGormCandleStore(db).WithQuotas(cfg.Tenants)
+	retentionRunner := retention.NewRunner(db, cfg.Retention)
+	imports := drain.New()
+	app := router.AppInstance(cfg, router.Dependencies{
+		Store:     store,
+		Audit:     repository.NewGormAuditStore(db),
+		APIKeys:   repository.NewGormAPIKeyStore(db),
+		Tokens:    tokens,
+		Retention: retentionRunner,
+		Health:    health.NewChecker(db, retentionRunner, imports, cfg.Server.MinSpoolFree),
+		Imports:   imports,
+	})
+	return app, store
+}
+
+// In memory sqlite database isolated to the test, replacing the database settings of cfg
+func newTestDB(t *testing.T, cfg *config.Config) *gorm.DB {
+	t.Helper()
+	cfg.Database.Driver = "sqlite"
+	cfg.Database.Name = strings.ReplaceAll(t.Name(), "/", "_")
+
+	db, err := model.DbConfig(cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The connection of a cancelled transaction is discarded, keep one open so the in memory database outlives it
+	conn, err := sqlDB.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		sqlDB.Close()
+	})
+	metrics.RegisterDB(sqlDB, cfg.Database.Name)
+	return db
+}
+
+// Save the sample fields into the store, repeated times times
+func seedCandles(t *testing.T, store repository.CandleStore, times int) {
+	t.Helper()
+	seedTenantCandles(t, store, "", times)
+}
+
+// Same as seedCandles in the tenant
+func seedTenantCandles(t *testing.T, store repository.CandleStore, tenant string, times int) {
+	t.Helper()
+	rows := make([]model.Ohcl, 0, times*len(fields))
+	for i := 0; i < times; i++ {
+		for _, field := range fields {
+			rows = append(rows, model.Ohcl{
+				UNIX:   field.UNIX,
+				SYMBOL: field.SYMBOL,
+				OPEN:   field.OPEN,
+				HIGH:   field.HIGH,
+				LOW:    field.LOW,
+				CLOSE:  field.CLOSE,
+			})
+		}
+	}
+
+	candleImport, err := store.BeginImport(auth.WithTenant(context.Background(), tenant))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = candleImport.InsertBatch(rows); err != nil {
+		candleImport.Rollback()
+		t.Fatal(err)
+	}
+	if err = candleImport.Commit(model.ImportSummary{FileName: "seed.csv", Rows: len(rows)}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,290 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"csvapi-test/auth"
+	"csvapi-test/config"
+	"csvapi-test/model"
+	"csvapi-test/services"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// Local OIDC issuer stub signing tokens with a generated key and serving its JWKS
+type stubIssuer struct {
+	key    *rsa.PrivateKey
+	kid    string
+	server *httptest.Server
+}
+
+func newStubIssuer(t *testing.T) *stubIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &stubIssuer{key: key, kid: "stub-key-1"}
+	issuer.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(issuer.jwks())
+	}))
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (issuer *stubIssuer) jwks() []byte {
+	content, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": issuer.kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(issuer.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(issuer.key.E)).Bytes()),
+		}},
+	})
+	return content
+}
+
+// Signed token for the subject with the roles, claims override the defaults
+func (issuer *stubIssuer) token(t *testing.T, subject string, roles []string, claims jwt.MapClaims) string {
+	t.Helper()
+	tokenClaims := jwt.MapClaims{
+		"iss":   issuer.server.URL,
+		"aud":   "csvapi",
+		"sub":   subject,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": roles,
+	}
+	for name, value := range claims {
+		tokenClaims[name] = value
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, tokenClaims)
+	token.Header["kid"] = issuer.kid
+	signed, err := token.SignedString(issuer.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func (issuer *stubIssuer) config() *config.Config {
+	cfg := config.Default()
+	cfg.Auth.JWT.JWKSURL = issuer.server.URL
+	cfg.Auth.JWT.Issuer = issuer.server.URL
+	cfg.Auth.JWT.Audience = "csvapi"
+	cfg.Auth.JWT.RoleScopes = map[string][]string{
+		"viewer":   {"data:read"},
+		"uploader": {"data:read", "data:write"},
+	}
+	return cfg
+}
+
+func bearerRequest(method, url, token string) *http.Request {
+	req, _ := http.NewRequest(method, url, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestJWTRolesGateRoutes(t *testing.T) {
+	issuer := newStubIssuer(t)
+	cfg := issuer.config()
+	cfg.Database.Driver = "sqlite"
+	assert.Nil(t, cfg.Validate())
+	appRouter, store := newTestAppWithConfig(t, cfg)
+	seedCandles(t, store, 1)
+
+	viewer := issuer.token(t, "alice", []string{"viewer"}, nil)
+	uploader := issuer.token(t, "bob", []string{"uploader"}, nil)
+
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, bearerRequest(http.MethodGet, "/data", viewer))
+	assert.Equal(t, http.StatusOK, w.Code, "Viewer must query candles")
+
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, bearerRequest(http.MethodPost, "/data", viewer))
+	assert.Equal(t, http.StatusForbidden, w.Code, "Viewer must not upload candles")
+
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, bearerRequest(http.MethodDelete, "/data/1", uploader))
+	assert.Equal(t, http.StatusOK, w.Code, "Uploader must delete candles")
+
+	var entry model.AuditEntry
+	assert.Nil(t, store.DB().Where("action = ?", model.AuditCandleDelete).First(&entry).Error)
+	assert.Equal(t, "jwt:bob", entry.Actor, "Token subject must be the audit actor")
+}
+
+func TestJWTRejectsInvalidTokens(t *testing.T) {
+	issuer := newStubIssuer(t)
+	appRouter, _ := newTestAppWithConfig(t, issuer.config())
+
+	hmacToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "mallory", "exp": time.Now().Add(time.Hour).Unix(), "roles": []string{"uploader"},
+	}).SignedString([]byte("secret"))
+
+	for name, token := range map[string]string{
+		"expired":        issuer.token(t, "alice", []string{"viewer"}, jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}),
+		"wrong issuer":   issuer.token(t, "alice", []string{"viewer"}, jwt.MapClaims{"iss": "https://elsewhere"}),
+		"wrong audience": issuer.token(t, "alice", []string{"viewer"}, jwt.MapClaims{"aud": "other-app"}),
+		"hmac signed":    hmacToken,
+		"malformed":      "not.a.token",
+	} {
+		w := httptest.NewRecorder()
+		appRouter.ServeHTTP(w, bearerRequest(http.MethodGet, "/data", token))
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "Token must be rejected: "+name)
+	}
+
+	// Valid token of a role without mapping grants nothing
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, bearerRequest(http.MethodGet, "/data", issuer.token(t, "eve", []string{"guest"}, nil)))
+	assert.Equal(t, http.StatusForbidden, w.Code, "Unmapped role must be forbidden")
+}
+
+func TestJWTKeySetUnavailable(t *testing.T) {
+	issuer := newStubIssuer(t)
+	var available int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&available) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write(issuer.jwks())
+	}))
+	defer server.Close()
+	cfg := issuer.config()
+	cfg.Auth.JWT.JWKSURL = server.URL
+	appRouter, _ := newTestAppWithConfig(t, cfg)
+	token := issuer.token(t, "alice", []string{"viewer"}, nil)
+
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, bearerRequest(http.MethodGet, "/data", token))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "Token must not be rejected as invalid when the keys cannot be fetched")
+	assert.Equal(t, services.CodeAuthUnavailable, decodeProblem(t, w).Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	atomic.StoreInt32(&available, 1)
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, bearerRequest(http.MethodGet, "/data", token))
+	assert.Equal(t, http.StatusOK, w.Code, "Retried request must be verified once the keys can be fetched")
+}
+
+func TestJWTKeySetFile(t *testing.T) {
+	issuer := newStubIssuer(t)
+	cfg := issuer.config()
+	cfg.Auth.JWT.JWKSURL = ""
+	cfg.Auth.JWT.JWKSFile = filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(cfg.Auth.JWT.JWKSFile, issuer.jwks(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Auth.JWT.RolesClaim = "realm_access.roles"
+	appRouter, _ := newTestAppWithConfig(t, cfg)
+
+	token := issuer.token(t, "alice", nil, jwt.MapClaims{"realm_access": map[string]interface{}{"roles": []string{"viewer"}}})
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, bearerRequest(http.MethodGet, "/data", token))
+	assert.Equal(t, http.StatusOK, w.Code, "Nested roles claim must be mapped")
+}
+
+func TestJWTTenantClaim(t *testing.T) {
+	issuer := newStubIssuer(t)
+	cfg := issuer.config()
+	cfg.Auth.JWT.TenantClaim = "tenant"
+	appRouter, store := newTestAppWithConfig(t, cfg)
+	seedTenantCandles(t, store, "acme", 1)
+
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, bearerRequest(http.MethodGet, "/data", issuer.token(t, "alice", []string{"viewer"}, nil)))
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "Token without tenant must be rejected")
+
+	token := issuer.token(t, "alice", []string{"viewer"}, jwt.MapClaims{"tenant": "acme"})
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, bearerRequest(http.MethodGet, "/data", token))
+
+	var response SimpleResponse
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, http.StatusOK, w.Code, "Status code must be 200")
+	assert.Len(t, response.Data, len(fields), "Candles of the token tenant must be returned")
+}
+
+func TestJWKSRefreshDoesNotBlock(t *testing.T) {
+	issuer := newStubIssuer(t)
+	var fetches int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			<-release // the refresh hangs
+		}
+		w.Write(issuer.jwks())
+	}))
+	defer server.Close()
+	defer close(release)
+
+	keySet := auth.NewRemoteKeySet(server.URL, 50*time.Millisecond)
+	ctx := context.Background()
+	_, err := keySet.Key(ctx, issuer.kid)
+	assert.Nil(t, err)
+
+	time.Sleep(60 * time.Millisecond)
+	go keySet.Key(ctx, issuer.kid) // starts the refresh
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&fetches) == 2 }, time.Second, time.Millisecond)
+
+	done := make(chan error)
+	go func() {
+		_, err := keySet.Key(ctx, issuer.kid)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.Nil(t, err, "The previous keys are served during the refresh")
+	case <-time.After(time.Second):
+		t.Fatal("Key must not wait for the running refresh")
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches), "Only one refresh runs at a time")
+}
+
+func TestJWKSFetchOutlivesCancelledRequest(t *testing.T) {
+	issuer := newStubIssuer(t)
+	var fetches int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		w.Write(issuer.jwks())
+	}))
+	defer server.Close()
+
+	// The cold start fetch is started by a request that is cancelled while it runs
+	keySet := auth.NewRemoteKeySet(server.URL, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error)
+	go func() {
+		_, err := keySet.Key(ctx, issuer.kid)
+		cancelled <- err
+	}()
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&fetches) == 1 }, time.Second, time.Millisecond)
+
+	waiting := make(chan error)
+	go func() {
+		_, err := keySet.Key(context.Background(), issuer.kid)
+		waiting <- err
+	}()
+	cancel()
+	assert.ErrorIs(t, <-cancelled, auth.ErrKeySetUnavailable)
+
+	close(release)
+	assert.Nil(t, <-waiting, "The other requests get the keys of the shared fetch")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches), "The fetch is not started again")
+}
@@ -0,0 +1,99 @@
+package test
+
+import (
+	"csvapi-test/auth"
+	"csvapi-test/config"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryRateLimit(t *testing.T) {
+	cfg := config.Default()
+	cfg.Limits.QueryRate = 0.5
+	cfg.Limits.QueryBurst = 2
+	appRouter, store := newTestAppWithConfig(t, cfg)
+	key := createAPIKey(t, store, "reader", auth.ScopeDataRead)
+	otherKey := createAPIKey(t, store, "other-reader", auth.ScopeDataRead)
+
+	query := func(key string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/data", nil)
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		appRouter.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < cfg.Limits.QueryBurst; i++ {
+		assert.Equal(t, http.StatusOK, query(key).Code, "Requests within the burst must be served")
+	}
+	w := query(key)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "Status code must be 429")
+	assert.Equal(t, "2", w.Header().Get("Retry-After"), "Next token is available in 2 seconds")
+
+	assert.Equal(t, http.StatusOK, query(otherKey).Code, "Each api key has its own bucket")
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Status code must be 200")
+	assert.Contains(t, w.Body.String(), `csvapi_rate_limited_requests_total{route="/data"}`)
+	assert.Contains(t, w.Body.String(), `csvapi_limit{limit="query_rate"} 0.5`)
+}
+
+func TestImportConcurrencyLimit(t *testing.T) {
+	cfg := config.Default()
+	cfg.Limits.MaxClientImports = 1
+	cfg.Limits.ImportRetryAfter = 15 * time.Second
+	appRouter, store := newTestAppWithConfig(t, cfg)
+	key := createAPIKey(t, store, "uploader", auth.ScopeDataWrite)
+	otherKey := createAPIKey(t, store, "other-uploader", auth.ScopeDataWrite)
+
+	// The first upload holds its import slot while its body is being sent
+	bodyReader, bodyWriter := io.Pipe()
+	form := multipart.NewWriter(bodyWriter)
+	req, _ := http.NewRequest(http.MethodPost, "/data", bodyReader)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("X-API-Key", key)
+	first := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		appRouter.ServeHTTP(first, req)
+	}()
+
+	// Uploads admitted alongside are empty forms rejected with 400
+	upload := func(key string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/data", strings.NewReader(""))
+		req.Header.Set("Content-Type", "multipart/form-data; boundary=none")
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		appRouter.ServeHTTP(w, req)
+		return w
+	}
+
+	var w *httptest.ResponseRecorder
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if w = upload(key); w.Code == http.StatusTooManyRequests {
+			break
+		}
+	}
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "A second concurrent import of the client must be rejected")
+	assert.Equal(t, "15", w.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusBadRequest, upload(otherKey).Code, "Other clients must still be admitted")
+
+	part, _ := form.CreateFormFile("csv_file", "candles.csv")
+	part.Write([]byte("UNIX,SYMBOL,OPEN,HIGH,LOW,CLOSE\n1644719700000,BTCUSDT,1,1,1,1\n"))
+	form.Close()
+	bodyWriter.Close()
+	<-done
+	assert.Equal(t, http.StatusCreated, first.Code, "Status code must be 201")
+
+	assert.Equal(t, http.StatusBadRequest, upload(key).Code, "The slot must be released once the import ends")
+}
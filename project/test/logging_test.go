@@ -0,0 +1,126 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"csvapi-test/config"
+	"csvapi-test/logging"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Capture the logs of the test as decoded json records
+func captureLogs(t *testing.T, level string) func() []map[string]interface{} {
+	t.Helper()
+	var buffer bytes.Buffer
+	logger, err := logging.New(config.LogConfig{Level: level, Format: "json"}, &buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	return func() []map[string]interface{} {
+		var records []map[string]interface{}
+		scanner := bufio.NewScanner(bytes.NewReader(buffer.Bytes()))
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			var record map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				t.Fatalf("Invalid log line %q: %v", scanner.Text(), err)
+			}
+			records = append(records, record)
+		}
+		return records
+	}
+}
+
+// Records with the message
+func logsWithMessage(records []map[string]interface{}, message string) (found []map[string]interface{}) {
+	for _, record := range records {
+		if record["msg"] == message {
+			found = append(found, record)
+		}
+	}
+	return found
+}
+
+func TestRequestLogsCarryRequestID(t *testing.T) {
+	appRouter, _ := newTestApp(t)
+	logs := captureLogs(t, "debug")
+
+	req := csvUploadRequest(t)
+	req.Header.Set("X-Request-ID", "upload-42")
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code, "Status code must be 201")
+
+	req, _ = http.NewRequest(http.MethodGet, "/data", nil)
+	req.Header.Set("X-Request-ID", "query-7")
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Status code must be 200")
+
+	req, _ = http.NewRequest(http.MethodPatch, "/data/first", nil)
+	req.Header.Set("X-Request-ID", "patch-9")
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Status code must be 400")
+
+	var response map[string]interface{}
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, "patch-9", response["request_id"], "Error responses must carry the request id")
+
+	records := logs()
+	started := logsWithMessage(records, "import started")
+	finished := logsWithMessage(records, "import finished")
+	if assert.Len(t, started, 1) && assert.Len(t, finished, 1) {
+		assert.Equal(t, "upload-42", started[0]["request_id"])
+		assert.Equal(t, "candles.csv", started[0]["file"])
+		assert.Equal(t, "upload-42", finished[0]["request_id"])
+		assert.Equal(t, "committed", finished[0]["result"])
+		assert.Equal(t, float64(len(fields)), finished[0]["rows_saved"])
+	}
+
+	queries := map[interface{}]int{}
+	for _, record := range logsWithMessage(records, "query") {
+		queries[record["request_id"]]++
+	}
+	assert.Greater(t, queries["query-7"], 0, "Queries must be logged with the request id")
+	assert.Zero(t, queries["upload-42"], "Batch inserts of imports are too large for query logs")
+
+	requests := logsWithMessage(records, "request")
+	if assert.Len(t, requests, 3) {
+		assert.Equal(t, "upload-42", requests[0]["request_id"])
+		assert.Equal(t, "/data", requests[0]["route"])
+		assert.Equal(t, float64(http.StatusCreated), requests[0]["status"])
+		assert.Equal(t, "INFO", requests[0]["level"])
+		assert.Equal(t, "query-7", requests[1]["request_id"])
+		assert.Equal(t, "patch-9", requests[2]["request_id"])
+		assert.Equal(t, "WARN", requests[2]["level"], "Client errors are logged as warnings")
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	appRouter, _ := newTestApp(t)
+	logs := captureLogs(t, "warn")
+
+	req, _ := http.NewRequest(http.MethodGet, "/data", nil)
+	appRouter.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Empty(t, logs(), "Successful requests and queries are below the warn level")
+
+	cfg := config.Default()
+	cfg.Log.Level = "verbose"
+	cfg.Log.Format = "xml"
+	err := cfg.Validate()
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "log.level")
+		assert.Contains(t, err.Error(), "log.format")
+	}
+}
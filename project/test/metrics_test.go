@@ -0,0 +1,156 @@
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+)
+
+// Metric names documented in the readme
+var documentedMetrics = []string{
+	"csvapi_http_requests_total",
+	"csvapi_http_request_duration_seconds",
+	"csvapi_http_requests_in_flight",
+	"csvapi_imports_total",
+	"csvapi_import_duration_seconds",
+	"csvapi_import_bytes_total",
+	"csvapi_import_rows_read_total",
+	"csvapi_import_rows_saved_total",
+	"csvapi_import_rows_rejected_total",
+	"csvapi_import_workers",
+	"csvapi_import_workers_busy",
+	"csvapi_import_worker_busy_seconds_total",
+	"csvapi_import_channel_depth",
+	"csvapi_limit",
+	"csvapi_rate_limit_clients",
+	"csvapi_imports_in_flight",
+	"go_sql_open_connections",
+	"go_sql_in_use_connections",
+	"go_sql_idle_connections",
+	"go_sql_wait_count_total",
+	"go_sql_wait_duration_seconds_total",
+}
+
+// Scrape /metrics into values by series e.g name{label="value"}, histograms as their _count and _sum series
+func scrapeMetrics(t *testing.T, appRouter *gin.Engine) map[string]float64 {
+	t.Helper()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+	appRouter.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Scraping metrics returned %d", w.Code)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]float64{}
+	for name, family := range families {
+		for _, metric := range family.Metric {
+			labels := make([]string, 0, len(metric.Label))
+			for _, label := range metric.Label {
+				labels = append(labels, fmt.Sprintf("%s=%q", label.GetName(), label.GetValue()))
+			}
+			sort.Strings(labels)
+			series := "{" + strings.Join(labels, ",") + "}"
+
+			switch {
+			case metric.Counter != nil:
+				values[name+series] = metric.Counter.GetValue()
+			case metric.Gauge != nil:
+				values[name+series] = metric.Gauge.GetValue()
+			case metric.Histogram != nil:
+				values[name+"_count"+series] = float64(metric.Histogram.GetSampleCount())
+				values[name+"_sum"+series] = metric.Histogram.GetSampleSum()
+			}
+		}
+		if len(family.Metric) == 0 {
+			values[name] = 0
+		}
+	}
+	return values
+}
+
+// Whether a series of the metric is exposed
+func hasMetric(values map[string]float64, name string) bool {
+	for series := range values {
+		if series == name || strings.HasPrefix(series, name+"{") || strings.HasPrefix(series, name+"_count{") {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMetrics(t *testing.T) {
+	appRouter, store := newTestApp(t)
+	seedCandles(t, store, 1)
+	before := scrapeMetrics(t, appRouter)
+
+	for _, request := range []struct{ method, path string }{
+		{http.MethodGet, "/data"},
+		{http.MethodGet, "/data"},
+		{http.MethodPatch, "/data/1"},
+		{http.MethodGet, "/unknown"},
+		{"BREW", "/unknown"},
+	} {
+		req, _ := http.NewRequest(request.method, request.path, strings.NewReader(`{"open": 42130}`))
+		req.Header.Set("Content-Type", "application/json")
+		appRouter.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, csvUploadRequest(t))
+	assert.Equal(t, http.StatusCreated, w.Code, "Status code must be 201")
+
+	// Import rolled back on its last row
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("csv_file", "invalid.csv")
+	part.Write([]byte("UNIX,SYMBOL,OPEN,HIGH,LOW,CLOSE\n1644719700000,BTCUSDT,1,1,1,1\n1644719640000,BTCUSDT,1\n"))
+	form.Close()
+	req, _ := http.NewRequest(http.MethodPost, "/data", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Invalid rows are client errors")
+
+	after := scrapeMetrics(t, appRouter)
+	delta := func(series string) float64 {
+		return after[series] - before[series]
+	}
+
+	assert.Equal(t, 2.0, delta(`csvapi_http_requests_total{method="GET",route="/data",status="200"}`))
+	assert.Equal(t, 1.0, delta(`csvapi_http_requests_total{method="PATCH",route="/data/:id",status="200"}`), "Routes are labelled by template")
+	assert.Equal(t, 1.0, delta(`csvapi_http_requests_total{method="GET",route="unmatched",status="404"}`))
+	assert.Equal(t, 1.0, delta(`csvapi_http_requests_total{method="other",route="unmatched",status="404"}`), "Unknown methods share a series")
+	assert.Equal(t, 1.0, delta(`csvapi_http_requests_total{method="POST",route="/data",status="201"}`))
+	assert.Equal(t, 2.0, delta(`csvapi_http_request_duration_seconds_count{method="GET",route="/data"}`))
+
+	assert.Equal(t, 1.0, delta(`csvapi_imports_total{result="committed"}`))
+	assert.Equal(t, 1.0, delta(`csvapi_imports_total{result="rolled_back"}`))
+	assert.Equal(t, 1.0, delta(`csvapi_import_duration_seconds_count{result="committed"}`))
+	assert.Equal(t, float64(len(fields)+1), delta(`csvapi_import_rows_read_total{}`), "Rows of both imports parsed before the invalid row")
+	assert.Equal(t, float64(len(fields)), delta(`csvapi_import_rows_saved_total{}`))
+	assert.Equal(t, 1.0, delta(`csvapi_import_rows_rejected_total{}`))
+	assert.Greater(t, delta(`csvapi_import_bytes_total{}`), 0.0)
+	assert.Greater(t, delta(`csvapi_import_worker_busy_seconds_total{}`), 0.0)
+	assert.Equal(t, 0.0, after[`csvapi_import_workers{}`], "Workers must exit once the imports end")
+	assert.Equal(t, 0.0, after[`csvapi_import_workers_busy{}`])
+	assert.Equal(t, 0.0, after[`csvapi_import_channel_depth{}`])
+
+	assert.Contains(t, after, `go_sql_max_open_connections{db_name="TestMetrics"}`, "Pool stats of the app database")
+	for _, name := range documentedMetrics {
+		assert.True(t, hasMetric(after, name), "%s must be exposed", name)
+	}
+}
@@ -0,0 +1,42 @@
+package test
+
+import (
+	"csvapi-test/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMigrateUpAndDown(t *testing.T) {
+	// Isolated in memory database, so the app database is left untouched
+	db, err := gorm.Open(sqlite.Open("file:migrate_test?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.ErrorIs(t, model.RequireMigrated(db), model.ErrSchemaNotMigrated, "Fresh schema must not be migrated")
+
+	applied, err := model.MigrateUp(db)
+	assert.Nil(t, err)
+	assert.Len(t, applied, len(model.Migrations), "All migrations must be applied")
+	assert.Nil(t, model.RequireMigrated(db))
+	assert.True(t, db.Migrator().HasTable(&model.Ohcl{}))
+
+	applied, err = model.MigrateUp(db)
+	assert.Nil(t, err)
+	assert.Empty(t, applied, "Migrating twice must be a no-op")
+
+	reverted, err := model.MigrateDown(db, len(model.Migrations))
+	assert.Nil(t, err)
+	assert.Len(t, reverted, len(model.Migrations), "All migrations must be reverted")
+	assert.ErrorIs(t, model.RequireMigrated(db), model.ErrSchemaNotMigrated)
+	assert.False(t, db.Migrator().HasTable(&model.Ohcl{}))
+
+	states, err := model.MigrationStatus(db)
+	assert.Nil(t, err)
+	for _, state := range states {
+		assert.Nil(t, state.AppliedAt, "Reverted migration must be pending")
+	}
+}
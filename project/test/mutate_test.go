@@ -0,0 +1,149 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"csvapi-test/model"
+	"csvapi-test/repository"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type CandleResponse struct {
+	Data   model.Ohcl `json:"data"`
+	Status string     `json:"status"`
+}
+
+func TestUpdateCandle(t *testing.T) {
+	appRouter, store := newTestApp(t)
+	seedCandles(t, store, 1)
+
+	body := bytes.NewBufferString(`{"close": 42150.5, "high": 42150.5}`)
+	req, err := http.NewRequest(http.MethodPatch, "/data/1", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+
+	var response CandleResponse
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, http.StatusOK, w.Code, "Status code must be 200")
+	assert.Equal(t, float32(42150.5), response.Data.CLOSE)
+	assert.Equal(t, fields[0].OPEN, response.Data.OPEN, "Omitted fields must keep their value")
+
+	saved, err := store.Get(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, float32(42150.5), saved.CLOSE)
+
+	var entry model.AuditEntry
+	assert.Nil(t, store.DB().Where("action = ?", model.AuditCandleUpdate).First(&entry).Error)
+	assert.Equal(t, "ohcls/1", entry.Target)
+	assert.Contains(t, entry.Before, fmt.Sprintf("%v", fields[0].CLOSE))
+	assert.Contains(t, entry.After, "42150.5")
+}
+
+func TestUpdateCandleValidation(t *testing.T) {
+	appRouter, store := newTestApp(t)
+	seedCandles(t, store, 1)
+
+	req, _ := http.NewRequest(http.MethodPatch, "/data/1", bytes.NewBufferString(`{"symbol": ""}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Status code must be 400")
+	assert.Contains(t, w.Body.String(), "symbol is required")
+
+	// Zero is a valid price, only an inconsistent candle is rejected
+	req, _ = http.NewRequest(http.MethodPatch, "/data/1", bytes.NewBufferString(`{"open": 0, "low": 0}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Status code must be 200")
+
+	req, _ = http.NewRequest(http.MethodPatch, "/data/1", bytes.NewBufferString(`{"low": -1}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Negative prices must be rejected")
+
+	req, _ = http.NewRequest(http.MethodPatch, "/data/999", bytes.NewBufferString(`{"close": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code, "Status code must be 404")
+}
+
+func TestDeleteCandle(t *testing.T) {
+	appRouter, store := newTestApp(t)
+	seedCandles(t, store, 1)
+
+	req, _ := http.NewRequest(http.MethodDelete, "/data/2", nil)
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+
+	var response CandleResponse
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, http.StatusOK, w.Code, "Status code must be 200")
+	assert.Equal(t, fields[1].UNIX, response.Data.UNIX)
+
+	_, err := store.Get(context.Background(), 2)
+	assert.ErrorIs(t, err, repository.ErrCandleNotFound)
+
+	var count int64
+	store.DB().Model(&model.AuditEntry{}).Where("action = ? AND target = ?", model.AuditCandleDelete, "ohcls/2").Count(&count)
+	assert.Equal(t, int64(1), count, "Delete must be audited")
+}
+
+func TestDeleteCandleRange(t *testing.T) {
+	appRouter, store := newTestApp(t)
+	seedCandles(t, store, 2)
+
+	req, _ := http.NewRequest(http.MethodDelete, "/data?symbol=BTCUSDT&from=1644719520000&to=1644719640000", nil)
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+
+	var response struct {
+		Data struct {
+			DeletedRows int64 `json:"deletedRows"`
+		} `json:"data"`
+	}
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, http.StatusOK, w.Code, "Status code must be 200")
+	assert.Equal(t, int64(4), response.Data.DeletedRows)
+
+	total, err := store.Count(context.Background(), repository.CandleQuery{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(6), total)
+
+	// Range without an end must be rejected
+	req, _ = http.NewRequest(http.MethodDelete, "/data?symbol=BTCUSDT&from=1644719520000", nil)
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Status code must be 400")
+	assert.Contains(t, w.Body.String(), "to is required")
+
+	// Range without a start must be rejected, from=0 is a valid start
+	req, _ = http.NewRequest(http.MethodDelete, "/data?symbol=BTCUSDT&to=1644719640000", nil)
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Status code must be 400")
+	assert.Contains(t, w.Body.String(), "from is required")
+
+	req, _ = http.NewRequest(http.MethodDelete, "/data?symbol=BTCUSDT&from=0&to=1644719640000", nil)
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Status code must be 200")
+	total, err = store.Count(context.Background(), repository.CandleQuery{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), total, "The candles before the end must be deleted")
+}
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"context"
+	"csvapi-test/config"
+	"csvapi-test/model"
+	"csvapi-test/repository"
+	"csvapi-test/services"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Runs against a local mysql container when MYSQL_TEST_HOST is set e.g
+// docker run -d -p 3306:3306 -e MYSQL_ROOT_PASSWORD=secret -e MYSQL_DATABASE=ohlc_test mysql:8
+// MYSQL_TEST_HOST=127.0.0.1 MYSQL_TEST_PASSWORD=secret go test ./test -run MySQL
+func TestMySQLStore(t *testing.T) {
+	host := os.Getenv("MYSQL_TEST_HOST")
+	if host == "" {
+		t.Skip("MYSQL_TEST_HOST is not set, skipping mysql integration test")
+	}
+
+	cfg := config.Default()
+	cfg.Database = config.DatabaseConfig{
+		Driver:   "mysql",
+		Host:     host,
+		Port:     envOrDefault("MYSQL_TEST_PORT", "3306"),
+		User:     envOrDefault("MYSQL_TEST_USER", "root"),
+		Password: os.Getenv("MYSQL_TEST_PASSWORD"),
+		Name:     envOrDefault("MYSQL_TEST_DATABASE", "ohlc_test"),
+	}
+	assert.Nil(t, cfg.Validate())
+
+	db, err := model.Connect(cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = model.MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		model.MigrateDown(db, len(model.Migrations))
+	})
+
+	store := repository.NewGormCandleStore(db)
+	seedCandles(t, store, 3)
+
+	ctx := context.Background()
+	total, err := store.Count(ctx, repository.CandleQuery{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3*len(fields)), total)
+
+	expression, err := services.ParseFilter("close>42114;symbol in (BTCUSDT)")
+	assert.Nil(t, err)
+	ohlcs, err := store.Query(ctx, repository.CandleQuery{Filter: expression, Limit: 100})
+	assert.Nil(t, err)
+	assert.Len(t, ohlcs, 3*4)
+
+	ohlcs, err = store.Query(ctx, repository.CandleQuery{Search: "1644719700000", Limit: 100})
+	assert.Nil(t, err)
+	assert.Len(t, ohlcs, 3, "Full text search must match the unix column")
+
+	// Mysql reports no affected rows for an update without changes, unless the found rows are counted
+	before, err := store.Update(ctx, ohlcs[0])
+	assert.Nil(t, err, "An unchanged PATCH must not insert the candle again")
+	assert.Equal(t, ohlcs[0], before)
+	total, err = store.Count(ctx, repository.CandleQuery{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3*len(fields)), total)
+}
+
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,106 @@
+package test
+
+import (
+	"csvapi-test/config"
+	"csvapi-test/openapi"
+	"csvapi-test/services"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Fetch and decode the OpenAPI document of the app
+func fetchSpec(t *testing.T) (openapi.Document, string) {
+	t.Helper()
+	appRouter, _ := newTestApp(t)
+	req, _ := http.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Status code must be 200")
+
+	var doc openapi.Document
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc, w.Body.String()
+}
+
+func TestOpenAPIRouteCoverage(t *testing.T) {
+	appRouter, _ := newTestApp(t)
+	doc, _ := fetchSpec(t)
+
+	routes := appRouter.Routes()
+	for _, route := range routes {
+		assert.True(t, doc.Has(route.Method, route.Path), "%s %s must be in the OpenAPI document", route.Method, route.Path)
+	}
+
+	operations := 0
+	for _, item := range doc.Paths {
+		operations += len(*item)
+	}
+	assert.Equal(t, len(routes), operations, "Every documented operation must be a registered route")
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	doc, body := fetchSpec(t)
+	assert.Equal(t, openapi.Version, doc.OpenAPI)
+
+	// Every reference resolves to a component
+	for _, ref := range regexp.MustCompile(`"\$ref":"#/components/schemas/([^"]+)"`).FindAllStringSubmatch(body, -1) {
+		assert.Contains(t, doc.Components.Schemas, ref[1], "%s must be a component", ref[0])
+	}
+
+	ids := map[string]bool{}
+	for path, item := range doc.Paths {
+		for method, operation := range *item {
+			assert.NotEmpty(t, operation.OperationID, "%s %s must have an operationId", method, path)
+			assert.False(t, ids[operation.OperationID], "%s must be unique", operation.OperationID)
+			ids[operation.OperationID] = true
+			assert.Contains(t, operation.Responses, "500", "%s %s must document server errors", method, path)
+		}
+	}
+
+	list := (*doc.Paths["/data"])["get"]
+	assert.NotEmpty(t, list.Security, "Candle queries require credentials")
+	assert.Contains(t, list.Responses["401"].Description, string(services.CodeUnauthorized))
+	assert.Contains(t, list.Responses["429"].Headers, "Retry-After")
+	assert.Contains(t, list.Responses["400"].Content, services.ProblemContentType)
+	var params []string
+	for _, param := range list.Parameters {
+		params = append(params, param.In+":"+param.Name)
+	}
+	assert.Subset(t, params, []string{"query:search", "query:filter", "query:from", "query:to", "query:page", "query:limit", "query:ptype"})
+
+	upload := (*doc.Paths["/data"])["post"]
+	form := upload.RequestBody.Content["multipart/form-data"].Schema
+	assert.Equal(t, "binary", form.Properties["csv_file"].Format)
+	assert.Equal(t, []string{"csv_file"}, form.Required)
+
+	update := (*doc.Paths["/data/{id}"])["patch"]
+	assert.Equal(t, "path", update.Parameters[0].In)
+	assert.Equal(t, "integer", update.Parameters[0].Schema.Type)
+
+	assert.Empty(t, (*doc.Paths["/healthz"])["get"].Security, "Probes are not authenticated")
+
+	problem := doc.Components.Schemas["Problem"]
+	assert.Len(t, problem.Properties["code"].Enum, len(services.ErrorCodes()), "Problem codes are the error code catalogue")
+	assert.Equal(t, "#/components/schemas/FieldError", problem.Properties["errors"].Items.Ref)
+	assert.Contains(t, doc.Components.Schemas["Pagination"].Properties, "next_page_url")
+	assert.Equal(t, []string{"symbol"}, doc.Components.Schemas["Ohcl"].Required, "Zero is a valid unix and price")
+}
+
+func TestDocsPage(t *testing.T) {
+	appRouter, _ := newTestAppWithConfig(t, config.Default())
+	req, _ := http.NewRequest(http.MethodGet, "/docs", nil)
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code, "Docs are not authenticated")
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/html"))
+	assert.Contains(t, w.Body.String(), `url: "openapi.json"`)
+}
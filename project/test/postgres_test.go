@@ -0,0 +1,98 @@
+package test
+
+import (
+	"context"
+	"csvapi-test/config"
+	"csvapi-test/model"
+	"csvapi-test/repository"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Runs against a local postgres container when POSTGRES_TEST_HOST is set e.g
+// docker run -d -p 5432:5432 -e POSTGRES_PASSWORD=secret -e POSTGRES_DB=ohlc_test postgres
+// POSTGRES_TEST_HOST=127.0.0.1 POSTGRES_TEST_PASSWORD=secret go test ./test -run Postgres
+func TestPostgresPartitions(t *testing.T) {
+	host := os.Getenv("POSTGRES_TEST_HOST")
+	if host == "" {
+		t.Skip("POSTGRES_TEST_HOST is not set, skipping postgres integration test")
+	}
+
+	cfg := config.Default()
+	cfg.Database = config.DatabaseConfig{
+		Driver:   "postgres",
+		Host:     host,
+		Port:     envOrDefault("POSTGRES_TEST_PORT", "5432"),
+		User:     envOrDefault("POSTGRES_TEST_USER", "postgres"),
+		Password: os.Getenv("POSTGRES_TEST_PASSWORD"),
+		Name:     envOrDefault("POSTGRES_TEST_DATABASE", "ohlc_test"),
+	}
+
+	db, err := model.Connect(cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = model.MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		var archived []string
+		db.Raw("SELECT tablename FROM pg_tables WHERE tablename LIKE 'archived_ohcls_2022_02_%'").Scan(&archived)
+		for _, name := range archived {
+			db.Exec("DROP TABLE IF EXISTS " + name)
+		}
+		model.MigrateDown(db, len(model.Migrations))
+	})
+
+	store := repository.NewGormCandleStore(db)
+	seedCandles(t, store, 2)
+
+	partitions, err := model.ListPartitions(db)
+	assert.Nil(t, err)
+	names := []string{}
+	for _, partition := range partitions {
+		names = append(names, partition.Name)
+	}
+	assert.Contains(t, names, "ohcls_2022_02", "Partition of the imported month must be created")
+	assert.Contains(t, names, "ohcls_2022_03", "Partition of the next month must be created ahead")
+
+	ctx := context.Background()
+	total, err := store.Count(ctx, repository.CandleQuery{From: 1644719520000, To: 1644719640000})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), total)
+
+	total, err = store.Count(ctx, repository.CandleQuery{Search: "1644719700000"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), total)
+
+	february := time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)
+	archivedName, err := store.DetachPartition(february)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(archivedName, "archived_ohcls_2022_02_"), archivedName)
+	total, err = store.Count(ctx, repository.CandleQuery{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), total, "Detached partition rows must not be queried")
+
+	// The detached partition is not cached, the next import of the month creates it again
+	seedCandles(t, store, 1)
+	total, err = store.Count(ctx, repository.CandleQuery{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(fields)), total)
+
+	archivedAgain, err := store.DetachPartition(february)
+	assert.Nil(t, err, "The same month can be archived twice")
+	assert.NotEqual(t, archivedName, archivedAgain)
+
+	// Detached by another process e.g the partition cli, the store still has the partition cached
+	seedCandles(t, store, 1)
+	_, err = model.DetachPartition(db, february)
+	assert.Nil(t, err)
+	seedCandles(t, store, 1)
+	total, err = store.Count(ctx, repository.CandleQuery{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(fields)), total, "The insert into the detached month creates the partition again")
+}
@@ -0,0 +1,195 @@
+package test
+
+import (
+	"compress/gzip"
+	"context"
+	"csvapi-test/config"
+	"csvapi-test/model"
+	"csvapi-test/repository"
+	"csvapi-test/retention"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type RetentionRunsResponse struct {
+	Data   []model.RetentionRun `json:"data"`
+	Status string               `json:"status"`
+}
+
+func TestRetentionArchivesOldCandles(t *testing.T) {
+	cfg := config.Default()
+	cfg.Retention = config.RetentionConfig{
+		ArchiveDir: t.TempDir(),
+		WindowRows: 10000,
+		Policies: []config.RetentionPolicy{
+			{Name: "minutes-90d", MaxAge: 90 * 24 * time.Hour, Resolution: 24 * time.Hour, Action: "archive"},
+		},
+	}
+	cfg.Database.Driver = "sqlite"
+	cfg.Auth.Enabled = false
+	assert.Nil(t, cfg.Validate())
+	appRouter, store := newTestAppWithConfig(t, cfg)
+	seedCandles(t, store, 3)
+
+	// Recent candle within the policy max age must be kept
+	candleImport, _ := store.BeginImport(context.Background())
+	recent := model.Ohcl{UNIX: uint64(time.Now().UnixMilli()), SYMBOL: "BTCUSDT", OPEN: 1, HIGH: 1, LOW: 1, CLOSE: 1}
+	assert.Nil(t, candleImport.InsertBatch([]model.Ohcl{recent}))
+	assert.Nil(t, candleImport.Commit(model.ImportSummary{Rows: 1}))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/admin/retention/run", nil)
+	appRouter.ServeHTTP(w, req)
+
+	var response RetentionRunsResponse
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, http.StatusOK, w.Code, "Status code must be 200")
+	if !assert.Len(t, response.Data, 1, "One run for the single symbol") {
+		t.FailNow()
+	}
+	run := response.Data[0]
+	assert.Equal(t, model.RetentionSucceeded, run.Status)
+	assert.Equal(t, int64(15), run.RowsProcessed)
+	assert.Equal(t, int64(15), run.RowsDeleted)
+	assert.Equal(t, int64(1), run.RollupsWritten, "Every sample candle is on the same day")
+
+	total, err := store.Count(context.Background(), repository.CandleQuery{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), total, "Only the recent candle must be left")
+
+	var rollup model.OhclRollup
+	assert.Nil(t, store.DB().First(&rollup).Error)
+	assert.Equal(t, fields[4].OPEN, rollup.OPEN, "Open of the earliest candle")
+	assert.Equal(t, fields[0].CLOSE, rollup.CLOSE, "Close of the latest candle")
+	assert.Equal(t, fields[0].HIGH, rollup.HIGH)
+	assert.Equal(t, fields[3].LOW, rollup.LOW)
+	assert.Equal(t, int64(15), rollup.SourceRows)
+
+	// Archive is a gzip compressed csv in the upload format
+	file, err := os.Open(run.ArchiveFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(gzipReader).ReadAll()
+	assert.Nil(t, err)
+	assert.Len(t, records, 16, "Header and every archived row")
+	assert.Equal(t, csvHeader, records[0])
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/admin/retention", nil)
+	appRouter.ServeHTTP(w, req)
+	response = RetentionRunsResponse{}
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, http.StatusOK, w.Code, "Status code must be 200")
+	assert.Len(t, response.Data, 1, "Run must be logged")
+}
+
+func TestRetentionMergesLateRows(t *testing.T) {
+	cfg := config.Default()
+	cfg.Retention = config.RetentionConfig{
+		WindowRows: 10000,
+		Policies: []config.RetentionPolicy{
+			{Name: "minutes-90d", MaxAge: 90 * 24 * time.Hour, Resolution: 24 * time.Hour, Action: "delete"},
+		},
+	}
+	cfg.Database.Driver = "sqlite"
+	cfg.Auth.Enabled = false
+	assert.Nil(t, cfg.Validate())
+	_, store := newTestAppWithConfig(t, cfg)
+	runner := retention.NewRunner(store.DB(), cfg.Retention)
+
+	// Middle candles are rolled up first, the earliest and latest arrive late in the same bucket
+	insert := func(candles ...OHLC) {
+		rows := make([]model.Ohcl, 0, len(candles))
+		for _, field := range candles {
+			rows = append(rows, model.Ohcl{UNIX: field.UNIX, SYMBOL: field.SYMBOL, OPEN: field.OPEN, HIGH: field.HIGH, LOW: field.LOW, CLOSE: field.CLOSE})
+		}
+		candleImport, _ := store.BeginImport(context.Background())
+		assert.Nil(t, candleImport.InsertBatch(rows))
+		assert.Nil(t, candleImport.Commit(model.ImportSummary{Rows: len(rows)}))
+	}
+	insert(fields[1:4]...)
+	_, err := runner.RunOnce(context.Background())
+	assert.Nil(t, err)
+	insert(fields[0], fields[4])
+	_, err = runner.RunOnce(context.Background())
+	assert.Nil(t, err)
+
+	var rollups []model.OhclRollup
+	assert.Nil(t, store.DB().Find(&rollups).Error)
+	if !assert.Len(t, rollups, 1, "Late rows are merged into the existing rollup") {
+		t.FailNow()
+	}
+	rollup := rollups[0]
+	assert.Equal(t, fields[4].OPEN, rollup.OPEN, "Open of the late earliest candle")
+	assert.Equal(t, fields[0].CLOSE, rollup.CLOSE, "Close of the late latest candle")
+	assert.Equal(t, fields[4].UNIX, rollup.FirstUNIX)
+	assert.Equal(t, fields[0].UNIX, rollup.LastUNIX)
+	assert.Equal(t, fields[0].HIGH, rollup.HIGH)
+	assert.Equal(t, int64(5), rollup.SourceRows)
+}
+
+func TestRetentionWindows(t *testing.T) {
+	// Rollups and run totals of the sample candles, repeated with different closes at the same unix
+	runWithWindow := func(t *testing.T, windowRows int) ([]model.OhclRollup, model.RetentionRun) {
+		cfg := config.Default()
+		cfg.Retention = config.RetentionConfig{
+			WindowRows: windowRows,
+			Policies: []config.RetentionPolicy{
+				{Name: "minutes-90d", MaxAge: 90 * 24 * time.Hour, Resolution: 2 * time.Minute, Action: "delete"},
+			},
+		}
+		cfg.Database.Driver = "sqlite"
+		cfg.Auth.Enabled = false
+		assert.Nil(t, cfg.Validate())
+		_, store := newTestAppWithConfig(t, cfg)
+
+		rows := []model.Ohcl{}
+		for i := 0; i < 3; i++ {
+			for _, field := range fields {
+				rows = append(rows, model.Ohcl{UNIX: field.UNIX, SYMBOL: field.SYMBOL, OPEN: field.OPEN + float32(i), HIGH: field.HIGH + float32(i), LOW: field.LOW - float32(i), CLOSE: field.CLOSE + float32(i)})
+			}
+		}
+		candleImport, _ := store.BeginImport(context.Background())
+		assert.Nil(t, candleImport.InsertBatch(rows))
+		assert.Nil(t, candleImport.Commit(model.ImportSummary{Rows: len(rows)}))
+
+		runs, err := retention.NewRunner(store.DB(), cfg.Retention).RunOnce(context.Background())
+		assert.Nil(t, err)
+		if !assert.Len(t, runs, 1) {
+			t.FailNow()
+		}
+		var rollups []model.OhclRollup
+		assert.Nil(t, store.DB().Order("unix").Find(&rollups).Error)
+		for i := range rollups {
+			rollups[i].ID = 0
+		}
+		total, err := store.Count(context.Background(), repository.CandleQuery{})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), total, "Every candle must be deleted")
+		return rollups, runs[0]
+	}
+
+	var single, windowed []model.OhclRollup
+	var singleRun, windowedRun model.RetentionRun
+	t.Run("single", func(t *testing.T) { single, singleRun = runWithWindow(t, 10000) })
+	t.Run("windowed", func(t *testing.T) { windowed, windowedRun = runWithWindow(t, 2) })
+
+	assert.Len(t, single, 3, "The samples span three 2 minute buckets")
+	assert.Equal(t, single, windowed, "Windows must roll up the same buckets as a single pass")
+	assert.Equal(t, int64(15), windowedRun.RowsProcessed)
+	assert.Equal(t, singleRun.RowsDeleted, windowedRun.RowsDeleted)
+	assert.Equal(t, singleRun.RollupsWritten, windowedRun.RollupsWritten, "A bucket spanning windows is counted once")
+}
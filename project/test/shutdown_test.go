@@ -0,0 +1,146 @@
+package test
+
+import (
+	"context"
+	"csvapi-test/config"
+	"csvapi-test/drain"
+	"csvapi-test/health"
+	"csvapi-test/model"
+	"csvapi-test/repository"
+	"csvapi-test/retention"
+	"csvapi-test/router"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Store whose import batches wait for hold before they are inserted
+type holdingStore struct {
+	repository.CandleStore
+	hold func(ctx context.Context) error
+}
+
+func (store holdingStore) BeginImport(ctx context.Context) (repository.CandleImport, error) {
+	candleImport, err := store.CandleStore.BeginImport(ctx)
+	return holdingImport{CandleImport: candleImport, ctx: ctx, hold: store.hold}, err
+}
+
+type holdingImport struct {
+	repository.CandleImport
+	ctx  context.Context
+	hold func(ctx context.Context) error
+}
+
+func (candleImport holdingImport) InsertBatch(rows []model.Ohcl) error {
+	if err := candleImport.hold(candleImport.ctx); err != nil {
+		return err
+	}
+	return candleImport.CandleImport.InsertBatch(rows)
+}
+
+// App router whose imports are held by hold, with the drain of its imports
+func newHoldingApp(t *testing.T, hold func(ctx context.Context) error) (*gin.Engine, *drain.Drain) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Auth.Enabled = false
+	cfg.Server.MinSpoolFree = 1
+	cfg.Limits.ImportRetryAfter = 20 * time.Second
+	db := newTestDB(t, cfg)
+	runner := retention.NewRunner(db, cfg.Retention)
+	imports := drain.New()
+	appRouter := router.AppInstance(cfg, router.Dependencies{
+		Store:     holdingStore{CandleStore: repository.NewGormCandleStore(db), hold: hold},
+		Audit:     repository.NewGormAuditStore(db),
+		APIKeys:   repository.NewGormAPIKeyStore(db),
+		Retention: runner,
+		Health:    health.NewChecker(db, runner, imports, cfg.Server.MinSpoolFree),
+		Imports:   imports,
+	})
+	return appRouter, imports
+}
+
+// Serve the csv upload in the background, the recorder is complete once done is closed
+func startUpload(t *testing.T, appRouter *gin.Engine, requestID string) (w *httptest.ResponseRecorder, done chan struct{}) {
+	t.Helper()
+	req := csvUploadRequest(t)
+	req.Header.Set("X-Request-ID", requestID)
+	w = httptest.NewRecorder()
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		appRouter.ServeHTTP(w, req)
+	}()
+	return w, done
+}
+
+func TestShutdownWaitsForImports(t *testing.T) {
+	release := make(chan struct{})
+	appRouter, imports := newHoldingApp(t, func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+
+	w, done := startUpload(t, appRouter, "upload-1")
+	assert.Eventually(t, func() bool { return imports.InFlight() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	interrupted := make(chan int)
+	go func() { interrupted <- imports.Shutdown(ctx) }()
+	assert.Eventually(t, imports.Draining, time.Second, 10*time.Millisecond)
+
+	code, response := readiness(t, appRouter)
+	assert.Equal(t, http.StatusServiceUnavailable, code, "Not ready while draining")
+	assert.Equal(t, "shutting down, draining 1 imports", response.Data["shutdown"].Detail)
+
+	upload := httptest.NewRecorder()
+	appRouter.ServeHTTP(upload, csvUploadRequest(t))
+	assert.Equal(t, http.StatusServiceUnavailable, upload.Code, "New uploads must be rejected while draining")
+	assert.Equal(t, "20", upload.Header().Get("Retry-After"))
+
+	close(release)
+	<-done
+	assert.Equal(t, http.StatusCreated, w.Code, "The running import must be committed")
+	assert.Equal(t, 0, <-interrupted, "Nothing is interrupted within the deadline")
+}
+
+func TestShutdownInterruptsImports(t *testing.T) {
+	appRouter, imports := newHoldingApp(t, func(ctx context.Context) error {
+		<-ctx.Done() // held until the drain deadline cancels the import
+		return context.Cause(ctx)
+	})
+
+	w, done := startUpload(t, appRouter, "upload-2")
+	assert.Eventually(t, func() bool { return imports.InFlight() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // deadline already passed
+	assert.Equal(t, 1, imports.Shutdown(ctx))
+	<-done
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "The interrupted import must be retried")
+	assert.Equal(t, "20", w.Header().Get("Retry-After"))
+	assert.Equal(t, 0, imports.InFlight())
+
+	req, _ := http.NewRequest(http.MethodGet, "/data", nil)
+	data := httptest.NewRecorder()
+	appRouter.ServeHTTP(data, req)
+	var candles SimpleResponse
+	assert.Nil(t, json.NewDecoder(data.Body).Decode(&candles))
+	assert.Empty(t, candles.Data, "The interrupted import must be rolled back")
+
+	req, _ = http.NewRequest(http.MethodGet, "/audit?action="+model.AuditCandleImportInterrupted, nil)
+	audit := httptest.NewRecorder()
+	appRouter.ServeHTTP(audit, req)
+	var response AuditResponse
+	assert.Nil(t, json.NewDecoder(audit.Body).Decode(&response))
+	if assert.Len(t, response.Data, 1, "The interrupted import must be audited") {
+		assert.Equal(t, "upload-2", response.Data[0].RequestID)
+		assert.Contains(t, response.Data[0].After, "candles.csv")
+	}
+}
@@ -0,0 +1,74 @@
+package test
+
+import (
+	"context"
+	"csvapi-test/config"
+	"csvapi-test/model"
+	"csvapi-test/repository"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLiteFileMode(t *testing.T) {
+	cfg := config.Default()
+	cfg.Database.Driver = "sqlite"
+	cfg.Database.Path = filepath.Join(t.TempDir(), "ohlc.db")
+	assert.Nil(t, cfg.Validate())
+
+	_, err := model.DbConfig(cfg.Database)
+	assert.ErrorIs(t, err, model.ErrSchemaNotMigrated, "File database must be migrated before use")
+
+	db, err := model.Connect(cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = model.MigrateUp(db)
+	assert.Nil(t, err)
+
+	var journalMode string
+	db.Raw("PRAGMA journal_mode").Scan(&journalMode)
+	assert.Equal(t, "wal", journalMode, "File database must use write ahead log")
+
+	seedCandles(t, repository.NewGormCandleStore(db), 2)
+	sqlDB, _ := db.DB()
+	sqlDB.Close()
+
+	// Reopen the file, data must survive the restart
+	db, err = model.DbConfig(cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}()
+	total, err := repository.NewGormCandleStore(db).Count(context.Background(), repository.CandleQuery{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2*len(fields)), total)
+}
+
+func TestSQLiteFilePathEscaped(t *testing.T) {
+	cfg := config.Default()
+	cfg.Database.Driver = "sqlite"
+	cfg.Database.Path = filepath.Join(t.TempDir(), "ohlc?v=1#50%.db")
+
+	db, err := model.Connect(cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}()
+	_, err = model.MigrateUp(db)
+	assert.Nil(t, err)
+
+	var journalMode string
+	db.Raw("PRAGMA journal_mode").Scan(&journalMode)
+	assert.Equal(t, "wal", journalMode, "Pragmas must be parsed after the path")
+	_, err = os.Stat(cfg.Database.Path)
+	assert.Nil(t, err, "Database must be created at the exact path")
+}
@@ -0,0 +1,128 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"csvapi-test/auth"
+	"csvapi-test/config"
+	"csvapi-test/repository"
+	"csvapi-test/services"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Csv upload request of the sample fields
+func csvUploadRequest(t *testing.T) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("csv_file", "candles.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte("UNIX,SYMBOL,OPEN,HIGH,LOW,CLOSE\n"))
+	for _, field := range fields {
+		fmt.Fprintf(part, "%d,%s,%v,%v,%v,%v\n", field.UNIX, field.SYMBOL, field.OPEN, field.HIGH, field.LOW, field.CLOSE)
+	}
+	writer.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, "/data", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestTenantIsolation(t *testing.T) {
+	appRouter, store := newTestAppWithConfig(t, config.Default())
+	seedTenantCandles(t, store, "acme", 1)
+	seedTenantCandles(t, store, "globex", 2)
+	acmeKey := createTenantAPIKey(t, store, "acme", "acme-reader", auth.ScopeDataRead, auth.ScopeDataWrite)
+	globexKey := createTenantAPIKey(t, store, "globex", "globex-reader", auth.ScopeDataRead, auth.ScopeDataWrite)
+
+	for key, expected := range map[string]int{acmeKey: len(fields), globexKey: 2 * len(fields)} {
+		req, _ := http.NewRequest(http.MethodGet, "/data?limit=100", nil)
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		appRouter.ServeHTTP(w, req)
+
+		var response SimpleResponse
+		assert.Nil(t, json.NewDecoder(w.Body).Decode(&response))
+		assert.Len(t, response.Data, expected, "Only the candles of the key tenant must be returned")
+	}
+
+	// Candle 1 belongs to acme
+	req, _ := http.NewRequest(http.MethodDelete, "/data/1", nil)
+	req.Header.Set("X-API-Key", globexKey)
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code, "Candles of another tenant must not be found")
+
+	req, _ = http.NewRequest(http.MethodDelete, "/data?symbol=BTCUSDT&from=1&to=9999999999999", nil)
+	req.Header.Set("X-API-Key", globexKey)
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Status code must be 200")
+
+	total, err := store.Count(auth.WithTenant(context.Background(), "acme"), repository.CandleQuery{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(fields)), total, "Range delete must keep the candles of other tenants")
+
+	req = csvUploadRequest(t)
+	req.Header.Set("X-API-Key", acmeKey)
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code, "Status code must be 201")
+
+	total, _ = store.Count(auth.WithTenant(context.Background(), "acme"), repository.CandleQuery{})
+	assert.Equal(t, int64(2*len(fields)), total, "Uploads must be saved in the key tenant")
+	total, _ = store.Count(context.Background(), repository.CandleQuery{})
+	assert.Equal(t, int64(0), total, "Default tenant must stay empty")
+}
+
+func TestTenantQuotas(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.Enabled = false
+	cfg.Tenants.DefaultQuota = config.TenantQuota{MaxRows: int64(len(fields)) + 2}
+	cfg.Tenants.Quotas = map[string]config.TenantQuota{"acme": {MaxUploadBytes: 16}}
+	cfg.Database.Driver = "sqlite"
+	assert.Nil(t, cfg.Validate())
+	appRouter, store := newTestAppWithConfig(t, cfg)
+	seedCandles(t, store, 1)
+
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, csvUploadRequest(t))
+	assert.Equal(t, http.StatusForbidden, w.Code, "Import over the row quota must be rejected")
+	assert.Contains(t, w.Body.String(), "row quota exceeded")
+
+	total, _ := store.Count(context.Background(), repository.CandleQuery{})
+	assert.Equal(t, int64(len(fields)), total, "Rejected import must be rolled back")
+
+	// Acme has its own quota without a row limit
+	seedTenantCandles(t, store, "acme", 2)
+	total, _ = store.Count(auth.WithTenant(context.Background(), "acme"), repository.CandleQuery{})
+	assert.Greater(t, total, cfg.Tenants.DefaultQuota.MaxRows, "Acme imports over the default row quota must be saved")
+
+	t.Run("upload size", func(t *testing.T) {
+		cfg.Auth.Enabled = true
+		appRouter, store := newTestAppWithConfig(t, cfg)
+		req := csvUploadRequest(t)
+		req.Header.Set("X-API-Key", createTenantAPIKey(t, store, "acme", "acme-uploader", auth.ScopeDataWrite))
+		w := httptest.NewRecorder()
+		appRouter.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, "Upload over the size quota must be rejected")
+
+		// The body is not read past the quota
+		req = csvFileRequest(t, strings.Repeat("1644719700000,BTCUSDT,1,1,1,1\n", 10000))
+		req.Header.Set("X-API-Key", createTenantAPIKey(t, store, "acme", "acme-large-uploader", auth.ScopeDataWrite))
+		w = httptest.NewRecorder()
+		appRouter.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, "Upload over the size quota must be rejected")
+		assert.Equal(t, services.CodeUploadTooLarge, decodeProblem(t, w).Code)
+	})
+}
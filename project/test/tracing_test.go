@@ -0,0 +1,93 @@
+package test
+
+import (
+	"context"
+	"csvapi-test/config"
+	"csvapi-test/tracing"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Record the spans of the test in memory
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	if _, err := tracing.Setup(context.Background(), config.TracingConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		provider.Shutdown(context.Background())
+	})
+	return exporter
+}
+
+// Value of the span attribute
+func spanAttribute(span tracetest.SpanStub, key string) attribute.Value {
+	for _, attr := range span.Attributes {
+		if string(attr.Key) == key {
+			return attr.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestImportTracing(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.Enabled = false
+	cfg.Import.ChunkSize = 2
+	appRouter, _ := newTestAppWithConfig(t, cfg)
+	exporter := recordSpans(t)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := csvUploadRequest(t)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code, "Status code must be 201")
+
+	spans := map[string][]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		assert.Equal(t, traceID, span.SpanContext.TraceID().String(), "Every span must continue the caller trace")
+		spans[span.Name] = append(spans[span.Name], span)
+	}
+
+	if assert.Len(t, spans["POST /data"], 1, "Server span of the route") {
+		server := spans["POST /data"][0]
+		assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String(), "Server span is a child of the caller span")
+		assert.Equal(t, int64(http.StatusCreated), spanAttribute(server, "http.response.status_code").AsInt64())
+	}
+	if !assert.Len(t, spans["csv.import"], 1) || !assert.Len(t, spans["csv.read"], 1) {
+		t.FailNow()
+	}
+	importSpan := spans["csv.import"][0]
+	assert.Equal(t, spans["POST /data"][0].SpanContext.SpanID(), importSpan.Parent.SpanID())
+	assert.Equal(t, "committed", spanAttribute(importSpan, "csv.result").AsString())
+	assert.Equal(t, int64(len(fields)), spanAttribute(importSpan, "csv.rows_saved").AsInt64())
+	assert.Equal(t, int64(len(fields)), spanAttribute(spans["csv.read"][0], "csv.rows").AsInt64())
+	assert.Equal(t, int64(3), spanAttribute(spans["csv.read"][0], "csv.chunks").AsInt64(), "5 rows in chunks of 2")
+
+	rows := int64(0)
+	for _, chunk := range spans["csv.insert_chunk"] {
+		assert.Equal(t, importSpan.SpanContext.SpanID(), chunk.Parent.SpanID())
+		rows += spanAttribute(chunk, "csv.rows").AsInt64()
+	}
+	assert.Len(t, spans["csv.insert_chunk"], 3, "One span per chunk insert")
+	assert.Equal(t, int64(len(fields)), rows, "Chunk spans must carry their row counts")
+
+	if assert.NotEmpty(t, spans["gorm.create"], "Inserts must be traced") {
+		insert := spans["gorm.create"][0]
+		assert.Equal(t, "sqlite", spanAttribute(insert, "db.system").AsString())
+		assert.Contains(t, spanAttribute(insert, "db.statement").AsString(), "INSERT INTO")
+	}
+}
@@ -0,0 +1,166 @@
+package test
+
+import (
+	"bytes"
+	"csvapi-test/services"
+	"csvapi-test/validation"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tags of validator v10.11.2, with its aliases
+var validatorTags = strings.Fields(`required required_if required_unless required_with required_with_all required_without
+	required_without_all excluded_if excluded_unless excluded_with excluded_with_all excluded_without excluded_without_all
+	isdefault len min max eq ne lt lte gt gte eqfield eqcsfield necsfield gtcsfield gtecsfield ltcsfield ltecsfield nefield
+	gtefield gtfield ltefield ltfield fieldcontains fieldexcludes alpha alphanum alphaunicode alphanumunicode boolean numeric
+	number hexadecimal hexcolor rgb rgba hsl hsla e164 email url uri urn_rfc2141 file base64 base64url contains containsany
+	containsrune excludes excludesall excludesrune startswith endswith startsnotwith endsnotwith isbn isbn10 isbn13 eth_addr
+	btc_addr btc_addr_bech32 uuid uuid3 uuid4 uuid5 uuid_rfc4122 uuid3_rfc4122 uuid4_rfc4122 uuid5_rfc4122 ulid md4 md5
+	sha256 sha384 sha512 ripemd128 ripemd160 tiger128 tiger160 tiger192 ascii printascii multibyte datauri latitude longitude
+	ssn ipv4 ipv6 ip cidrv4 cidrv6 cidr tcp4_addr tcp6_addr tcp_addr udp4_addr udp6_addr udp_addr ip4_addr ip6_addr ip_addr
+	unix_addr mac hostname hostname_rfc1123 fqdn unique oneof html html_encoded url_encoded dir json jwt hostname_port
+	lowercase uppercase datetime timezone iso3166_1_alpha2 iso3166_1_alpha3 iso3166_1_alpha_numeric iso3166_2 iso4217
+	iso4217_numeric bcp47_language_tag postcode_iso3166_alpha2 postcode_iso3166_alpha2_field bic semver dns_rfc1035_label
+	credit_card iscolor country_code`)
+
+func TestValidationMessageCoverage(t *testing.T) {
+	assert.Equal(t, []string{"en", "fr"}, validation.Locales())
+	tags := append(validatorTags, validation.TagSymbol, validation.TagOHLCConsistent)
+	for _, locale := range validation.Locales() {
+		for _, tag := range tags {
+			assert.True(t, validation.Translates(locale, tag), "%s must have a %s message", tag, locale)
+		}
+	}
+}
+
+func TestValidationLocale(t *testing.T) {
+	for header, locale := range map[string]string{
+		"":                        "en",
+		"fr-FR,fr;q=0.9,en;q=0.8": "fr",
+		"de-DE,en;q=0.5,fr;q=0.7": "fr",
+		"de":                      "en",
+		"fr;q=0,en-GB;q=0.3":      "en",
+		"FR-ca":                   "fr",
+		"en-US;q=0.8,fr;q=0.95":   "fr",
+	} {
+		assert.Equal(t, locale, validation.Locale(header), "Accept-Language: %q", header)
+	}
+}
+
+func TestValidationMessagesTranslated(t *testing.T) {
+	appRouter, store := newTestApp(t)
+	seedCandles(t, store, 1)
+
+	req, _ := http.NewRequest(http.MethodPatch, "/data/1", bytes.NewBufferString(`{"symbol": ""}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "fr-FR,fr;q=0.9,en;q=0.8")
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Status code must be 400")
+	assert.Equal(t, "fr", w.Header().Get("Content-Language"))
+	problem := decodeProblem(t, w)
+	assert.Equal(t, []services.FieldError{{Field: "symbol", Rule: "required", Message: "symbol est obligatoire"}}, problem.Errors)
+}
+
+func TestCustomValidationTags(t *testing.T) {
+	appRouter, store := newTestApp(t)
+	seedCandles(t, store, 1)
+
+	for _, test := range []struct {
+		name, body string
+		errors     []services.FieldError
+	}{
+		{"lowercase symbol", `{"symbol": "btcusdt"}`, []services.FieldError{
+			{Field: "symbol", Rule: validation.TagSymbol, Message: "symbol must be an uppercase trading symbol such as BTCUSDT"},
+		}},
+		{"close above high", `{"close": 50000}`, []services.FieldError{
+			{Field: "high", Rule: validation.TagOHLCConsistent, Message: "high is inconsistent with the other prices, high must be the highest price and low the lowest"},
+		}},
+		{"low above high", `{"low": 42200, "high": 42100}`, []services.FieldError{
+			{Field: "high", Rule: validation.TagOHLCConsistent, Message: "high is inconsistent with the other prices, high must be the highest price and low the lowest"},
+			{Field: "low", Rule: validation.TagOHLCConsistent, Message: "low is inconsistent with the other prices, high must be the highest price and low the lowest"},
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPatch, "/data/1", bytes.NewBufferString(test.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			appRouter.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, "Status code must be 400")
+			problem := decodeProblem(t, w)
+			assert.Equal(t, services.CodeValidationFailed, problem.Code)
+			assert.ElementsMatch(t, test.errors, problem.Errors)
+		})
+	}
+}
+
+func TestImportRowValidation(t *testing.T) {
+	appRouter, _ := newTestApp(t)
+
+	// Only the updates are checked against the candle rules, imported rows only have to parse
+	req := csvFileRequest(t, "UNIX,SYMBOL,OPEN,HIGH,LOW,CLOSE\n1644719700000,btcusdt,2,3,1,2\n1644719640000,BTCUSDT,2,1,3,2\n")
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code, "Status code must be 201")
+
+	for _, test := range []struct {
+		language, content, detail string
+	}{
+		{"", "1644719700000,BTCUSDT,2,high,1,2\n", `line 2: HIGH must be a number, got "high"`},
+		{"fr", "1644719700000,BTCUSDT,2,high,1,2\n", `line 2: HIGH doit être un nombre, reçu "high"`},
+		{"fr", "yesterday,BTCUSDT,2,3,1,2\n", `line 2: UNIX doit être un horodatage unix en millisecondes, reçu "yesterday"`},
+	} {
+		req := csvFileRequest(t, "UNIX,SYMBOL,OPEN,HIGH,LOW,CLOSE\n"+test.content)
+		req.Header.Set("Accept-Language", test.language)
+		w := httptest.NewRecorder()
+		appRouter.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, "Status code must be 400")
+		problem := decodeProblem(t, w)
+		assert.Equal(t, services.CodeRowParseError, problem.Code)
+		assert.Contains(t, problem.Detail, test.detail)
+	}
+}
+
+func TestQueryValidation(t *testing.T) {
+	appRouter, _ := newTestApp(t)
+
+	for _, test := range []struct {
+		name, path string
+		code       services.ErrorCode
+		errors     []services.FieldError
+	}{
+		{"reversed range", "/data?from=10&to=5", services.CodeValidationFailed, []services.FieldError{
+			{Field: "to", Rule: "gtfield", Message: "to must be greater than from"},
+		}},
+		{"invalid number", "/data?from=yesterday", services.CodeInvalidParameter, []services.FieldError{
+			{Field: "from", Rule: "number", Message: "from must be a number"},
+		}},
+		{"invalid number sharing its value", "/data?search=5x&from=5x&to=5x", services.CodeInvalidParameter, []services.FieldError{
+			{Field: "from", Rule: "number", Message: "from must be a number"},
+		}},
+		{"invalid time", "/audit?until=tomorrow", services.CodeInvalidParameter, []services.FieldError{
+			{Field: "until", Rule: "time", Message: "until must be an RFC 3339 time"},
+		}},
+		{"reversed time range", "/audit?since=2023-04-02T00:00:00Z&until=2023-04-01T00:00:00Z", services.CodeValidationFailed, []services.FieldError{
+			{Field: "until", Rule: "gtfield", Message: "until must be greater than since"},
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, test.path, nil)
+			w := httptest.NewRecorder()
+			appRouter.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, "Status code must be 400")
+			problem := decodeProblem(t, w)
+			assert.Equal(t, test.code, problem.Code)
+			assert.Equal(t, test.errors, problem.Errors)
+		})
+	}
+}
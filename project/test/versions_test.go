@@ -0,0 +1,123 @@
+package test
+
+import (
+	"csvapi-test/auth"
+	"csvapi-test/config"
+	"csvapi-test/services"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionedRoutes(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.Enabled = false
+	cfg.API.Sunset = time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+	appRouter, store := newTestAppWithConfig(t, cfg)
+	seedCandles(t, store, 1)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		appRouter.ServeHTTP(w, req)
+		return w
+	}
+
+	current := get("/v1/data?limit=2")
+	assert.Equal(t, http.StatusOK, current.Code, "Status code must be 200")
+	assert.Empty(t, current.Header().Get("Deprecation"), "Versioned routes are not deprecated")
+
+	legacy := get("/data?limit=2")
+	assert.Equal(t, http.StatusOK, legacy.Code, "Status code must be 200")
+	assert.JSONEq(t, current.Body.String(), legacy.Body.String(), "Unversioned paths are aliases of v1")
+	assert.Equal(t, "@"+strconv.FormatInt(cfg.API.DeprecatedAt.Unix(), 10), legacy.Header().Get("Deprecation"))
+	assert.Equal(t, "Fri, 30 Apr 2027 00:00:00 GMT", legacy.Header().Get("Sunset"))
+	assert.Equal(t, `</v1/data>; rel="successor-version"`, legacy.Header().Get("Link"))
+
+	var response CandleResponse
+	req, _ := http.NewRequest(http.MethodDelete, "/v1/data/1", nil)
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Status code must be 200")
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, uint64(1), response.Data.ID)
+}
+
+func TestLegacyRoutesDisabled(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.Enabled = false
+	cfg.API.LegacyRoutes = false
+	appRouter, _ := newTestAppWithConfig(t, cfg)
+
+	req, _ := http.NewRequest(http.MethodGet, "/data", nil)
+	w := httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code, "Status code must be 404")
+	assert.Equal(t, services.CodeRouteNotFound, decodeProblem(t, w).Code)
+
+	req, _ = http.NewRequest(http.MethodGet, "/v1/data", nil)
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Status code must be 200")
+
+	req, _ = http.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w = httptest.NewRecorder()
+	appRouter.ServeHTTP(w, req)
+	assert.NotContains(t, w.Body.String(), `"/data"`, "Disabled aliases are not documented")
+}
+
+func TestVersionsShareLimits(t *testing.T) {
+	cfg := config.Default()
+	cfg.Limits.QueryRate = 0.5
+	cfg.Limits.QueryBurst = 1
+	appRouter, store := newTestAppWithConfig(t, cfg)
+	key := createAPIKey(t, store, "reader", auth.ScopeDataRead)
+
+	query := func(path string) int {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		appRouter.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, query("/data"))
+	assert.Equal(t, http.StatusTooManyRequests, query("/v1/data"), "Aliases and versioned routes share the client bucket")
+}
+
+func TestOpenAPIVersions(t *testing.T) {
+	doc, _ := fetchSpec(t)
+
+	current := (*doc.Paths["/v1/data"])["get"]
+	assert.False(t, current.Deprecated)
+	assert.Equal(t, "v1ListCandles", current.OperationID)
+
+	legacy := (*doc.Paths["/data"])["get"]
+	assert.True(t, legacy.Deprecated, "Unversioned paths are documented as deprecated")
+	assert.Contains(t, legacy.Description, "/v1/data")
+}
+
+func TestAPIConfig(t *testing.T) {
+	t.Setenv("API_SUNSET", "2027-04-30")
+	t.Setenv("API_LEGACY_ROUTES", "false")
+	cfg, _, err := config.Load([]string{"-db-driver", "sqlite"})
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC), cfg.API.Sunset)
+	assert.False(t, cfg.API.LegacyRoutes)
+
+	cfg = config.Default()
+	cfg.Database.Driver = "sqlite"
+	cfg.API.Sunset = cfg.API.DeprecatedAt.Add(-time.Hour)
+	err = cfg.Validate()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "api.sunset")
+
+	t.Setenv("API_SUNSET", "soon")
+	_, _, err = config.Load([]string{"-db-driver", "sqlite"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "API_SUNSET")
+}
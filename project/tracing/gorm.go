@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanInstanceKey = "tracing:span"
+
+// Gorm plugin starting a client span for every statement, child of the span in the statement context
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+// Register the span callbacks around the gorm callback of each operation
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("tracing:before_create", startStatement("gorm.create")),
+		callbacks.Create().After("gorm:create").Register("tracing:after_create", endStatement),
+		callbacks.Query().Before("gorm:query").Register("tracing:before_query", startStatement("gorm.query")),
+		callbacks.Query().After("gorm:query").Register("tracing:after_query", endStatement),
+		callbacks.Update().Before("gorm:update").Register("tracing:before_update", startStatement("gorm.update")),
+		callbacks.Update().After("gorm:update").Register("tracing:after_update", endStatement),
+		callbacks.Delete().Before("gorm:delete").Register("tracing:before_delete", startStatement("gorm.delete")),
+		callbacks.Delete().After("gorm:delete").Register("tracing:after_delete", endStatement),
+		callbacks.Row().Before("gorm:row").Register("tracing:before_row", startStatement("gorm.row")),
+		callbacks.Row().After("gorm:row").Register("tracing:after_row", endStatement),
+		callbacks.Raw().Before("gorm:raw").Register("tracing:before_raw", startStatement("gorm.raw")),
+		callbacks.Raw().After("gorm:raw").Register("tracing:after_raw", endStatement),
+	)
+}
+
+func startStatement(name string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Statement.Context == nil {
+			return
+		}
+		_, span := Tracer().Start(db.Statement.Context, name, trace.WithSpanKind(trace.SpanKindClient))
+		db.InstanceSet(spanInstanceKey, span)
+	}
+}
+
+func endStatement(db *gorm.DB) {
+	value, found := db.InstanceGet(spanInstanceKey)
+	if !found {
+		return
+	}
+	span := value.(trace.Span)
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("db.system", db.Dialector.Name()),
+		attribute.String("db.statement", db.Statement.SQL.String()),
+		attribute.String("db.sql.table", db.Statement.Table),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	if !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		Fail(span, db.Error)
+	}
+}
@@ -0,0 +1,195 @@
+package validation
+
+var english = Bundle{
+	Messages: map[string]string{
+		"fallback":   "{field} failed the {tag} validation",
+		"format":     "{field} must be a valid {format}",
+		"param.pair": "{name} is {value}",
+		"param.and":  " and ",
+
+		// Query parameters that could not be decoded, by kind of value
+		"decode.time":   "{field} must be an RFC 3339 time",
+		"decode.number": "{field} must be a number",
+
+		// Csv rows that could not be parsed, by kind of error
+		"row.columns": "expected {value} columns",
+		"row.unix":    "{field} must be a unix timestamp in milliseconds, got {value}",
+		"row.number":  "{field} must be a number, got {value}",
+
+		"required":             "{field} is required",
+		"required_if":          "{field} is required when {param}",
+		"required_unless":      "{field} is required unless {param}",
+		"required_with":        "{field} is required when {param} is present",
+		"required_with_all":    "{field} is required when {param} are present",
+		"required_without":     "{field} is required when {param} is missing",
+		"required_without_all": "{field} is required when {param} are missing",
+		"excluded_if":          "{field} must be empty when {param}",
+		"excluded_unless":      "{field} must be empty unless {param}",
+		"excluded_with":        "{field} must be empty when {param} is present",
+		"excluded_with_all":    "{field} must be empty when {param} are present",
+		"excluded_without":     "{field} must be empty when {param} is missing",
+		"excluded_without_all": "{field} must be empty when {param} are missing",
+		"isdefault":            "{field} must be empty",
+
+		"len.string": "{field} must be {param} characters long",
+		"len.items":  "{field} must contain {param} items",
+		"len.number": "{field} must be equal to {param}",
+		"min.string": "{field} must be at least {param} characters long",
+		"min.items":  "{field} must contain at least {param} items",
+		"min.number": "{field} must be {param} or greater",
+		"max.string": "{field} must be at most {param} characters long",
+		"max.items":  "{field} must contain at most {param} items",
+		"max.number": "{field} must be {param} or less",
+		"eq":         "{field} must be equal to {param}",
+		"ne":         "{field} must not be equal to {param}",
+		"lt.string":  "{field} must be shorter than {param} characters",
+		"lt.items":   "{field} must contain fewer than {param} items",
+		"lt.time":    "{field} must be before the current time",
+		"lt.number":  "{field} must be less than {param}",
+		"lte.string": "{field} must be at most {param} characters long",
+		"lte.items":  "{field} must contain at most {param} items",
+		"lte.time":   "{field} must be before or at the current time",
+		"lte.number": "{field} must be less than or equal to {param}",
+		"gt.string":  "{field} must be longer than {param} characters",
+		"gt.items":   "{field} must contain more than {param} items",
+		"gt.time":    "{field} must be after the current time",
+		"gt.number":  "{field} must be greater than {param}",
+		"gte.string": "{field} must be at least {param} characters long",
+		"gte.items":  "{field} must contain at least {param} items",
+		"gte.time":   "{field} must be after or at the current time",
+		"gte.number": "{field} must be greater than or equal to {param}",
+
+		"eqfield":       "{field} must be equal to {param}",
+		"eqcsfield":     "{field} must be equal to {param}",
+		"nefield":       "{field} must not be equal to {param}",
+		"necsfield":     "{field} must not be equal to {param}",
+		"gtfield":       "{field} must be greater than {param}",
+		"gtcsfield":     "{field} must be greater than {param}",
+		"gtefield":      "{field} must be greater than or equal to {param}",
+		"gtecsfield":    "{field} must be greater than or equal to {param}",
+		"ltfield":       "{field} must be less than {param}",
+		"ltcsfield":     "{field} must be less than {param}",
+		"ltefield":      "{field} must be less than or equal to {param}",
+		"ltecsfield":    "{field} must be less than or equal to {param}",
+		"fieldcontains": "{field} must contain the value of {param}",
+		"fieldexcludes": "{field} must not contain the value of {param}",
+
+		"contains":      `{field} must contain "{param}"`,
+		"containsany":   `{field} must contain at least one of the characters "{param}"`,
+		"containsrune":  `{field} must contain "{param}"`,
+		"excludes":      `{field} must not contain "{param}"`,
+		"excludesall":   `{field} must not contain any of the characters "{param}"`,
+		"excludesrune":  `{field} must not contain "{param}"`,
+		"startswith":    `{field} must start with "{param}"`,
+		"endswith":      `{field} must end with "{param}"`,
+		"startsnotwith": `{field} must not start with "{param}"`,
+		"endsnotwith":   `{field} must not end with "{param}"`,
+
+		"oneof":           "{field} must be one of {param}",
+		"unique":          "{field} must only contain unique values",
+		"alpha":           "{field} must only contain letters",
+		"alphanum":        "{field} must only contain letters and digits",
+		"alphaunicode":    "{field} must only contain unicode letters",
+		"alphanumunicode": "{field} must only contain unicode letters and digits",
+		"ascii":           "{field} must only contain ascii characters",
+		"printascii":      "{field} must only contain printable ascii characters",
+		"multibyte":       "{field} must contain multibyte characters",
+		"lowercase":       "{field} must be lowercase",
+		"uppercase":       "{field} must be uppercase",
+		"boolean":         "{field} must be a boolean",
+		"numeric":         "{field} must be a numeric value",
+		"number":          "{field} must be a number",
+		"datetime":        "{field} must be a time in the {param} format",
+		"file":            "{field} must be an existing file",
+		"dir":             "{field} must be an existing directory",
+
+		TagSymbol:         "{field} must be an uppercase trading symbol such as BTCUSDT",
+		TagOHLCConsistent: "{field} is inconsistent with the other prices, high must be the highest price and low the lowest",
+	},
+	Formats: map[string]string{
+		"hexadecimal":                   "hexadecimal string",
+		"hexcolor":                      "hex color",
+		"rgb":                           "RGB color",
+		"rgba":                          "RGBA color",
+		"hsl":                           "HSL color",
+		"hsla":                          "HSLA color",
+		"iscolor":                       "color",
+		"e164":                          "E.164 phone number",
+		"email":                         "email address",
+		"url":                           "URL",
+		"uri":                           "URI",
+		"urn_rfc2141":                   "URN",
+		"base64":                        "base64 string",
+		"base64url":                     "base64url string",
+		"isbn":                          "ISBN",
+		"isbn10":                        "ISBN-10",
+		"isbn13":                        "ISBN-13",
+		"eth_addr":                      "Ethereum address",
+		"btc_addr":                      "Bitcoin address",
+		"btc_addr_bech32":               "Bech32 Bitcoin address",
+		"uuid":                          "UUID",
+		"uuid3":                         "UUID v3",
+		"uuid4":                         "UUID v4",
+		"uuid5":                         "UUID v5",
+		"uuid_rfc4122":                  "RFC 4122 UUID",
+		"uuid3_rfc4122":                 "RFC 4122 UUID v3",
+		"uuid4_rfc4122":                 "RFC 4122 UUID v4",
+		"uuid5_rfc4122":                 "RFC 4122 UUID v5",
+		"ulid":                          "ULID",
+		"md4":                           "MD4 hash",
+		"md5":                           "MD5 hash",
+		"sha256":                        "SHA-256 hash",
+		"sha384":                        "SHA-384 hash",
+		"sha512":                        "SHA-512 hash",
+		"ripemd128":                     "RIPEMD-128 hash",
+		"ripemd160":                     "RIPEMD-160 hash",
+		"tiger128":                      "Tiger-128 hash",
+		"tiger160":                      "Tiger-160 hash",
+		"tiger192":                      "Tiger-192 hash",
+		"datauri":                       "data URI",
+		"latitude":                      "latitude",
+		"longitude":                     "longitude",
+		"ssn":                           "social security number",
+		"ipv4":                          "IPv4 address",
+		"ipv6":                          "IPv6 address",
+		"ip":                            "IP address",
+		"cidrv4":                        "IPv4 CIDR",
+		"cidrv6":                        "IPv6 CIDR",
+		"cidr":                          "CIDR",
+		"tcp4_addr":                     "TCP IPv4 address",
+		"tcp6_addr":                     "TCP IPv6 address",
+		"tcp_addr":                      "TCP address",
+		"udp4_addr":                     "UDP IPv4 address",
+		"udp6_addr":                     "UDP IPv6 address",
+		"udp_addr":                      "UDP address",
+		"ip4_addr":                      "IPv4 address",
+		"ip6_addr":                      "IPv6 address",
+		"ip_addr":                       "IP address",
+		"unix_addr":                     "unix socket address",
+		"mac":                           "MAC address",
+		"hostname":                      "hostname",
+		"hostname_rfc1123":              "RFC 1123 hostname",
+		"hostname_port":                 "host and port",
+		"fqdn":                          "fully qualified domain name",
+		"html":                          "HTML",
+		"html_encoded":                  "HTML encoded string",
+		"url_encoded":                   "URL encoded string",
+		"json":                          "JSON",
+		"jwt":                           "JWT",
+		"timezone":                      "time zone",
+		"country_code":                  "country code",
+		"iso3166_1_alpha2":              "ISO 3166-1 alpha-2 country code",
+		"iso3166_1_alpha3":              "ISO 3166-1 alpha-3 country code",
+		"iso3166_1_alpha_numeric":       "ISO 3166-1 numeric country code",
+		"iso3166_2":                     "ISO 3166-2 subdivision code",
+		"iso4217":                       "ISO 4217 currency code",
+		"iso4217_numeric":               "ISO 4217 numeric currency code",
+		"bcp47_language_tag":            "BCP 47 language tag",
+		"postcode_iso3166_alpha2":       "postcode",
+		"postcode_iso3166_alpha2_field": "postcode",
+		"bic":                           "BIC",
+		"semver":                        "semantic version",
+		"dns_rfc1035_label":             "RFC 1035 DNS label",
+		"credit_card":                   "credit card number",
+	},
+}
@@ -0,0 +1,193 @@
+package validation
+
+var french = Bundle{
+	Messages: map[string]string{
+		"fallback":   "{field} ne respecte pas la règle {tag}",
+		"format":     "{field} doit être une valeur valide de type {format}",
+		"param.pair": "{name} vaut {value}",
+		"param.and":  " et ",
+
+		"decode.time":   "{field} doit être une date RFC 3339",
+		"decode.number": "{field} doit être un nombre",
+
+		"row.columns": "{value} colonnes attendues",
+		"row.unix":    "{field} doit être un horodatage unix en millisecondes, reçu {value}",
+		"row.number":  "{field} doit être un nombre, reçu {value}",
+
+		"required":             "{field} est obligatoire",
+		"required_if":          "{field} est obligatoire lorsque {param}",
+		"required_unless":      "{field} est obligatoire sauf si {param}",
+		"required_with":        "{field} est obligatoire lorsque {param} est présent",
+		"required_with_all":    "{field} est obligatoire lorsque {param} sont présents",
+		"required_without":     "{field} est obligatoire lorsque {param} est absent",
+		"required_without_all": "{field} est obligatoire lorsque {param} sont absents",
+		"excluded_if":          "{field} doit être vide lorsque {param}",
+		"excluded_unless":      "{field} doit être vide sauf si {param}",
+		"excluded_with":        "{field} doit être vide lorsque {param} est présent",
+		"excluded_with_all":    "{field} doit être vide lorsque {param} sont présents",
+		"excluded_without":     "{field} doit être vide lorsque {param} est absent",
+		"excluded_without_all": "{field} doit être vide lorsque {param} sont absents",
+		"isdefault":            "{field} doit être vide",
+
+		"len.string": "{field} doit contenir {param} caractères",
+		"len.items":  "{field} doit contenir {param} éléments",
+		"len.number": "{field} doit être égal à {param}",
+		"min.string": "{field} doit contenir au moins {param} caractères",
+		"min.items":  "{field} doit contenir au moins {param} éléments",
+		"min.number": "{field} doit être supérieur ou égal à {param}",
+		"max.string": "{field} doit contenir au plus {param} caractères",
+		"max.items":  "{field} doit contenir au plus {param} éléments",
+		"max.number": "{field} doit être inférieur ou égal à {param}",
+		"eq":         "{field} doit être égal à {param}",
+		"ne":         "{field} doit être différent de {param}",
+		"lt.string":  "{field} doit contenir moins de {param} caractères",
+		"lt.items":   "{field} doit contenir moins de {param} éléments",
+		"lt.time":    "{field} doit être antérieur à l'heure actuelle",
+		"lt.number":  "{field} doit être inférieur à {param}",
+		"lte.string": "{field} doit contenir au plus {param} caractères",
+		"lte.items":  "{field} doit contenir au plus {param} éléments",
+		"lte.time":   "{field} doit être antérieur ou égal à l'heure actuelle",
+		"lte.number": "{field} doit être inférieur ou égal à {param}",
+		"gt.string":  "{field} doit contenir plus de {param} caractères",
+		"gt.items":   "{field} doit contenir plus de {param} éléments",
+		"gt.time":    "{field} doit être postérieur à l'heure actuelle",
+		"gt.number":  "{field} doit être supérieur à {param}",
+		"gte.string": "{field} doit contenir au moins {param} caractères",
+		"gte.items":  "{field} doit contenir au moins {param} éléments",
+		"gte.time":   "{field} doit être postérieur ou égal à l'heure actuelle",
+		"gte.number": "{field} doit être supérieur ou égal à {param}",
+
+		"eqfield":       "{field} doit être égal à {param}",
+		"eqcsfield":     "{field} doit être égal à {param}",
+		"nefield":       "{field} doit être différent de {param}",
+		"necsfield":     "{field} doit être différent de {param}",
+		"gtfield":       "{field} doit être supérieur à {param}",
+		"gtcsfield":     "{field} doit être supérieur à {param}",
+		"gtefield":      "{field} doit être supérieur ou égal à {param}",
+		"gtecsfield":    "{field} doit être supérieur ou égal à {param}",
+		"ltfield":       "{field} doit être inférieur à {param}",
+		"ltcsfield":     "{field} doit être inférieur à {param}",
+		"ltefield":      "{field} doit être inférieur ou égal à {param}",
+		"ltecsfield":    "{field} doit être inférieur ou égal à {param}",
+		"fieldcontains": "{field} doit contenir la valeur de {param}",
+		"fieldexcludes": "{field} ne doit pas contenir la valeur de {param}",
+
+		"contains":      `{field} doit contenir « {param} »`,
+		"containsany":   `{field} doit contenir au moins un des caractères « {param} »`,
+		"containsrune":  `{field} doit contenir « {param} »`,
+		"excludes":      `{field} ne doit pas contenir « {param} »`,
+		"excludesall":   `{field} ne doit contenir aucun des caractères « {param} »`,
+		"excludesrune":  `{field} ne doit pas contenir « {param} »`,
+		"startswith":    `{field} doit commencer par « {param} »`,
+		"endswith":      `{field} doit se terminer par « {param} »`,
+		"startsnotwith": `{field} ne doit pas commencer par « {param} »`,
+		"endsnotwith":   `{field} ne doit pas se terminer par « {param} »`,
+
+		"oneof":           "{field} doit être l'une des valeurs {param}",
+		"unique":          "{field} ne doit contenir que des valeurs uniques",
+		"alpha":           "{field} ne doit contenir que des lettres",
+		"alphanum":        "{field} ne doit contenir que des lettres et des chiffres",
+		"alphaunicode":    "{field} ne doit contenir que des lettres unicode",
+		"alphanumunicode": "{field} ne doit contenir que des lettres unicode et des chiffres",
+		"ascii":           "{field} ne doit contenir que des caractères ascii",
+		"printascii":      "{field} ne doit contenir que des caractères ascii imprimables",
+		"multibyte":       "{field} doit contenir des caractères multi-octets",
+		"lowercase":       "{field} doit être en minuscules",
+		"uppercase":       "{field} doit être en majuscules",
+		"boolean":         "{field} doit être un booléen",
+		"numeric":         "{field} doit être une valeur numérique",
+		"number":          "{field} doit être un nombre",
+		"datetime":        "{field} doit être une date au format {param}",
+		"file":            "{field} doit être un fichier existant",
+		"dir":             "{field} doit être un répertoire existant",
+
+		TagSymbol:         "{field} doit être un symbole de marché en majuscules, par exemple BTCUSDT",
+		TagOHLCConsistent: "{field} est incohérent avec les autres prix, high doit être le prix le plus haut et low le plus bas",
+	},
+	Formats: map[string]string{
+		"hexadecimal":                   "chaîne hexadécimale",
+		"hexcolor":                      "couleur hexadécimale",
+		"rgb":                           "couleur RGB",
+		"rgba":                          "couleur RGBA",
+		"hsl":                           "couleur HSL",
+		"hsla":                          "couleur HSLA",
+		"iscolor":                       "couleur",
+		"e164":                          "numéro de téléphone E.164",
+		"email":                         "adresse e-mail",
+		"url":                           "URL",
+		"uri":                           "URI",
+		"urn_rfc2141":                   "URN",
+		"base64":                        "chaîne base64",
+		"base64url":                     "chaîne base64url",
+		"isbn":                          "ISBN",
+		"isbn10":                        "ISBN-10",
+		"isbn13":                        "ISBN-13",
+		"eth_addr":                      "adresse Ethereum",
+		"btc_addr":                      "adresse Bitcoin",
+		"btc_addr_bech32":               "adresse Bitcoin Bech32",
+		"uuid":                          "UUID",
+		"uuid3":                         "UUID v3",
+		"uuid4":                         "UUID v4",
+		"uuid5":                         "UUID v5",
+		"uuid_rfc4122":                  "UUID RFC 4122",
+		"uuid3_rfc4122":                 "UUID v3 RFC 4122",
+		"uuid4_rfc4122":                 "UUID v4 RFC 4122",
+		"uuid5_rfc4122":                 "UUID v5 RFC 4122",
+		"ulid":                          "ULID",
+		"md4":                           "empreinte MD4",
+		"md5":                           "empreinte MD5",
+		"sha256":                        "empreinte SHA-256",
+		"sha384":                        "empreinte SHA-384",
+		"sha512":                        "empreinte SHA-512",
+		"ripemd128":                     "empreinte RIPEMD-128",
+		"ripemd160":                     "empreinte RIPEMD-160",
+		"tiger128":                      "empreinte Tiger-128",
+		"tiger160":                      "empreinte Tiger-160",
+		"tiger192":                      "empreinte Tiger-192",
+		"datauri":                       "URI de données",
+		"latitude":                      "latitude",
+		"longitude":                     "longitude",
+		"ssn":                           "numéro de sécurité sociale",
+		"ipv4":                          "adresse IPv4",
+		"ipv6":                          "adresse IPv6",
+		"ip":                            "adresse IP",
+		"cidrv4":                        "CIDR IPv4",
+		"cidrv6":                        "CIDR IPv6",
+		"cidr":                          "CIDR",
+		"tcp4_addr":                     "adresse TCP IPv4",
+		"tcp6_addr":                     "adresse TCP IPv6",
+		"tcp_addr":                      "adresse TCP",
+		"udp4_addr":                     "adresse UDP IPv4",
+		"udp6_addr":                     "adresse UDP IPv6",
+		"udp_addr":                      "adresse UDP",
+		"ip4_addr":                      "adresse IPv4",
+		"ip6_addr":                      "adresse IPv6",
+		"ip_addr":                       "adresse IP",
+		"unix_addr":                     "adresse de socket unix",
+		"mac":                           "adresse MAC",
+		"hostname":                      "nom d'hôte",
+		"hostname_rfc1123":              "nom d'hôte RFC 1123",
+		"hostname_port":                 "hôte et port",
+		"fqdn":                          "nom de domaine complet",
+		"html":                          "HTML",
+		"html_encoded":                  "chaîne encodée en HTML",
+		"url_encoded":                   "chaîne encodée en URL",
+		"json":                          "JSON",
+		"jwt":                           "JWT",
+		"timezone":                      "fuseau horaire",
+		"country_code":                  "code pays",
+		"iso3166_1_alpha2":              "code pays ISO 3166-1 alpha-2",
+		"iso3166_1_alpha3":              "code pays ISO 3166-1 alpha-3",
+		"iso3166_1_alpha_numeric":       "code pays ISO 3166-1 numérique",
+		"iso3166_2":                     "code de subdivision ISO 3166-2",
+		"iso4217":                       "code devise ISO 4217",
+		"iso4217_numeric":               "code devise ISO 4217 numérique",
+		"bcp47_language_tag":            "étiquette de langue BCP 47",
+		"postcode_iso3166_alpha2":       "code postal",
+		"postcode_iso3166_alpha2_field": "code postal",
+		"bic":                           "BIC",
+		"semver":                        "version sémantique",
+		"dns_rfc1035_label":             "libellé DNS RFC 1035",
+		"credit_card":                   "numéro de carte bancaire",
+	},
+}
@@ -0,0 +1,222 @@
+package validation
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Locale of the messages when the client accepts none of the bundles
+const DefaultLocale = "en"
+
+// Validation messages of a language.
+// Templates replace {field} with the field name, {param} with the tag parameter and {tag} with the tag.
+type Bundle struct {
+	// Templates by tag, size tags have one per kind of field e.g min.string, min.items and min.number.
+	// The format template is used for the tags of Formats, with {format} replaced by their name,
+	// and the fallback template for tags without a message.
+	Messages map[string]string
+	// Names of the values checked by format tags e.g email address for email
+	Formats map[string]string
+}
+
+var (
+	bundlesMutex sync.RWMutex
+	bundles      = map[string]Bundle{"en": english, "fr": french}
+)
+
+// Add the bundle of a locale, or extend it e.g with the messages of a custom tag
+func RegisterBundle(locale string, bundle Bundle) {
+	bundlesMutex.Lock()
+	defer bundlesMutex.Unlock()
+	current, ok := bundles[locale]
+	if !ok {
+		current = Bundle{Messages: map[string]string{}, Formats: map[string]string{}}
+	}
+	for tag, message := range bundle.Messages {
+		current.Messages[tag] = message
+	}
+	for tag, format := range bundle.Formats {
+		current.Formats[tag] = format
+	}
+	bundles[locale] = current
+}
+
+// Locales with a bundle, sorted
+func Locales() []string {
+	bundlesMutex.RLock()
+	defer bundlesMutex.RUnlock()
+	locales := make([]string, 0, len(bundles))
+	for locale := range bundles {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Whether the locale bundle has a message for the tag, without falling back to the default locale
+func Translates(locale, tag string) bool {
+	bundlesMutex.RLock()
+	defer bundlesMutex.RUnlock()
+	bundle, ok := bundles[locale]
+	if !ok {
+		return false
+	}
+	if _, ok := bundle.Formats[tag]; ok {
+		return true
+	}
+	for key := range bundle.Messages {
+		if key == tag || strings.HasPrefix(key, tag+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Locale with a bundle best matching the Accept-Language header, DefaultLocale if none matches
+func Locale(acceptLanguage string) string {
+	type language struct {
+		locale  string
+		quality float64
+	}
+	var languages []language
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				quality = parsed
+			}
+		}
+		primary, _, _ := strings.Cut(tag, "-")
+		languages = append(languages, language{strings.ToLower(primary), quality})
+	}
+	sort.SliceStable(languages, func(i, j int) bool { return languages[i].quality > languages[j].quality })
+
+	bundlesMutex.RLock()
+	defer bundlesMutex.RUnlock()
+	for _, language := range languages {
+		if _, ok := bundles[language.locale]; ok && language.quality > 0 {
+			return language.locale
+		}
+	}
+	return DefaultLocale
+}
+
+// Message of the failed validation in the locale.
+// fieldName names the struct fields referenced by the tag parameter e.g the From of gtfield=From.
+func Message(locale string, fieldErr validator.FieldError, fieldName func(string) string) string {
+	bundlesMutex.RLock()
+	defer bundlesMutex.RUnlock()
+
+	tag := fieldErr.Tag()
+	message, ok := lookupMessage(locale, tag, kind(fieldErr))
+	if !ok {
+		if format, ok := lookupFormat(locale, tag); ok {
+			message, _ = lookupMessage(locale, "format", "")
+			message = strings.ReplaceAll(message, "{format}", format)
+		} else {
+			message, _ = lookupMessage(locale, "fallback", "")
+		}
+	}
+
+	return strings.NewReplacer(
+		"{field}", fieldErr.Field(),
+		"{param}", formatParam(locale, tag, fieldErr.Param(), fieldName),
+		"{tag}", tag,
+	).Replace(message)
+}
+
+// Message of a parameter whose value could not be decoded as the kind of value, time or number
+func DecodeMessage(locale, field, kind string) string {
+	bundlesMutex.RLock()
+	defer bundlesMutex.RUnlock()
+	message, ok := lookupMessage(locale, "decode", kind)
+	if !ok {
+		message, _ = lookupMessage(locale, "fallback", "")
+	}
+	return strings.NewReplacer("{field}", field, "{tag}", "decode").Replace(message)
+}
+
+// Message of an invalid csv row, kind is columns, unix or number.
+// The template replaces {field} with the column name and {value} with its value, or the expected number of columns.
+func RowMessage(locale, kind, field, value string) string {
+	bundlesMutex.RLock()
+	defer bundlesMutex.RUnlock()
+	message, ok := lookupMessage(locale, "row", kind)
+	if !ok {
+		message, _ = lookupMessage(locale, "fallback", "")
+	}
+	return strings.NewReplacer("{field}", field, "{value}", value, "{tag}", "row").Replace(message)
+}
+
+// Template of the tag for the kind of field in the locale, else in the default locale
+func lookupMessage(locale, tag, kind string) (string, bool) {
+	for _, bundle := range []Bundle{bundles[locale], bundles[DefaultLocale]} {
+		if message, ok := bundle.Messages[tag+"."+kind]; ok && kind != "" {
+			return message, true
+		}
+		if message, ok := bundle.Messages[tag]; ok {
+			return message, true
+		}
+	}
+	return "", false
+}
+
+func lookupFormat(locale, tag string) (string, bool) {
+	for _, bundle := range []Bundle{bundles[locale], bundles[DefaultLocale]} {
+		if format, ok := bundle.Formats[tag]; ok {
+			return format, true
+		}
+	}
+	return "", false
+}
+
+// Kind of the field for the size tags: string, items, time or number
+func kind(fieldErr validator.FieldError) string {
+	switch fieldErr.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return "items"
+	case reflect.Struct:
+		if fieldErr.Type() == reflect.TypeOf(time.Time{}) {
+			return "time"
+		}
+		return ""
+	}
+	return "number"
+}
+
+// Parameter of the tag as shown in messages, with the referenced fields named after their json or query name
+func formatParam(locale, tag, param string, fieldName func(string) string) string {
+	switch tag {
+	case "oneof":
+		return strings.Join(strings.Fields(param), ", ")
+	case "eqfield", "nefield", "gtfield", "gtefield", "ltfield", "ltefield", "fieldcontains", "fieldexcludes",
+		"eqcsfield", "necsfield", "gtcsfield", "gtecsfield", "ltcsfield", "ltecsfield":
+		return fieldName(param)
+	case "required_with", "required_with_all", "required_without", "required_without_all",
+		"excluded_with", "excluded_with_all", "excluded_without", "excluded_without_all":
+		names := strings.Fields(param)
+		for i, name := range names {
+			names[i] = fieldName(name)
+		}
+		return strings.Join(names, ", ")
+	case "required_if", "required_unless", "excluded_if", "excluded_unless":
+		pair, _ := lookupMessage(locale, "param.pair", "")
+		and, _ := lookupMessage(locale, "param.and", "")
+		values := strings.Fields(param)
+		conditions := make([]string, 0, len(values)/2)
+		for i := 0; i+1 < len(values); i += 2 {
+			conditions = append(conditions, strings.NewReplacer("{name}", fieldName(values[i]), "{value}", values[i+1]).Replace(pair))
+		}
+		return strings.Join(conditions, and)
+	}
+	return param
+}
@@ -0,0 +1,82 @@
+package validation
+
+import (
+	"csvapi-test/model"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Custom validation tags
+const (
+	// Uppercase trading pair symbol e.g BTCUSDT
+	TagSymbol = "symbol"
+	// High price of a candle is its highest price and low price its lowest, on the HIGH and LOW fields of model.Ohcl
+	TagOHLCConsistent = "ohlc_consistent"
+)
+
+// Regular expression of the symbol tag
+const SymbolPattern = `^[A-Z0-9][A-Z0-9._-]{1,31}$`
+
+var symbolRegex = regexp.MustCompile(SymbolPattern)
+
+var setupOnce sync.Once
+
+// Configure the validator of the gin bindings with Register, once.
+// Panics if the custom tags cannot be registered, which is a programming error.
+func Setup() {
+	setupOnce.Do(func() {
+		engine, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			panic("validation: the gin binding validator is not a go-playground validator")
+		}
+		if err := Register(engine); err != nil {
+			panic("validation: " + err.Error())
+		}
+	})
+}
+
+// Name fields after their json or query parameter name and register the custom tags
+func Register(engine *validator.Validate) error {
+	engine.RegisterTagNameFunc(FieldName)
+	if err := engine.RegisterValidation(TagSymbol, validateSymbol); err != nil {
+		return err
+	}
+	return engine.RegisterValidation(TagOHLCConsistent, validateOHLCConsistent)
+}
+
+// Name of the field in messages: its json name, else its query parameter name, else its go name
+func FieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name := strings.Split(field.Tag.Get(tag), ",")[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+func validateSymbol(fl validator.FieldLevel) bool {
+	return symbolRegex.MatchString(fl.Field().String())
+}
+
+func validateOHLCConsistent(fl validator.FieldLevel) bool {
+	candle, ok := fl.Parent().Interface().(model.Ohcl)
+	if !ok {
+		return false
+	}
+	switch fl.StructFieldName() {
+	case "HIGH":
+		return candle.HIGH >= candle.OPEN && candle.HIGH >= candle.CLOSE && candle.HIGH >= candle.LOW
+	case "LOW":
+		return candle.LOW <= candle.OPEN && candle.LOW <= candle.CLOSE && candle.LOW <= candle.HIGH
+	}
+	return false
+}